@@ -10,6 +10,7 @@ import (
 
 	loader "github.com/MottainaiCI/ssh-compose/pkg/loader"
 	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+	"github.com/MottainaiCI/ssh-compose/pkg/template"
 
 	"github.com/spf13/cobra"
 )
@@ -57,6 +58,9 @@ func newApplyCommand(config *specs.SshComposeConfig) *cobra.Command {
 
 			skipSync, _ := cmd.Flags().GetBool("skip-sync")
 			skipCompile, _ := cmd.Flags().GetBool("skip-compile")
+			deleteDryRun, _ := cmd.Flags().GetBool("delete-dry-run")
+			diff, _ := cmd.Flags().GetBool("diff")
+			drift := false
 
 			composer.SetFlagsDisabled(disabledFlags)
 			composer.SetFlagsEnabled(enabledFlags)
@@ -64,6 +68,7 @@ func newApplyCommand(config *specs.SshComposeConfig) *cobra.Command {
 			composer.SetGroupsEnabled(enabledGroups)
 			composer.SetSkipSync(skipSync)
 			composer.SetSkipCompile(skipCompile)
+			composer.SetDeleteDryRun(deleteDryRun)
 
 			projects := args[0:]
 
@@ -79,7 +84,8 @@ func newApplyCommand(config *specs.SshComposeConfig) *cobra.Command {
 
 				pObj := env.GetProjectByName(proj)
 				for _, varFile := range varsFiles {
-					err := pObj.LoadEnvVarsFile(varFile, config)
+					err := pObj.LoadEnvVarsFile(varFile, config,
+						composer.GetRenderValuesFiles(env))
 					if err != nil {
 						logger.Fatal(fmt.Sprintf(
 							"Error on load additional envs var file %s: %s",
@@ -100,6 +106,18 @@ func newApplyCommand(config *specs.SshComposeConfig) *cobra.Command {
 					pObj.AddEnvironment(evars)
 				}
 
+				if diff {
+					d, err := composer.DiffProject(proj, template.CompilerOpts{})
+					if err != nil {
+						logger.Fatal(fmt.Sprintf(
+							"Project %s failed. %s", proj, err.Error()))
+					}
+					if d {
+						drift = true
+					}
+					continue
+				}
+
 				err = composer.ApplyProject(proj)
 				if err != nil {
 					logger.Fatal(fmt.Sprintf(
@@ -108,6 +126,10 @@ func newApplyCommand(config *specs.SshComposeConfig) *cobra.Command {
 
 			}
 
+			if drift {
+				os.Exit(1)
+			}
+
 			logger.InfoC(":tada:All done!")
 		},
 	}
@@ -130,6 +152,11 @@ func newApplyCommand(config *specs.SshComposeConfig) *cobra.Command {
 		"Add additional environments vars file.")
 	flags.Bool("skip-sync", false, "Disable sync of files.")
 	flags.Bool("skip-compile", false, "Disable compile of templates.")
+	flags.Bool("delete-dry-run", false,
+		"Show the remote files to delete of the sync resources in mirror mode without removing them.")
+	flags.Bool("diff", false,
+		"Show the differences between the compiled files and the remote files without\n"+
+			"applying the project. Exit with status 1 if there are differences.")
 
 	return cmd
 }
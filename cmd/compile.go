@@ -64,6 +64,9 @@ func NewCompileCommand(config *specs.SshComposeConfig) *cobra.Command {
 				GroupsDisabled: disabledGroups,
 			}
 
+			diff, _ := cmd.Flags().GetBool("diff")
+			drift := false
+
 			projects := args
 			for _, proj := range projects {
 
@@ -77,7 +80,8 @@ func NewCompileCommand(config *specs.SshComposeConfig) *cobra.Command {
 					pObj := env.GetProjectByName(proj)
 
 					for _, varFile := range varsFiles {
-						err := pObj.LoadEnvVarsFile(varFile, config)
+						err := pObj.LoadEnvVarsFile(varFile, config,
+							composer.GetRenderValuesFiles(env))
 						if err != nil {
 							logger.Fatal(fmt.Sprintf(
 								"Error on load additional envs var file %s: %s",
@@ -103,12 +107,43 @@ func NewCompileCommand(config *specs.SshComposeConfig) *cobra.Command {
 					logger.Aurora.Bold(fmt.Sprintf(
 						">>> Compile files for project :right_arrow:%s :rocket:", proj)))
 
+				if diff {
+					d, err := composer.DiffProject(proj, opts)
+					if err != nil {
+						logger.Fatal("Error on diff files of the project " +
+							proj + ":" + err.Error() + "\n")
+					}
+					if d {
+						drift = true
+					}
+					continue
+				}
+
+				opts.Cache = nil
+				if config.GetGeneral().CompileCache {
+					opts.Cache, err = template.NewEnvCompileCache(env)
+					if err != nil {
+						logger.Fatal("Error on load compile cache: " + err.Error() + "\n")
+					}
+				}
+
 				err := template.CompileAllProjectFiles(env, proj, opts)
 				if err != nil {
 					logger.Fatal("Error on compile files of the project " +
 						proj + ":" + err.Error() + "\n")
 				}
 
+				if opts.Cache != nil {
+					err = opts.Cache.Save()
+					if err != nil {
+						logger.Warning("Error on save compile cache: " + err.Error())
+					}
+				}
+
+			}
+
+			if drift {
+				os.Exit(1)
 			}
 
 			logger.InfoC(":tada:All done!")
@@ -129,6 +164,9 @@ func NewCompileCommand(config *specs.SshComposeConfig) *cobra.Command {
 		"Append render engine environments in the format key=value.")
 	flags.StringSliceVar(&varsFiles, "vars-file", []string{},
 		"Add additional environments vars file.")
+	flags.Bool("diff", false,
+		"Show the differences between the compiled files and the remote files of the nodes.\n"+
+			"Exit with status 1 if there are differences.")
 
 	return cmd
 }
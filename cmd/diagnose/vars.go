@@ -67,7 +67,8 @@ func NewVarsCommand(config *specs.SshComposeConfig) *cobra.Command {
 			proj := env.GetProjectByName(pName)
 
 			for _, varFile := range varsFiles {
-				err := proj.LoadEnvVarsFile(varFile, config)
+				err := proj.LoadEnvVarsFile(varFile, config,
+					composer.GetRenderValuesFiles(env))
 				if err != nil {
 					fmt.Println(fmt.Sprintf(
 						"Error on load additional envs var file %s: %s",
@@ -24,6 +24,13 @@ func NewPullCommand(config *specs.SshComposeConfig) *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			localAsTarget, _ := cmd.Flags().GetBool("local-as-target")
 			ensurePerms, _ := cmd.Flags().GetBool("ensure-perms")
+			verify, _ := cmd.Flags().GetBool("verify")
+			exclude, _ := cmd.Flags().GetStringArray("exclude")
+			include, _ := cmd.Flags().GetStringArray("include")
+			progress, _ := cmd.Flags().GetBool("progress")
+			newerThan, _ := cmd.Flags().GetString("newer-than")
+			minSize, _ := cmd.Flags().GetString("min-size")
+			maxSize, _ := cmd.Flags().GetString("max-size")
 
 			// Create Instance also if not really used but
 			// contains the right setup of the logger and the
@@ -63,7 +70,7 @@ func NewPullCommand(config *specs.SshComposeConfig) *cobra.Command {
 						fmt.Sprintf("Using remote:\t%s",
 							remoteName))))
 
-			err = executor.SetupSftp()
+			err = executor.SetupSftp(executor.GetSftpClientOptions()...)
 			if err != nil {
 				logger.Fatal("Error on setup sftp client on executor of the node " +
 					remoteName + ": " + err.Error())
@@ -76,8 +83,22 @@ func NewPullCommand(config *specs.SshComposeConfig) *cobra.Command {
 						fmt.Sprintf(">>> [%s] Pulling %s -> %s ",
 							remoteName, remotePath, localPath))))
 
-			err = executor.RecursivePullFile(remoteName,
-				remotePath, localPath, localAsTarget, ensurePerms)
+			syncOpts := ssh_executor.NewSyncOpts()
+			syncOpts.EnsurePerms = ensurePerms
+			syncOpts.Verify = verify
+			syncOpts.Exclude = exclude
+			syncOpts.Include = include
+			syncOpts.NewerThan = newerThan
+			syncOpts.MinSize = minSize
+			syncOpts.MaxSize = maxSize
+			syncOpts.Progress = progress || config.GetLogging().PushProgressBar
+			syncOpts.ResumeThreshold = config.GetGeneral().ResumeThreshold
+			if cmd.Flags().Changed("resume-threshold") {
+				syncOpts.ResumeThreshold, _ = cmd.Flags().GetInt64("resume-threshold")
+			}
+
+			err = executor.RecursivePullFileWithOpts(remoteName,
+				remotePath, localPath, localAsTarget, syncOpts)
 			if err != nil {
 				logger.Fatal("Error on pull files from " +
 					remoteName + ": " + err.Error())
@@ -92,6 +113,22 @@ func NewPullCommand(config *specs.SshComposeConfig) *cobra.Command {
 		"Using the local path as target path instead of append all remote path.")
 	flags.Bool("ensure-perms", false,
 		"Force sync of the remote uid/gid and file modes on local copy.")
+	flags.Bool("verify", false,
+		"Verify the SHA-256 checksum of the transferred files.")
+	flags.StringArray("exclude", []string{},
+		"Gitignore-style pattern of the files to exclude.")
+	flags.StringArray("include", []string{},
+		"Gitignore-style pattern of the excluded files to include again.")
+	flags.String("newer-than", "",
+		"Pull only the files modified in the duration (ex. 12h, 7d) or after the date.")
+	flags.String("min-size", "",
+		"Pull only the files with a size greater or equal (ex. 10K, 1M).")
+	flags.String("max-size", "",
+		"Pull only the files with a size lesser or equal (ex. 10K, 1M).")
+	flags.Bool("progress", false,
+		"Show the progress of the transferred files (default logging.push_progressbar).")
+	flags.Int64("resume-threshold", 0,
+		"Minimum size in bytes of the files transferred with resume support (default general.resume_threshold, 0 disables).")
 
 	return cmd
 }
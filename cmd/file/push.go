@@ -23,6 +23,14 @@ func NewPushCommand(config *specs.SshComposeConfig) *cobra.Command {
 		Short:   "Sync files from local path to remote path.",
 		Run: func(cmd *cobra.Command, args []string) {
 			ensurePerms, _ := cmd.Flags().GetBool("ensure-perms")
+			verify, _ := cmd.Flags().GetBool("verify")
+			exclude, _ := cmd.Flags().GetStringArray("exclude")
+			include, _ := cmd.Flags().GetStringArray("include")
+			compare, _ := cmd.Flags().GetString("compare")
+			mode, _ := cmd.Flags().GetString("mode")
+			compression, _ := cmd.Flags().GetString("compression")
+			workers, _ := cmd.Flags().GetInt("workers")
+			progress, _ := cmd.Flags().GetBool("progress")
 
 			// Create Instance also if not really used but
 			// contains the right setup of the logger and the
@@ -62,7 +70,7 @@ func NewPushCommand(config *specs.SshComposeConfig) *cobra.Command {
 						fmt.Sprintf("Using remote:\t%s",
 							remoteName))))
 
-			err = executor.SetupSftp()
+			err = executor.SetupSftp(executor.GetSftpClientOptions()...)
 			if err != nil {
 				logger.Fatal("Error on setup sftp client on executor of the node " +
 					remoteName + ": " + err.Error())
@@ -75,20 +83,56 @@ func NewPushCommand(config *specs.SshComposeConfig) *cobra.Command {
 						fmt.Sprintf(">>> [%s] Pusing %s -> %s ",
 							remoteName, localPath, remotePath))))
 
-			err = executor.RecursivePushFile(remoteName,
-				localPath, remotePath, ensurePerms)
+			syncOpts := ssh_executor.NewSyncOpts()
+			syncOpts.EnsurePerms = ensurePerms
+			syncOpts.Compare = compare
+			syncOpts.Mode = mode
+			syncOpts.Compression = compression
+			syncOpts.Verify = verify
+			syncOpts.Exclude = exclude
+			syncOpts.Include = include
+			syncOpts.Workers = workers
+			syncOpts.Progress = progress || config.GetLogging().PushProgressBar
+			syncOpts.ResumeThreshold = config.GetGeneral().ResumeThreshold
+			if cmd.Flags().Changed("resume-threshold") {
+				syncOpts.ResumeThreshold, _ = cmd.Flags().GetInt64("resume-threshold")
+			}
+			if syncOpts.Workers == 0 {
+				syncOpts.Workers = executor.GetSftpWorkers()
+			}
+
+			err = executor.RecursivePushFileWithOpts(remoteName,
+				localPath, remotePath, syncOpts)
 			if err != nil {
 				logger.Fatal("Error on push files to " +
 					remoteName + ": " + err.Error())
 			}
 
-			logger.InfoC(":tada:All done!")
+			logger.InfoC(fmt.Sprintf(":tada:All done! (%s)", syncOpts.Stats))
 		},
 	}
 
 	var flags = cmd.Flags()
 	flags.Bool("ensure-perms", false,
 		"Force sync of the local uid/gid and file modes to the remote copy.")
+	flags.String("compare", "",
+		"Skip unchanged files comparing them with the remote copy (size, mtime, checksum).")
+	flags.String("mode", ssh_executor.TransferModeSftp,
+		"Transfer mode of the files (sftp, tar).")
+	flags.String("compression", ssh_executor.CompressionNone,
+		"Compression of the tar stream (none, gzip).")
+	flags.Bool("verify", false,
+		"Verify the SHA-256 checksum of the transferred files.")
+	flags.StringArray("exclude", []string{},
+		"Gitignore-style pattern of the files to exclude.")
+	flags.StringArray("include", []string{},
+		"Gitignore-style pattern of the excluded files to include again.")
+	flags.Bool("progress", false,
+		"Show the progress of the transferred files (default logging.push_progressbar).")
+	flags.Int64("resume-threshold", 0,
+		"Minimum size in bytes of the files transferred with resume support (default general.resume_threshold, 0 disables).")
+	flags.Int("workers", 0,
+		"Number of files transferred in parallel (default sftp_workers of the remote).")
 
 	return cmd
 }
@@ -77,7 +77,8 @@ func initCommand(rootCmd *cobra.Command, config *specs.SshComposeConfig) {
 	var pflags = rootCmd.PersistentFlags()
 
 	pflags.StringP("config", "c", "", "SSH Compose configuration file")
-	pflags.String("render-values", "", "Override render values file.")
+	pflags.StringArray("render-values", []string{},
+		"Override render values files. The files are merged in order.")
 	pflags.String("render-default", "", "Override render default file.")
 	pflags.Bool("cmds-output", config.Viper.GetBool("logging.cmds_output"),
 		"Show hooks commands output or not.")
@@ -88,7 +89,6 @@ func initCommand(rootCmd *cobra.Command, config *specs.SshComposeConfig) {
 
 	config.Viper.BindPFlag("config", pflags.Lookup("config"))
 	config.Viper.BindPFlag("render_default_file", pflags.Lookup("render-default"))
-	config.Viper.BindPFlag("render_values_file", pflags.Lookup("render-values"))
 	config.Viper.BindPFlag("general.debug", pflags.Lookup("debug"))
 	config.Viper.BindPFlag("logging.cmds_output", pflags.Lookup("cmds-output"))
 	config.Viper.BindPFlag("security.keyfile", pflags.Lookup("keyfile"))
@@ -160,6 +160,13 @@ func Execute() {
 				}
 			}
 
+			if cmd.Flags().Changed("render-values") {
+				// NOTE: the values files of the flags replace the
+				//       values files of the configuration.
+				config.RenderValuesFile = ""
+				config.RenderValuesFiles, _ = cmd.Flags().GetStringArray("render-values")
+			}
+
 			// Load key decryption key if available keyfile
 			if config.GetSecurity().Keyfile != "" && config.GetSecurity().Key == "" {
 				// NOTE: if the Key is present it wins.
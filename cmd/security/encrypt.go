@@ -40,7 +40,7 @@ func NewEncryptCommand(config *specs.SshComposeConfig) *cobra.Command {
 
 			keyBytes, err := base64.StdEncoding.DecodeString(config.GetSecurity().Key)
 			if err != nil {
-				fmt.Println("error on decode key: %s", err.Error())
+				fmt.Println("error on decode key:", err.Error())
 				os.Exit(1)
 			}
 
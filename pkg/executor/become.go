@@ -0,0 +1,253 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/MottainaiCI/ssh-compose/pkg/helpers"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	BecomeMethodSudo = "sudo"
+	BecomeMethodSu   = "su"
+)
+
+type BecomeOpts struct {
+	User   string
+	Method string
+	Pass   string
+
+	// Random strings used to identify the password prompt
+	// and the begin of the command execution.
+	prompt string
+	marker string
+}
+
+func NewBecomeOpts(user, method, pass string) *BecomeOpts {
+	id := uuid.New().String()
+	ans := &BecomeOpts{
+		User:   user,
+		Method: method,
+		Pass:   pass,
+		prompt: fmt.Sprintf("[sshc-become-prompt-%s]", id),
+		marker: fmt.Sprintf("SSHC-BECOME-SUCCESS-%s", id),
+	}
+
+	if ans.User == "" {
+		ans.User = "root"
+	}
+	if ans.Method == "" {
+		ans.Method = BecomeMethodSudo
+	}
+
+	return ans
+}
+
+func (b *BecomeOpts) Validate() error {
+	switch b.Method {
+	case BecomeMethodSudo, BecomeMethodSu:
+	default:
+		return fmt.Errorf("Invalid become method %s", b.Method)
+	}
+	return nil
+}
+
+// UsePty returns true if the become method requires a
+// pseudo terminal to ask the password.
+func (b *BecomeOpts) UsePty() bool {
+	return b.Method == BecomeMethodSu
+}
+
+// WrapCommand returns the command to execute through the
+// become method. The marker is printed before the execution
+// of the command in order to detect when the password is
+// been accepted.
+func (b *BecomeOpts) WrapCommand(command string) string {
+	shcmd := fmt.Sprintf("echo %s >&2; %s", b.marker, command)
+
+	switch b.Method {
+	case BecomeMethodSu:
+		return fmt.Sprintf("su %s -c %s",
+			helpers.ShellQuote(b.User), helpers.ShellQuote(shcmd))
+	default:
+		return fmt.Sprintf("sudo -S -p %s -u %s -- /bin/sh -c %s",
+			helpers.ShellQuote(b.prompt),
+			helpers.ShellQuote(b.User),
+			helpers.ShellQuote(shcmd))
+	}
+}
+
+func (b *BecomeOpts) getPrompt() string {
+	if b.Method == BecomeMethodSu {
+		// su doesn't permit to customize the prompt.
+		return "assword:"
+	}
+	return b.prompt
+}
+
+// becomeWatcher is a writer that intercepts the password prompt
+// and the success marker from the output of the become method.
+type becomeWatcher struct {
+	mutex   sync.Mutex
+	writer  io.Writer
+	prompt  string
+	marker  string
+	buf     []byte
+	started bool
+
+	promptCh  chan struct{}
+	successCh chan struct{}
+}
+
+func newBecomeWatcher(w io.Writer, b *BecomeOpts) *becomeWatcher {
+	return &becomeWatcher{
+		writer:    w,
+		prompt:    b.getPrompt(),
+		marker:    b.marker,
+		buf:       []byte{},
+		started:   false,
+		promptCh:  make(chan struct{}, 3),
+		successCh: make(chan struct{}),
+	}
+}
+
+func (w *becomeWatcher) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.started {
+		return w.writer.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+
+	for bytes.Contains(w.buf, []byte(w.prompt)) {
+		w.buf = bytes.Replace(w.buf, []byte(w.prompt), []byte{}, 1)
+		select {
+		case w.promptCh <- struct{}{}:
+		default:
+		}
+	}
+
+	idx := bytes.Index(w.buf, []byte(w.marker))
+	if idx >= 0 {
+		rest := w.buf[idx+len(w.marker):]
+		rest = bytes.TrimLeft(rest, "\r\n")
+		w.started = true
+		close(w.successCh)
+
+		if len(rest) > 0 {
+			_, err := w.writer.Write(rest)
+			if err != nil {
+				return len(p), err
+			}
+		}
+		w.buf = []byte{}
+	}
+
+	return len(p), nil
+}
+
+// Flush writes the data not yet processed. This is useful
+// to see the errors of the become method.
+func (w *becomeWatcher) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.started && len(w.buf) > 0 {
+		w.writer.Write(w.buf)
+		w.buf = []byte{}
+	}
+}
+
+// runWithBecome executes the command through the become method
+// answering to the password prompt and forwarding the stdin
+// only after that the command is been started.
+func (e *SshCExecutor) runWithBecome(session *SshCSession, runArgs string,
+	outBuffer, errBuffer io.Writer, opts *CommandOpts) error {
+
+	b := opts.Become
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	if b.UsePty() && opts.Stdin != nil {
+		// The pseudo terminal translates the line endings and the
+		// control characters of the data sent through the stdin.
+		return fmt.Errorf("stdin is not supported by the become method %s",
+			b.Method)
+	}
+
+	if b.UsePty() {
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          0,
+			ssh.TTY_OP_OSPEED: e.TTYOpOSpeed,
+			ssh.TTY_OP_ISPEED: e.TTYOpISpeed,
+		}
+		if err := session.RequestPty("xterm", 80, 200, modes); err != nil {
+			return fmt.Errorf(
+				"error on request pseudo terminal: %s", err.Error())
+		}
+	}
+
+	var watcher *becomeWatcher
+	if b.UsePty() {
+		// With a pty the stderr is merged with the stdout
+		watcher = newBecomeWatcher(outBuffer, b)
+		session.Stdout = watcher
+		session.Stderr = errBuffer
+	} else {
+		watcher = newBecomeWatcher(errBuffer, b)
+		session.Stdout = outBuffer
+		session.Stderr = watcher
+	}
+	defer watcher.Flush()
+
+	stdinPipe, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		nPrompt := 0
+		for {
+			select {
+			case <-watcher.promptCh:
+				nPrompt++
+				if nPrompt > 1 || b.Pass == "" {
+					// POST: wrong or missing password.
+					stdinPipe.Close()
+					return
+				}
+				stdinPipe.Write([]byte(b.Pass + "\n"))
+			case <-watcher.successCh:
+				if opts.Stdin != nil {
+					io.Copy(stdinPipe, opts.Stdin)
+				}
+				stdinPipe.Close()
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return session.Run(b.WrapCommand(runArgs))
+}
+
+func (b *BecomeOpts) String() string {
+	return strings.Join([]string{b.Method, b.User}, ":")
+}
@@ -0,0 +1,351 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/MottainaiCI/ssh-compose/pkg/executor"
+	"github.com/MottainaiCI/ssh-compose/pkg/helpers"
+	log "github.com/MottainaiCI/ssh-compose/pkg/logger"
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+)
+
+// newExecExecutor returns an executor connected to an in-process
+// SSH server that runs the commands with the local shell. The
+// pseudo terminal requests are accepted but ignored.
+func newExecExecutor(env []string) *SshCExecutor {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).Should(BeNil())
+	signer, err := ssh.NewSignerFromKey(key)
+	Expect(err).Should(BeNil())
+
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).Should(BeNil())
+
+	go func() {
+		defer listener.Close()
+		serverConn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		_, chans, reqs, err := ssh.NewServerConn(serverConn, serverConfig)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+
+		for newCh := range chans {
+			ch, chReqs, err := newCh.Accept()
+			if err != nil {
+				continue
+			}
+			go serveExecSession(ch, chReqs, env)
+		}
+	}()
+
+	clientConn, err := net.Dial("tcp", listener.Addr().String())
+	Expect(err).Should(BeNil())
+
+	conn, chans, reqs, err := ssh.NewClientConn(clientConn, listener.Addr().String(), &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	Expect(err).Should(BeNil())
+
+	ans := NewSshCExecutor("test", "127.0.0.1", 22)
+	ans.Client = ssh.NewClient(conn, chans, reqs)
+	return ans
+}
+
+// newSudoExecutor returns an executor with the exec sessions and the
+// SFTP client where the sudo command is replaced by a script that
+// executes the command as the current user with a clean environment.
+func newSudoExecutor(dir string) *SshCExecutor {
+	binDir := filepath.Join(dir, "bin")
+	Expect(os.MkdirAll(binDir, 0755)).Should(BeNil())
+	Expect(os.WriteFile(filepath.Join(binDir, "sudo"), []byte(
+		"#!/bin/sh\nwhile [ \"$1\" != \"--\" ]; do shift; done\nshift\n"+
+			"exec env -i PATH=\"$PATH\" \"$@\"\n",
+	), 0755)).Should(BeNil())
+
+	ans := newExecExecutor([]string{
+		"PATH=" + binDir + ":" + os.Getenv("PATH"),
+	})
+	ans.SftpClient = newSftpExecutor().SftpClient
+	return ans
+}
+
+func serveExecSession(ch ssh.Channel, reqs <-chan *ssh.Request, env []string) {
+	defer ch.Close()
+
+	for req := range reqs {
+		if req.Type != "exec" {
+			req.Reply(true, nil)
+			continue
+		}
+		req.Reply(true, nil)
+
+		size := binary.BigEndian.Uint32(req.Payload)
+		cmd := exec.Command("/bin/sh", "-c", string(req.Payload[4:4+size]))
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdout = ch
+		cmd.Stderr = ch.Stderr()
+		stdin, _ := cmd.StdinPipe()
+		go func() {
+			io.Copy(stdin, ch)
+			stdin.Close()
+		}()
+
+		status := uint32(0)
+		if err := cmd.Run(); err != nil {
+			status = 1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				status = uint32(exitErr.ExitCode())
+			}
+		}
+
+		payload := make([]byte, 4)
+		binary.BigEndian.PutUint32(payload, status)
+		ch.SendRequest("exit-status", false, payload)
+		return
+	}
+}
+
+var _ = Describe("Become", func() {
+
+	// The content contains all the bytes altered by a pseudo terminal.
+	content := make([]byte, 0, 512)
+	for i := 0; i < 2; i++ {
+		for b := 0; b < 256; b++ {
+			content = append(content, byte(b))
+		}
+	}
+
+	Context("Binary stdin", func() {
+
+		It("Send the stdin through sudo", func() {
+			dir, err := os.MkdirTemp("", "sshc-become")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			executor := newSudoExecutor(dir)
+			defer executor.Client.Close()
+
+			target := filepath.Join(dir, "target.bin")
+			res, err := executor.ExecCommand("cat > "+target, bytes.NewReader(content),
+				nil, nil, NewBecomeOpts("root", BecomeMethodSudo, ""))
+			Expect(err).Should(BeNil())
+			Expect(res).To(Equal(0))
+
+			data, err := os.ReadFile(target)
+			Expect(err).Should(BeNil())
+			Expect(data).To(Equal(content))
+		})
+
+		It("Reject the stdin through su", func() {
+			executor := newExecExecutor([]string{})
+			defer executor.Client.Close()
+
+			_, err := executor.ExecCommand("cat > /dev/null", bytes.NewReader(content),
+				nil, nil, NewBecomeOpts("root", BecomeMethodSu, ""))
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Upload the content through su", func() {
+			if os.Getuid() != 0 {
+				Skip("su without password requires root")
+			}
+
+			dir, err := os.MkdirTemp("", "sshc-become")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			executor := newExecExecutor([]string{})
+			defer executor.Client.Close()
+
+			opts := NewSyncOpts()
+			opts.Become = NewBecomeOpts("root", BecomeMethodSu, "")
+
+			target := filepath.Join(dir, "sub", "target.bin")
+			err = executor.UploadContentWithOpts("test", target, content, opts)
+			Expect(err).Should(BeNil())
+
+			data, err := os.ReadFile(target)
+			Expect(err).Should(BeNil())
+			Expect(data).To(Equal(content))
+		})
+
+	})
+
+	Context("Push with become", func() {
+
+		It("Replace the files through a rename", func() {
+			dir, err := os.MkdirTemp("", "sshc-become")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			cwd, err := os.Getwd()
+			Expect(err).Should(BeNil())
+			Expect(os.Chdir(dir)).Should(BeNil())
+			defer os.Chdir(cwd)
+
+			executor := newSudoExecutor(dir)
+			defer executor.Client.Close()
+
+			files := map[string]string{
+				"source/a.txt":     "a2",
+				"source/sub/b.txt": "b2",
+				"target/a.txt":     "a1",
+				"target/c.txt":     "c1",
+			}
+			for f, content := range files {
+				Expect(os.MkdirAll(filepath.Dir(f), 0755)).Should(BeNil())
+				Expect(os.WriteFile(f, []byte(content), 0644)).Should(BeNil())
+			}
+
+			target := filepath.Join(dir, "target")
+			opts := NewSyncOpts()
+			opts.Backup = true
+			opts.Become = NewBecomeOpts("root", BecomeMethodSudo, "")
+			err = executor.RecursivePushFileWithOpts("test", "source/", target, opts)
+			Expect(err).Should(BeNil())
+
+			entries, err := os.ReadDir(target)
+			Expect(err).Should(BeNil())
+			names := []string{}
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			// No staging directory is left and the replaced
+			// file is kept as backup.
+			Expect(len(names)).To(Equal(4))
+			Expect(names).To(ContainElements("a.txt", "c.txt", "sub"))
+
+			data, err := os.ReadFile(filepath.Join(target, "a.txt"))
+			Expect(err).Should(BeNil())
+			Expect(string(data)).To(Equal("a2"))
+
+			data, err = os.ReadFile(filepath.Join(target, "sub", "b.txt"))
+			Expect(err).Should(BeNil())
+			Expect(string(data)).To(Equal("b2"))
+
+			// Single file
+			Expect(os.WriteFile("source/a.txt", []byte("a3"), 0644)).Should(BeNil())
+			opts = NewSyncOpts()
+			opts.Become = NewBecomeOpts("root", BecomeMethodSudo, "")
+			err = executor.RecursivePushFileWithOpts("test", "source/a.txt",
+				filepath.Join(target, "a.txt"), opts)
+			Expect(err).Should(BeNil())
+
+			data, err = os.ReadFile(filepath.Join(target, "a.txt"))
+			Expect(err).Should(BeNil())
+			Expect(string(data)).To(Equal("a3"))
+
+			entries, err = os.ReadDir(target)
+			Expect(err).Should(BeNil())
+			Expect(len(entries)).To(Equal(4))
+		})
+
+		It("Resume the partial files of the staging directory", func() {
+			dir, err := os.MkdirTemp("", "sshc-become")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			// The home directory of the SFTP server is the
+			// current directory.
+			cwd, err := os.Getwd()
+			Expect(err).Should(BeNil())
+			Expect(os.Chdir(dir)).Should(BeNil())
+			defer os.Chdir(cwd)
+
+			executor := newSudoExecutor(dir)
+			defer executor.Client.Close()
+
+			content := bytes.Repeat([]byte("0123456789"), 100)
+			Expect(os.MkdirAll("source", 0755)).Should(BeNil())
+			Expect(os.WriteFile("source/big.bin", content, 0644)).Should(BeNil())
+
+			// Partial file left by a failed transfer.
+			target := filepath.Join(dir, "target")
+			sum := sha256.Sum256([]byte(target))
+			stagingDir := filepath.Join(dir, ".cache", "ssh-compose", "become",
+				hex.EncodeToString(sum[:])[:16])
+			partial := filepath.Join(stagingDir, target, ".big.bin.sshc-part")
+			Expect(os.MkdirAll(filepath.Dir(partial), 0755)).Should(BeNil())
+			Expect(os.WriteFile(partial, content[:600], 0644)).Should(BeNil())
+			// Stale file of the failed transfer.
+			Expect(os.WriteFile(filepath.Join(stagingDir, target, "old.txt"),
+				[]byte("old"), 0644)).Should(BeNil())
+
+			opts := NewSyncOpts()
+			opts.ResumeThreshold = 100
+			opts.Become = NewBecomeOpts("root", BecomeMethodSudo, "")
+			err = executor.RecursivePushFileWithOpts("test", "source/", target, opts)
+			Expect(err).Should(BeNil())
+			Expect(opts.Stats.Bytes).To(Equal(int64(400)))
+
+			data, err := os.ReadFile(filepath.Join(target, "big.bin"))
+			Expect(err).Should(BeNil())
+			Expect(data).To(Equal(content))
+
+			_, err = os.Stat(filepath.Join(target, "old.txt"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+			_, err = os.Stat(stagingDir)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+	})
+
+	Context("Commands with become", func() {
+
+		It("Export the project variables through sudo", func() {
+			config := specs.NewSshComposeConfig(nil)
+			config.General.EnvSessionPrefix = "SSH_COMPOSE"
+			log.NewSshCLogger(config).SetAsDefault()
+
+			dir, err := os.MkdirTemp("", "sshc-become")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			executor := newSudoExecutor(dir)
+			defer executor.Client.Close()
+
+			var outBuffer, errBuffer bytes.Buffer
+			opts := NewCommandOpts()
+			opts.Become = NewBecomeOpts("root", BecomeMethodSudo, "")
+			opts.Envs = map[string]string{"HOOK_VAR": "value2"}
+			res, err := executor.RunCommandWithOutputWithOpts("test",
+				`echo "$SSH_COMPOSE_PROJECT $HOOK_VAR"`,
+				map[string]string{"key1": "value1"},
+				helpers.NewNopCloseWriter(&outBuffer),
+				helpers.NewNopCloseWriter(&errBuffer),
+				[]string{}, opts)
+			Expect(err).Should(BeNil())
+			Expect(res).To(Equal(0))
+			Expect(outBuffer.String()).To(Equal("{\"key1\":\"value1\"} value2\n"))
+		})
+
+	})
+
+})
@@ -10,7 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"strings"
+	"sort"
 
 	helpers "github.com/MottainaiCI/ssh-compose/pkg/helpers"
 	log "github.com/MottainaiCI/ssh-compose/pkg/logger"
@@ -19,7 +19,59 @@ import (
 	"github.com/google/uuid"
 )
 
+type CommandOpts struct {
+	// Content to send as stdin of the command. If nil
+	// the stdin is disabled.
+	Stdin io.Reader
+
+	// Privilege escalation options. If nil the command
+	// is executed with the login user.
+	Become *BecomeOpts
+
+	// Working directory of the command.
+	Cwd string
+
+	// Arguments of the command executed without the
+	// entrypoint. Every argument is quoted and passed
+	// as is without shell expansions.
+	Args []string
+
+	// Define how the environment variables are propagated
+	// to the command (json, export, none).
+	EnvMode string
+
+	// Environment variables of the hook. With the json mode
+	// these variables are exported to the command too.
+	Envs map[string]string
+}
+
+func NewCommandOpts() *CommandOpts {
+	return &CommandOpts{
+		Stdin:   nil,
+		Become:  nil,
+		Cwd:     "",
+		Args:    []string{},
+		EnvMode: specs.EnvModeJson,
+		Envs:    map[string]string{},
+	}
+}
+
+func (o *CommandOpts) GetStdin() io.Reader {
+	if o.Stdin == nil {
+		return io.NopCloser(bytes.NewReader(nil))
+	}
+	return o.Stdin
+}
+
 func (e *SshCExecutor) RunCommandWithOutput(nodeName, command string, envs map[string]string, outBuffer, errBuffer io.WriteCloser, entryPoint []string) (int, error) {
+	return e.RunCommandWithOutputWithOpts(nodeName, command, envs,
+		outBuffer, errBuffer, entryPoint, NewCommandOpts())
+}
+
+func (e *SshCExecutor) RunCommandWithOutputWithOpts(nodeName, command string, envs map[string]string, outBuffer, errBuffer io.WriteCloser, entryPoint []string, opts *CommandOpts) (int, error) {
+	if opts == nil {
+		opts = NewCommandOpts()
+	}
 	if outBuffer == nil {
 		return 1, errors.New("Invalid outBuffer")
 	}
@@ -48,36 +100,78 @@ func (e *SshCExecutor) RunCommandWithOutput(nodeName, command string, envs map[s
 			logger.Aurora.BrightCyan(
 				fmt.Sprintf(">>> [%s] - %s - :coffee:", nodeName, command)))))
 
-	if len(envs) > 0 {
-
-		data, err := json.Marshal(envs)
-		if err != nil {
-			return 1, fmt.Errorf("error on convert envs map to string: %s",
-				err.Error())
+	cmdPrefix := ""
+	switch opts.EnvMode {
+	case specs.EnvModeNone:
+	case specs.EnvModeExport:
+		// The variables of the hook win over the project variables.
+		exported := make(map[string]string, len(envs)+len(opts.Envs))
+		for k, v := range envs {
+			exported[k] = v
+		}
+		for k, v := range opts.Envs {
+			exported[k] = v
 		}
+		cmdPrefix = exportEnvs(exported)
+	default:
+		if len(envs) > 0 {
+			data, err := json.Marshal(envs)
+			if err != nil {
+				return 1, fmt.Errorf("error on convert envs map to string: %s",
+					err.Error())
+			}
 
-		envprefix := logger.Config.GetGeneral().EnvSessionPrefix
+			envprefix := logger.Config.GetGeneral().EnvSessionPrefix
 
-		sshcproject := string(data)
-		_ = session.Setenv(fmt.Sprintf("%s_PROJECT", envprefix), sshcproject)
-		_ = session.Setenv(fmt.Sprintf("%s_VERSION", envprefix), specs.SSH_COMPOSE_VERSION)
+			sshcproject := string(data)
+			_ = session.Setenv(fmt.Sprintf("%s_PROJECT", envprefix), sshcproject)
+			_ = session.Setenv(fmt.Sprintf("%s_VERSION", envprefix), specs.SSH_COMPOSE_VERSION)
+
+			if opts.Become != nil {
+				// The variables of the session are dropped by the
+				// env_reset option of sudo.
+				cmdPrefix = exportEnvs(map[string]string{
+					fmt.Sprintf("%s_PROJECT", envprefix): sshcproject,
+					fmt.Sprintf("%s_VERSION", envprefix): specs.SSH_COMPOSE_VERSION,
+				})
+			}
+		}
+
+		// The sshd server accepts only the variables of the
+		// AcceptEnv option. The variables of the hook are
+		// exported through the command.
+		cmdPrefix += exportEnvs(opts.Envs)
 	}
 
-	// Disable stdin
-	session.Stdin = io.NopCloser(bytes.NewReader(nil))
-	session.Stdout = outBuffer
-	session.Stderr = errBuffer
+	if opts.Cwd != "" {
+		cmdPrefix += fmt.Sprintf("cd %s && ", helpers.ShellQuote(opts.Cwd))
+	}
 
 	runArgs := ""
-	if len(entryPoint) > 0 {
-		runArgs = strings.Join(entryPoint, " ")
-		runArgs += fmt.Sprintf(" '%s'", command)
+	if len(opts.Args) > 0 {
+		runArgs = cmdPrefix + helpers.ShellJoin(opts.Args)
+	} else if len(entryPoint) > 0 {
+		runArgs = helpers.ShellJoin(entryPoint) + " " +
+			helpers.ShellQuote(cmdPrefix+command)
 	} else {
-		runArgs = command
+		runArgs = cmdPrefix + command
 	}
 
 	ans := 0
-	err = session.Run(runArgs)
+	if opts.Become != nil {
+		e.Emitter.DebugLog(true, logger.Aurora.Bold(
+			logger.Aurora.BrightCyan(
+				fmt.Sprintf(">>> [%s] - become: %s", nodeName, opts.Become))))
+
+		err = e.runWithBecome(session, runArgs, outBuffer, errBuffer, opts)
+	} else {
+		// Stdin is disabled if not defined
+		session.Stdin = opts.GetStdin()
+		session.Stdout = outBuffer
+		session.Stderr = errBuffer
+
+		err = session.Run(runArgs)
+	}
 	if err != nil {
 		e.Emitter.InfoLog(true,
 			logger.Aurora.Bold(
@@ -96,12 +190,16 @@ func (e *SshCExecutor) RunCommandWithOutput(nodeName, command string, envs map[s
 }
 
 func (e *SshCExecutor) RunCommand(nodeName, command string, envs map[string]string, entryPoint []string) (int, error) {
+	return e.RunCommandWithOpts(nodeName, command, envs, entryPoint, NewCommandOpts())
+}
+
+func (e *SshCExecutor) RunCommandWithOpts(nodeName, command string, envs map[string]string, entryPoint []string, opts *CommandOpts) (int, error) {
 	var outBuffer, errBuffer bytes.Buffer
 	logger := log.GetDefaultLogger()
 
-	res, err := e.RunCommandWithOutput(nodeName, command, envs,
+	res, err := e.RunCommandWithOutputWithOpts(nodeName, command, envs,
 		helpers.NewNopCloseWriter(&outBuffer), helpers.NewNopCloseWriter(&errBuffer),
-		entryPoint)
+		entryPoint, opts)
 
 	if err == nil {
 
@@ -124,12 +222,17 @@ func (e *SshCExecutor) RunCommand(nodeName, command string, envs map[string]stri
 }
 
 func (e *SshCExecutor) RunCommandWithOutput4Var(nodeName, command, outVar, errVar string, envs *map[string]string, entryPoint []string) (int, error) {
+	return e.RunCommandWithOutput4VarWithOpts(nodeName, command, outVar, errVar,
+		envs, entryPoint, NewCommandOpts())
+}
+
+func (e *SshCExecutor) RunCommandWithOutput4VarWithOpts(nodeName, command, outVar, errVar string, envs *map[string]string, entryPoint []string, opts *CommandOpts) (int, error) {
 	var outBuffer, errBuffer bytes.Buffer
 	logger := log.GetDefaultLogger()
 
-	res, err := e.RunCommandWithOutput(nodeName, command, *envs,
+	res, err := e.RunCommandWithOutputWithOpts(nodeName, command, *envs,
 		helpers.NewNopCloseWriter(&outBuffer), helpers.NewNopCloseWriter(&errBuffer),
-		entryPoint)
+		entryPoint, opts)
 
 	if err == nil {
 
@@ -157,3 +260,21 @@ func (e *SshCExecutor) RunCommandWithOutput4Var(nodeName, command, outVar, errVa
 
 	return res, err
 }
+
+// exportEnvs returns the shell statements that export the
+// variables. The variables with an invalid name are skipped.
+func exportEnvs(envs map[string]string) string {
+	keys := []string{}
+	for k := range envs {
+		if helpers.IsValidShellVar(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	ans := ""
+	for _, k := range keys {
+		ans += fmt.Sprintf("export %s=%s; ", k, helpers.ShellQuote(envs[k]))
+	}
+	return ans
+}
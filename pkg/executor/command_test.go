@@ -0,0 +1,68 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor_test
+
+import (
+	"bytes"
+
+	. "github.com/MottainaiCI/ssh-compose/pkg/executor"
+	"github.com/MottainaiCI/ssh-compose/pkg/helpers"
+	log "github.com/MottainaiCI/ssh-compose/pkg/logger"
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Commands", func() {
+
+	Context("Variables of the hooks", func() {
+
+		run := func(envs map[string]string, opts *CommandOpts) string {
+			config := specs.NewSshComposeConfig(nil)
+			config.General.EnvSessionPrefix = "SSH_COMPOSE"
+			log.NewSshCLogger(config).SetAsDefault()
+
+			executor := newExecExecutor([]string{})
+			defer executor.Client.Close()
+
+			var outBuffer, errBuffer bytes.Buffer
+			res, err := executor.RunCommandWithOutputWithOpts("test",
+				`echo "$VAR1 $VAR2"`, envs,
+				helpers.NewNopCloseWriter(&outBuffer),
+				helpers.NewNopCloseWriter(&errBuffer),
+				[]string{}, opts)
+			Expect(err).Should(BeNil())
+			Expect(res).To(Equal(0))
+			return outBuffer.String()
+		}
+
+		It("Export the variables of the hook without project variables", func() {
+			opts := NewCommandOpts()
+			opts.EnvMode = specs.EnvModeJson
+			opts.Envs = map[string]string{"VAR1": "hook1"}
+
+			Expect(run(map[string]string{}, opts)).To(Equal("hook1 \n"))
+		})
+
+		It("Export the variables of the hook over the project variables", func() {
+			opts := NewCommandOpts()
+			opts.EnvMode = specs.EnvModeExport
+			opts.Envs = map[string]string{"VAR2": "hook2"}
+
+			Expect(run(map[string]string{"VAR1": "proj1", "VAR2": "proj2"}, opts)).To(
+				Equal("proj1 hook2\n"))
+		})
+
+		It("Skip the variables with the none mode", func() {
+			opts := NewCommandOpts()
+			opts.EnvMode = specs.EnvModeNone
+			opts.Envs = map[string]string{"VAR1": "hook1"}
+
+			Expect(run(map[string]string{"VAR2": "proj2"}, opts)).To(Equal(" \n"))
+		})
+	})
+
+})
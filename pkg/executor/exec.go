@@ -0,0 +1,82 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+// ExecCommand runs a command on a dedicated session without
+// logging it. It's used internally to run the support commands
+// needed by the file transfers. It returns the exit status of
+// the command.
+func (e *SshCExecutor) ExecCommand(command string, stdin io.Reader,
+	stdout, stderr io.Writer, become *BecomeOpts) (int, error) {
+
+	sid := uuid.New().String()
+
+	session, err := e.GetSession(sid)
+	if err != nil {
+		return 1, fmt.Errorf("error on get session: %s", err.Error())
+	}
+	defer e.RemoveSession(sid)
+
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	if become != nil {
+		opts := NewCommandOpts()
+		opts.Stdin = stdin
+		opts.Become = become
+		err = e.runWithBecome(session, command, stdout, stderr, opts)
+	} else {
+		if stdin == nil {
+			stdin = bytes.NewReader(nil)
+		}
+		session.Stdin = stdin
+		session.Stdout = stdout
+		session.Stderr = stderr
+
+		err = session.Run(command)
+	}
+
+	if err != nil {
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitStatus(), nil
+		}
+		return 1, err
+	}
+
+	return 0, nil
+}
+
+// ExecCommandOutput runs a command and returns the stdout. A not
+// zero exit status is returned as error with the stderr content.
+func (e *SshCExecutor) ExecCommandOutput(command string, become *BecomeOpts) (string, error) {
+	var outBuffer, errBuffer bytes.Buffer
+
+	res, err := e.ExecCommand(command, nil, &outBuffer, &errBuffer, become)
+	if err != nil {
+		return "", err
+	}
+
+	if res != 0 {
+		return outBuffer.String(), fmt.Errorf("command '%s' exited with %d: %s",
+			command, res, errBuffer.String())
+	}
+
+	return outBuffer.String(), nil
+}
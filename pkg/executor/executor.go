@@ -57,6 +57,9 @@ type SshCExecutor struct {
 	CiscoEnaPrompt string
 	CiscoEnaPass   string
 
+	// Password used by the become method
+	BecomePass string
+
 	TTYOpISpeed uint32
 	TTYOpOSpeed uint32
 
@@ -70,6 +73,18 @@ type SshCExecutor struct {
 	Client     *ssh.Client
 	SftpClient *sftp.Client
 
+	// Transfer method of the files: sftp|scp|exec. If empty
+	// it's used sftp with the fallback to exec.
+	Transport string
+
+	// SFTP client options
+	SftpMaxPacket             int
+	SftpMaxConcurrentRequests int
+	SftpConcurrentWrites      *bool
+	SftpConcurrentReads       *bool
+	SftpUseFstat              bool
+	SftpWorkers               int
+
 	Sessions map[string]*SshCSession
 
 	Emitter SshCExecutorEmitter
@@ -174,6 +189,7 @@ func NewSshCExecutorFromRemote(rname string, r *specs.Remote) (*SshCExecutor, er
 	ans.CiscoPrompt = r.CiscoPrompt
 	ans.CiscoEnaPrompt = r.CiscoEnaPrompt
 	ans.CiscoEnaPass = r.CiscoEnaPass
+	ans.BecomePass = r.BecomePass
 	if r.AuthMethod == specs.AuthMethodPassword {
 		ans.Pass = r.Pass
 	} else {
@@ -196,6 +212,18 @@ func NewSshCExecutorFromRemote(rname string, r *specs.Remote) (*SshCExecutor, er
 	ans.TunnelLocalBind = r.TunLocalBind
 	ans.TimeoutSecs = r.TimeoutSecs
 
+	if err := ValidateTransport(r.Transfer); err != nil {
+		return ans, err
+	}
+	ans.Transport = r.Transfer
+
+	ans.SftpMaxPacket = r.SftpMaxPacket
+	ans.SftpMaxConcurrentRequests = r.SftpMaxConcurrentRequests
+	ans.SftpConcurrentWrites = r.SftpConcurrentWrites
+	ans.SftpConcurrentReads = r.SftpConcurrentReads
+	ans.SftpUseFstat = r.SftpUseFstat
+	ans.SftpWorkers = r.SftpWorkers
+
 	if r.HasChain() {
 		for _, cr := range r.GetChain() {
 			tun, err := NewTunnelHop(&cr)
@@ -609,20 +637,65 @@ func (s *SshCExecutor) Setup() error {
 func (s *SshCExecutor) SetupSftp(opts ...sftp.ClientOption) error {
 	var client *sftp.Client
 	var err error
+
+	if s.Transport == TransportScp || s.Transport == TransportExec {
+		// POST: the files are transferred through exec sessions.
+		return nil
+	}
+
 	if s.SftpClient == nil {
 		client, err = sftp.NewClient(s.Client, opts...)
 		if err != nil {
-			return err
+			if s.Transport == TransportSftp {
+				return err
+			}
+			s.Emitter.WarnLog(false,
+				fmt.Sprintf("[%s] SFTP subsystem not available (%s). Using the exec transfer.",
+					s.Endpoint, err.Error()))
+			s.Transport = TransportExec
+			return nil
 		}
 		s.SftpClient = client
 	}
 	return nil
 }
 
+// GetSftpClientOptions returns the options of the SFTP client
+// defined by the remote.
+func (s *SshCExecutor) GetSftpClientOptions() []sftp.ClientOption {
+	ans := []sftp.ClientOption{}
+
+	if s.SftpMaxPacket > 0 {
+		if s.SftpMaxPacket > 32768 {
+			// The server could reject packets greater than 32KB.
+			ans = append(ans, sftp.MaxPacketUnchecked(s.SftpMaxPacket))
+		} else {
+			ans = append(ans, sftp.MaxPacketChecked(s.SftpMaxPacket))
+		}
+	}
+	if s.SftpMaxConcurrentRequests > 0 {
+		ans = append(ans,
+			sftp.MaxConcurrentRequestsPerFile(s.SftpMaxConcurrentRequests))
+	}
+	if s.SftpConcurrentWrites != nil {
+		ans = append(ans, sftp.UseConcurrentWrites(*s.SftpConcurrentWrites))
+	}
+	if s.SftpConcurrentReads != nil {
+		ans = append(ans, sftp.UseConcurrentReads(*s.SftpConcurrentReads))
+	}
+	if s.SftpUseFstat {
+		ans = append(ans, sftp.UseFstat(true))
+	}
+
+	return ans
+}
+
 func (e *SshCExecutor) GetEmitter() SshCExecutorEmitter        { return e.Emitter }
 func (e *SshCExecutor) SetEmitter(emitter SshCExecutorEmitter) { e.Emitter = emitter }
 func (s *SshCExecutor) GetClient() *ssh.Client                 { return s.Client }
 func (s *SshCExecutor) GetSftpClient() *sftp.Client            { return s.SftpClient }
+func (s *SshCExecutor) GetSftpWorkers() int                    { return s.SftpWorkers }
+func (s *SshCExecutor) GetTransport() string                   { return s.Transport }
 func (s *SshCExecutor) GetEndpoint() string                    { return s.Endpoint }
 func (s *SshCExecutor) GetHost() string                        { return s.Host }
 func (s *SshCExecutor) GetPort() int                           { return s.Port }
@@ -14,11 +14,21 @@ import (
 
 	helpers "github.com/MottainaiCI/ssh-compose/pkg/helpers"
 	log "github.com/MottainaiCI/ssh-compose/pkg/logger"
+	"github.com/MottainaiCI/ssh-compose/pkg/specs"
 )
 
 func (e *SshCExecutor) RunHostCommandWithOutput(command string, envs map[string]string, outBuffer, errBuffer io.WriteCloser, entryPoint []string) (int, error) {
+	return e.RunHostCommandWithOutputWithOpts(command, envs, outBuffer, errBuffer,
+		entryPoint, NewCommandOpts())
+}
+
+func (e *SshCExecutor) RunHostCommandWithOutputWithOpts(command string, envs map[string]string, outBuffer, errBuffer io.WriteCloser, entryPoint []string, opts *CommandOpts) (int, error) {
 	ans := 1
 
+	if opts == nil {
+		opts = NewCommandOpts()
+	}
+
 	entrypoint := []string{"/bin/bash", "-c"}
 	if len(e.Entrypoint) > 0 {
 		entrypoint = e.Entrypoint
@@ -36,6 +46,10 @@ func (e *SshCExecutor) RunHostCommandWithOutput(command string, envs map[string]
 	}
 
 	cmds := append(entrypoint, command)
+	if len(opts.Args) > 0 {
+		// The arguments are executed without shell.
+		cmds = opts.Args
+	}
 
 	hostCommand := exec.Command(cmds[0], cmds[1:]...)
 
@@ -51,17 +65,29 @@ func (e *SshCExecutor) RunHostCommandWithOutput(command string, envs map[string]
 
 	// Convert envs to array list
 	elist := os.Environ()
-	for k, v := range envs {
-		elist = append(elist, k+"="+v)
+	if opts.EnvMode != specs.EnvModeNone {
+		for k, v := range envs {
+			elist = append(elist, k+"="+v)
+		}
+		// The variables of the hook win over the project variables.
+		for k, v := range opts.Envs {
+			elist = append(elist, k+"="+v)
+		}
 	}
 
 	if e.ConfigDir != "" {
 		elist = append(elist, fmt.Sprintf("SSHC_CONF=%s", e.ConfigDir))
 	}
 
+	if opts.Stdin != nil {
+		hostCommand.Stdin = opts.Stdin
+	}
 	hostCommand.Stdout = outBuffer
 	hostCommand.Stderr = errBuffer
 	hostCommand.Env = elist
+	if opts.Cwd != "" {
+		hostCommand.Dir = opts.Cwd
+	}
 
 	err := hostCommand.Start()
 	if err != nil {
@@ -84,12 +110,16 @@ func (e *SshCExecutor) RunHostCommandWithOutput(command string, envs map[string]
 }
 
 func (e *SshCExecutor) RunHostCommand(command string, envs map[string]string, entryPoint []string) (int, error) {
+	return e.RunHostCommandWithOpts(command, envs, entryPoint, NewCommandOpts())
+}
+
+func (e *SshCExecutor) RunHostCommandWithOpts(command string, envs map[string]string, entryPoint []string, opts *CommandOpts) (int, error) {
 	var outBuffer, errBuffer bytes.Buffer
 	logger := log.GetDefaultLogger()
 
-	res, err := e.RunHostCommandWithOutput(command, envs,
+	res, err := e.RunHostCommandWithOutputWithOpts(command, envs,
 		helpers.NewNopCloseWriter(&outBuffer), helpers.NewNopCloseWriter(&errBuffer),
-		entryPoint)
+		entryPoint, opts)
 
 	if e.ShowCmdsOutput && len(outBuffer.String()) > 0 {
 		e.Emitter.InfoLog(false,
@@ -109,12 +139,17 @@ func (e *SshCExecutor) RunHostCommand(command string, envs map[string]string, en
 }
 
 func (e *SshCExecutor) RunHostCommandWithOutput4Var(command, outVar, errVar string, envs *map[string]string, entryPoint []string) (int, error) {
+	return e.RunHostCommandWithOutput4VarWithOpts(command, outVar, errVar, envs,
+		entryPoint, NewCommandOpts())
+}
+
+func (e *SshCExecutor) RunHostCommandWithOutput4VarWithOpts(command, outVar, errVar string, envs *map[string]string, entryPoint []string, opts *CommandOpts) (int, error) {
 	var outBuffer, errBuffer bytes.Buffer
 	logger := log.GetDefaultLogger()
 
-	res, err := e.RunHostCommandWithOutput(command, *envs,
+	res, err := e.RunHostCommandWithOutputWithOpts(command, *envs,
 		helpers.NewNopCloseWriter(&outBuffer), helpers.NewNopCloseWriter(&errBuffer),
-		entryPoint)
+		entryPoint, opts)
 
 	if err == nil {
 
@@ -5,6 +5,9 @@ See AUTHORS and LICENSE for the license details and contributors.
 package executor
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -13,8 +16,13 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/MottainaiCI/ssh-compose/pkg/helpers"
 	log "github.com/MottainaiCI/ssh-compose/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/pkg/sftp"
 )
 
 func (s *SshCExecutor) RecursiveMkdir(dir string, mode *os.FileMode, uid int, gid int, ensurePerms bool) error {
@@ -93,8 +101,459 @@ func (s *SshCExecutor) RecursiveMkdir(dir string, mode *os.FileMode, uid int, gi
 	return nil
 }
 
+// UploadContent writes the in memory content to the remote path
+// with the specified mode.
+func (s *SshCExecutor) UploadContent(targetPath string, content []byte, mode os.FileMode) error {
+	if s.useExecTransport() {
+		return s.uploadContentExec(targetPath, content, mode)
+	}
+	if s.SftpClient == nil {
+		return fmt.Errorf("Sftp client not initialized.")
+	}
+
+	uploadPath := getUploadTmpPath(targetPath)
+	dstFile, err := s.SftpClient.Create(uploadPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = dstFile.Write(content)
+	if err == nil {
+		dstFile.Sync()
+		err = dstFile.Close()
+	} else {
+		dstFile.Close()
+	}
+	if err == nil {
+		err = s.SftpClient.Chmod(uploadPath, mode)
+	}
+	if err != nil {
+		s.SftpClient.Remove(uploadPath)
+		return err
+	}
+
+	return s.renameUpload(uploadPath, targetPath)
+}
+
+// UploadContentWithOpts writes the in memory content to the remote
+// path creating the missing directories and applying the ownership
+// and the file mode of the options (0644 by default). With become or
+// without the SFTP client the content is streamed to an exec session.
+// The become methods with a pseudo terminal copy the content from a
+// staging file uploaded as the login user.
+func (s *SshCExecutor) UploadContentWithOpts(nodeName, targetPath string,
+	content []byte, opts *SyncOpts) error {
+
+	if opts == nil {
+		opts = NewSyncOpts()
+	}
+
+	if opts.perms == nil {
+		perms, err := s.resolvePerms(opts, true)
+		if err != nil {
+			return err
+		}
+		opts.perms = perms
+	}
+
+	mode := os.FileMode(0644)
+	if opts.perms.fileMode != nil {
+		mode = *opts.perms.fileMode
+	}
+
+	s.Emitter.DebugLog(false,
+		fmt.Sprintf("[%s] Writing %s (%d bytes, %o)", nodeName, targetPath,
+			len(content), mode))
+
+	if opts.Become != nil && opts.Become.UsePty() {
+		err := s.uploadContentWithStaging(targetPath, content, mode, opts)
+		if err != nil {
+			return err
+		}
+	} else if opts.Become != nil || !s.UseSftp() {
+		uploadPath := getUploadTmpPath(targetPath)
+
+		cmd := fmt.Sprintf("mkdir -p %s && cat > %s && chmod %o %s",
+			helpers.ShellQuote(path.Dir(targetPath)),
+			helpers.ShellQuote(uploadPath),
+			mode.Perm(), helpers.ShellQuote(uploadPath),
+		)
+		if spec := ownerSpec(opts.perms.uid, opts.perms.gid); spec != "" {
+			cmd += fmt.Sprintf(" && chown %s %s", spec, helpers.ShellQuote(uploadPath))
+		}
+		cmd += fmt.Sprintf(" && mv -f %s %s || { rm -f %s; exit 1; }",
+			helpers.ShellQuote(uploadPath), helpers.ShellQuote(targetPath),
+			helpers.ShellQuote(uploadPath))
+
+		var errBuffer bytes.Buffer
+		res, err := s.ExecCommand(cmd, bytes.NewReader(content), nil, &errBuffer, opts.Become)
+		if err != nil {
+			return fmt.Errorf("error on upload %s: %s", targetPath, err.Error())
+		}
+		if res != 0 {
+			return fmt.Errorf("error on upload %s (%d): %s",
+				targetPath, res, errBuffer.String())
+		}
+	} else {
+		err := s.SftpClient.MkdirAll(path.Dir(targetPath))
+		if err != nil {
+			return fmt.Errorf("error on create directory %s: %s",
+				path.Dir(targetPath), err.Error())
+		}
+
+		err = s.UploadContent(targetPath, content, mode)
+		if err != nil {
+			return fmt.Errorf("error on upload %s: %s", targetPath, err.Error())
+		}
+
+		if opts.perms.HasOwnership() {
+			perms := *opts.perms
+			// The mode is already applied by the upload.
+			perms.fileMode = nil
+			if err := s.applyRemotePerms(targetPath, false, &perms); err != nil {
+				return err
+			}
+		}
+	}
+
+	opts.Stats.AddTransferred(int64(len(content)))
+
+	return nil
+}
+
+// uploadContentWithStaging uploads the content in a staging file as
+// the login user and copies it to the target through the become method.
+// The become methods with a pseudo terminal don't permit to send the
+// content through the stdin.
+func (s *SshCExecutor) uploadContentWithStaging(targetPath string, content []byte,
+	mode os.FileMode, opts *SyncOpts) error {
+
+	// The staging directory isn't listable by the other users and
+	// the file has a random name, so it's reachable only from the
+	// become user that could be not the owner of the file.
+	stagingDir, err := s.ExecCommandOutput(
+		"d=$(mktemp -d) && chmod 711 \"$d\" && echo \"$d\"", nil)
+	if err != nil {
+		return fmt.Errorf("error on create staging directory: %s", err.Error())
+	}
+	stagingDir = strings.TrimSpace(stagingDir)
+	defer s.ExecCommand(fmt.Sprintf("rm -rf %s", helpers.ShellQuote(stagingDir)),
+		nil, nil, nil, nil)
+
+	stagingPath := path.Join(stagingDir, uuid.New().String())
+
+	var errBuffer bytes.Buffer
+	res, err := s.ExecCommand(
+		fmt.Sprintf("cat > %s && chmod 644 %s",
+			helpers.ShellQuote(stagingPath), helpers.ShellQuote(stagingPath)),
+		bytes.NewReader(content), nil, &errBuffer, nil)
+	if err != nil {
+		return fmt.Errorf("error on upload %s: %s", stagingPath, err.Error())
+	}
+	if res != 0 {
+		return fmt.Errorf("error on upload %s (%d): %s",
+			stagingPath, res, errBuffer.String())
+	}
+
+	uploadPath := getUploadTmpPath(targetPath)
+
+	cmd := fmt.Sprintf("mkdir -p %s && cp %s %s && chmod %o %s",
+		helpers.ShellQuote(path.Dir(targetPath)),
+		helpers.ShellQuote(stagingPath), helpers.ShellQuote(uploadPath),
+		mode.Perm(), helpers.ShellQuote(uploadPath),
+	)
+	if spec := ownerSpec(opts.perms.uid, opts.perms.gid); spec != "" {
+		cmd += fmt.Sprintf(" && chown %s %s", spec, helpers.ShellQuote(uploadPath))
+	}
+	cmd += fmt.Sprintf(" && mv -f %s %s || { rm -f %s; exit 1; }",
+		helpers.ShellQuote(uploadPath), helpers.ShellQuote(targetPath),
+		helpers.ShellQuote(uploadPath))
+
+	_, err = s.ExecCommandOutput(cmd, opts.Become)
+	if err != nil {
+		return fmt.Errorf("error on upload %s: %s", targetPath, err.Error())
+	}
+
+	return nil
+}
+
+// getUploadTmpPath returns the temporary path in the same directory
+// of the target used to upload the file before the rename.
+func getUploadTmpPath(targetPath string) string {
+	return path.Join(path.Dir(targetPath),
+		fmt.Sprintf(".%s.sshc-%s", path.Base(targetPath), uuid.New().String()[:8]))
+}
+
+// ReadFileContent returns the content of the remote file and
+// false if the file doesn't exist. With become or without the
+// SFTP client the file is read through the cat command.
+func (s *SshCExecutor) ReadFileContent(remotePath string, become *BecomeOpts) ([]byte, bool, error) {
+	if become != nil || s.SftpClient == nil {
+		var outBuffer, errBuffer bytes.Buffer
+
+		q := helpers.ShellQuote(remotePath)
+		res, err := s.ExecCommand(
+			fmt.Sprintf("test -e %s || exit 3; cat %s", q, q),
+			nil, &outBuffer, &errBuffer, become)
+		if err != nil {
+			return nil, false, err
+		}
+		if res == 3 {
+			return nil, false, nil
+		}
+		if res != 0 {
+			return nil, false, fmt.Errorf("error on read %s: %s",
+				remotePath, strings.TrimSpace(errBuffer.String()))
+		}
+		return outBuffer.Bytes(), true, nil
+	}
+
+	f, err := s.SftpClient.Open(remotePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+// commitUpload replaces the target file with the uploaded file. The
+// modes of the replaced file are maintained and if the backup is
+// enabled the previous version is kept with the backup suffix.
+func (s *SshCExecutor) commitUpload(uploadPath, targetPath string, opts *SyncOpts) error {
+	fi, err := s.SftpClient.Lstat(targetPath)
+	if err == nil && fi.Mode().IsRegular() {
+		if !opts.EnsurePerms {
+			_ = s.SftpClient.Chmod(uploadPath, fi.Mode().Perm())
+			if stat, ok := fi.Sys().(*sftp.FileStat); ok {
+				_ = s.SftpClient.Chown(uploadPath, int(stat.UID), int(stat.GID))
+			}
+		}
+
+		if opts.Backup {
+			backupPath := targetPath + opts.backupSuffix
+			// Never overwrite an existing backup.
+			if _, serr := s.SftpClient.Lstat(backupPath); serr == nil {
+				s.SftpClient.Remove(uploadPath)
+				return fmt.Errorf("error on backup %s: %s already exists",
+					targetPath, backupPath)
+			}
+			// Using an hardlink the target path is always available.
+			err = s.SftpClient.Link(targetPath, backupPath)
+			if err != nil {
+				err = s.SftpClient.Rename(targetPath, backupPath)
+			}
+			if err != nil {
+				s.SftpClient.Remove(uploadPath)
+				return fmt.Errorf("error on backup %s: %s", targetPath, err.Error())
+			}
+		}
+	}
+
+	return s.renameUpload(uploadPath, targetPath)
+}
+
+func (s *SshCExecutor) renameUpload(uploadPath, targetPath string) error {
+	err := s.SftpClient.PosixRename(uploadPath, targetPath)
+	if err != nil {
+		// POST: the server doesn't support the posix-rename extension.
+		if _, serr := s.SftpClient.Lstat(targetPath); serr == nil {
+			_ = s.SftpClient.Remove(targetPath)
+		}
+		err = s.SftpClient.Rename(uploadPath, targetPath)
+	}
+	if err != nil {
+		s.SftpClient.Remove(uploadPath)
+		return fmt.Errorf("error on rename %s: %s", uploadPath, err.Error())
+	}
+
+	return nil
+}
+
+func (s *SshCExecutor) RemoveFile(targetPath string) error {
+	if s.useExecTransport() {
+		_, err := s.ExecCommandOutput(
+			fmt.Sprintf("rm -f %s", helpers.ShellQuote(targetPath)), nil)
+		return err
+	}
+	if s.SftpClient == nil {
+		return fmt.Errorf("Sftp client not initialized.")
+	}
+
+	return s.SftpClient.Remove(targetPath)
+}
+
 func (s *SshCExecutor) RecursivePushFile(nodeName, source, target string, ensurePerms bool) error {
+	opts := NewSyncOpts()
+	opts.EnsurePerms = ensurePerms
+	return s.RecursivePushFileWithOpts(nodeName, source, target, opts)
+}
+
+// GetPushTargets returns the remote paths of the local files
+// pushed from the source to the target with the same rules of the
+// push: the .sshcignore file, the exclude/include patterns and the
+// tar rules when the files are sent with a tar stream. The files
+// skipped by the push aren't returned.
+func (s *SshCExecutor) GetPushTargets(source, target string, files []string,
+	opts *SyncOpts) (map[string]string, error) {
+	ans := make(map[string]string, 0)
+
+	if opts == nil {
+		opts = NewSyncOpts()
+	}
+
+	sourceRoot := filepath.Clean(source)
+	fi, err := os.Stat(sourceRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ans, nil
+		}
+		return ans, err
+	}
+
+	tarRules := opts.TarRules
+	if !s.useTarStream(opts) {
+		tarRules = nil
+	}
+	if tarRules != nil {
+		if err := tarRules.Prepare(); err != nil {
+			return ans, fmt.Errorf("error on prepare tar rules: %s", err.Error())
+		}
+	}
+
+	if !fi.IsDir() {
+		targetDir := path.Dir(path.Clean(target))
+		name := path.Base(target)
+		if strings.HasSuffix(target, "/") {
+			targetDir = path.Clean(target)
+			name = filepath.Base(sourceRoot)
+		}
+
+		for _, f := range files {
+			if filepath.Clean(f) != sourceRoot {
+				continue
+			}
+			if tarRules != nil {
+				if tarRules.IsPath2Skip(name) {
+					break
+				}
+				name = tarRules.GetRename(name)
+			}
+			ans[path.Join(targetDir, name)] = f
+			break
+		}
+
+		return ans, nil
+	}
+
+	if opts.rules == nil {
+		if err := opts.loadIgnoreRules(sourceRoot); err != nil {
+			return ans, err
+		}
+	}
+
+	// skipped returns true if the path is skipped by the push,
+	// the directories also with all their children.
+	skipped := func(rel string, isDir bool) bool {
+		if opts.isIgnored(rel, isDir) {
+			return true
+		}
+		return tarRules != nil && tarRules.IsPath2Skip(rel)
+	}
+
+	for _, f := range files {
+		rel, err := filepath.Rel(sourceRoot, filepath.Clean(f))
+		if err != nil || rel == "." || rel == ".." ||
+			strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		skip := false
+		parts := strings.Split(rel, "/")
+		for idx := 1; idx < len(parts) && !skip; idx++ {
+			skip = skipped(strings.Join(parts[:idx], "/"), true)
+		}
+		if skip || skipped(rel, false) {
+			continue
+		}
+
+		name := rel
+		if tarRules != nil {
+			name = tarRules.GetRename(rel)
+		}
+		ans[path.Join(target, name)] = f
+	}
+
+	return ans, nil
+}
+
+func (s *SshCExecutor) RecursivePushFileWithOpts(nodeName, source, target string, opts *SyncOpts) error {
+	if opts == nil {
+		opts = NewSyncOpts()
+	}
+	if opts.Stats == nil {
+		opts.Stats = &SyncStats{}
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	if opts.Delete && opts.mirror == nil {
+		opts.mirror = &mirrorState{
+			root:  "",
+			paths: make(map[string]bool, 0),
+		}
+	}
+
+	if opts.rules == nil {
+		if err := opts.loadIgnoreRules(source); err != nil {
+			return err
+		}
+	}
+
+	if opts.Backup && opts.backupSuffix == "" {
+		opts.backupSuffix = getBackupSuffix(time.Now())
+	}
+
+	if opts.Progress && opts.progress == nil {
+		total, err := opts.localTreeSize(source)
+		if err != nil {
+			return err
+		}
+		opts.progress = newTransferProgress(nodeName, target, total, s.Emitter)
+		defer opts.progress.Done()
+	}
+
+	if opts.perms == nil {
+		perms, err := s.resolvePerms(opts, true)
+		if err != nil {
+			return err
+		}
+		opts.perms = perms
+	}
 
+	if s.useExecTransport() {
+		return s.recursivePushFileWithExec(nodeName, source, target, opts)
+	}
+
+	if opts.Mode == TransferModeTar {
+		return s.recursivePushFileWithTar(nodeName, source, target, opts)
+	}
+
+	if opts.Become != nil {
+		return s.recursivePushFileWithBecome(nodeName, source, target, opts)
+	}
+
+	ensurePerms := opts.EnsurePerms
 	var targetIsFile bool = true
 	var sourceIsFile bool = true
 	var uid, gid int
@@ -134,12 +593,68 @@ func (s *SshCExecutor) RecursivePushFile(nodeName, source, target string, ensure
 		return errors.New("Error on create dir " + filepath.Dir(target) + ": " + err.Error())
 	}
 
+	var pool *workerPool
+	logger := log.GetDefaultLogger()
+
+	// finalizeFile applies the permissions to the pushed file.
+	finalizeFile := func(p, targetPath, ftype string, fInfo os.FileInfo, uid, gid int) error {
+		if ensurePerms {
+			err := s.SftpClient.Chmod(targetPath, mode)
+			if err != nil {
+				return err
+			}
+
+			err = s.SftpClient.Chown(targetPath, uid, gid)
+			if err != nil {
+				return err
+			}
+		}
+
+		if ftype != "symlink" {
+			err := s.applyRemotePerms(targetPath, fInfo.IsDir(), opts.perms)
+			if err != nil {
+				return err
+			}
+		}
+
+		if logger.Config.GetGeneral().Debug {
+			s.Emitter.InfoLog(true,
+				logger.Aurora.Italic(
+					logger.Aurora.BrightMagenta(
+						fmt.Sprintf(">>> [%s] Pushing %s -> %s (%s)",
+							nodeName, p, targetPath, ftype))))
+		}
+
+		return nil
+	}
+
+	pushRegularFile := func(p, targetPath string, fInfo os.FileInfo, uid, gid int) error {
+		skipped, err := s.uploadFile(nodeName, p, targetPath, fInfo, opts)
+		if err != nil || skipped {
+			return err
+		}
+		return finalizeFile(p, targetPath, "file", fInfo, uid, gid)
+	}
+
+	sourceRoot := filepath.Clean(source)
 	sendFile := func(p string, fInfo os.FileInfo, err error) error {
 
 		if err != nil {
 			return fmt.Errorf("failed to walk path for %s: %s", p, err)
 		}
 
+		if filepath.Clean(p) != sourceRoot {
+			rel, _ := filepath.Rel(sourceRoot, p)
+			if opts.isIgnored(filepath.ToSlash(rel), fInfo.IsDir()) {
+				s.Emitter.DebugLog(false,
+					fmt.Sprintf("[%s] Excluding %s", nodeName, p))
+				if fInfo.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
 		// Detect unsupported files
 		if !fInfo.Mode().IsRegular() && !fInfo.Mode().IsDir() && fInfo.Mode()&os.ModeSymlink != os.ModeSymlink {
 			return fmt.Errorf("'%s' isn't a supported file type", p)
@@ -159,12 +674,18 @@ func (s *SshCExecutor) RecursivePushFile(nodeName, source, target string, ensure
 			if targetIsFile && sourceIsFile {
 				targetPath = target
 			} else if targetIsFile && !sourceIsFile {
+				opts.trackPath(target, true)
+				if err := s.applyRemotePerms(target, true, opts.perms); err != nil {
+					return err
+				}
 				// Nothing to do. The directory is already been created.
 				s.Emitter.DebugLog(false, fmt.Sprintf("Skipping dir %s. Already created.", p))
 				return nil
 			}
 		}
 
+		opts.trackPath(targetPath, p == source && fInfo.IsDir())
+
 		if stat, ok := fInfo.Sys().(*syscall.Stat_t); ok {
 			uid = int(stat.Uid)
 			gid = int(stat.Gid)
@@ -174,8 +695,6 @@ func (s *SshCExecutor) RecursivePushFile(nodeName, source, target string, ensure
 			gid = os.Getgid()
 		}
 
-		logger := log.GetDefaultLogger()
-
 		ftype := "file"
 		if fInfo.IsDir() {
 			// Directory handling
@@ -192,97 +711,356 @@ func (s *SshCExecutor) RecursivePushFile(nodeName, source, target string, ensure
 				return err
 			}
 
+			if opts.Compare != "" {
+				// Check if the link is already present.
+				remoteTarget, err := s.SftpClient.ReadLink(opts.getComparePath(targetPath))
+				if err == nil && remoteTarget == symlinkTarget {
+					opts.Stats.AddSkipped()
+					s.Emitter.DebugLog(false,
+						fmt.Sprintf("[%s] Skipping unchanged %s", nodeName, p))
+					return nil
+				} else if err == nil {
+					_ = s.SftpClient.Remove(targetPath)
+				}
+			}
+
 			err = s.SftpClient.Symlink(symlinkTarget, targetPath)
 			if err != nil {
 				return err
 			}
 
 			ftype = "symlink"
+			opts.Stats.AddTransferred(0)
 		} else {
-			// Open local file for reading data
-			f, err := os.Open(p)
-			if err != nil {
-				return err
+			// Regular file handling
+			fileUid, fileGid := uid, gid
+			if pool != nil {
+				return pool.Add(func() error {
+					return pushRegularFile(p, targetPath, fInfo, fileUid, fileGid)
+				})
 			}
-			defer f.Close()
+			return pushRegularFile(p, targetPath, fInfo, fileUid, fileGid)
+		}
 
-			// Open target file for writing data
-			dstFile, err := s.SftpClient.Create(targetPath)
-			if err != nil {
-				return err
-			}
+		return finalizeFile(p, targetPath, ftype, fInfo, uid, gid)
+	}
 
-			_, err = io.Copy(dstFile, f)
-			if err != nil {
-				dstFile.Close()
-				return err
-			}
+	if opts.Workers > 1 {
+		pool = newWorkerPool(opts.Workers)
+	}
+
+	err = filepath.Walk(source, sendFile)
+	if pool != nil {
+		// Wait the end of the running transfers also on error.
+		perr := pool.Wait()
+		if err == nil {
+			err = perr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.Delete {
+		return s.mirrorDelete(nodeName, opts)
+	}
 
-			dstFile.Sync()
+	return nil
+}
+
+// uploadFile uploads a regular file to the target path. It returns
+// true if the file is skipped because unchanged.
+func (s *SshCExecutor) uploadFile(nodeName, p, targetPath string, fInfo os.FileInfo,
+	opts *SyncOpts) (bool, error) {
+	if opts.Compare != "" {
+		changed, err := s.isFileChanged(p, fInfo,
+			opts.getComparePath(targetPath), opts)
+		if err != nil {
+			return false, err
+		}
+		if !changed {
+			opts.Stats.AddSkipped()
+			opts.progress.Skip(fInfo.Size())
+			s.Emitter.DebugLog(false,
+				fmt.Sprintf("[%s] Skipping unchanged %s", nodeName, p))
+			return true, nil
+		}
+	}
+
+	// Open local file for reading data
+	f, err := os.Open(p)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	// Open a temporary target file for writing data. The file
+	// is renamed only after a complete write to avoid truncated
+	// files on the target path.
+	uploadPath := getUploadTmpPath(targetPath)
+	hasher := sha256.New()
+	resumable := opts.isResumable(fInfo.Size())
+	var offset int64
+	var dstFile *sftp.File
+
+	if resumable {
+		// The large files are uploaded in a partial file
+		// kept on failure to resume the next upload.
+		uploadPath = getResumePath(targetPath)
+		offset = s.remoteResumeOffset(f, hasher, uploadPath, fInfo.Size())
+	}
+
+	if offset > 0 {
+		s.Emitter.DebugLog(false,
+			fmt.Sprintf("[%s] Resuming upload of %s from %d bytes", nodeName, p, offset))
+		dstFile, err = s.SftpClient.OpenFile(uploadPath, os.O_WRONLY)
+		if err == nil {
+			_, err = dstFile.Seek(offset, io.SeekStart)
+		}
+		opts.progress.Skip(offset)
+	} else {
+		dstFile, err = s.SftpClient.Create(uploadPath)
+	}
+	if err != nil {
+		if dstFile != nil {
 			dstFile.Close()
+		}
+		return false, err
+	}
 
-			// Check if the file is been created correctly.
-			// I catch a weird behavior that the file is been
-			// copied by later is not present
+	_, err = io.Copy(io.MultiWriter(dstFile, hasher),
+		opts.progress.Reader(p, fInfo.Size()-offset, f))
+	opts.progress.FileDone(p)
+	if err != nil {
+		dstFile.Close()
+		if !resumable {
+			s.SftpClient.Remove(uploadPath)
+		}
+		return false, err
+	}
 
-			fi, err := s.SftpClient.Stat(targetPath)
-			if fi == nil || err != nil {
-				return fmt.Errorf("File %s not copied correctly.",
-					targetPath,
-				)
-			}
+	dstFile.Sync()
+	err = dstFile.Close()
+	if err != nil {
+		if !resumable {
+			s.SftpClient.Remove(uploadPath)
+		}
+		return false, err
+	}
+
+	// Check if the file is been created correctly.
+	// I catch a weird behavior that the file is been
+	// copied by later is not present
+
+	fi, err := s.SftpClient.Stat(uploadPath)
+	if fi == nil || err != nil || fi.Size() != fInfo.Size() {
+		s.SftpClient.Remove(uploadPath)
+		return false, fmt.Errorf("File %s not copied correctly.",
+			targetPath,
+		)
+	}
 
+	if opts.Verify {
+		err = s.verifyChecksum(hex.EncodeToString(hasher.Sum(nil)), uploadPath)
+		if err != nil {
+			s.SftpClient.Remove(uploadPath)
+			return false, fmt.Errorf("error on verify %s: %s", p, err.Error())
 		}
+	}
 
-		if ensurePerms {
-			err = s.SftpClient.Chmod(targetPath, mode)
+	err = s.commitUpload(uploadPath, targetPath, opts)
+	if err != nil {
+		return false, err
+	}
+
+	if opts.Compare != "" {
+		// Align the modification time used by the
+		// next comparisons.
+		err = s.SftpClient.Chtimes(targetPath, fInfo.ModTime(), fInfo.ModTime())
+		if err != nil {
+			return false, err
+		}
+	}
+
+	opts.Stats.AddTransferred(fInfo.Size() - offset)
+
+	return false, nil
+}
+
+func (s *SshCExecutor) RecursivePullFile(nodeName, sourcePath, targetPath string, localAsTarget, ensurePerms bool) error {
+	opts := NewSyncOpts()
+	opts.EnsurePerms = ensurePerms
+	return s.RecursivePullFileWithOpts(nodeName, sourcePath, targetPath, localAsTarget, opts)
+}
+
+func (s *SshCExecutor) RecursivePullFileWithOpts(nodeName, sourcePath, targetPath string,
+	localAsTarget bool, opts *SyncOpts) error {
+	if opts == nil {
+		opts = NewSyncOpts()
+	}
+	if opts.Stats == nil {
+		opts.Stats = &SyncStats{}
+	}
+
+	if s.SftpClient == nil && !s.useExecTransport() {
+		return fmt.Errorf("Sftp client not initialized.")
+	}
+
+	perms, err := s.resolvePerms(opts, false)
+	if err != nil {
+		return err
+	}
+	opts.perms = perms
+
+	opts.filters, err = opts.resolveFilters()
+	if err != nil {
+		return err
+	}
+
+	if helpers.HasGlobMeta(sourcePath) {
+		return s.pullGlob(nodeName, sourcePath, targetPath, localAsTarget, opts)
+	}
+
+	var ignoreContent []byte
+	ignoreFile := path.Join(sourcePath, SshcIgnoreFile)
+	if s.useExecTransport() {
+		ignoreContent = s.readRemoteFileExec(ignoreFile, opts.Become)
+	} else if fi, err := s.SftpClient.Stat(ignoreFile); err == nil && fi.Mode().IsRegular() {
+		f, err := s.SftpClient.Open(ignoreFile)
+		if err != nil {
+			return err
+		}
+		ignoreContent, err = io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := opts.buildIgnoreRules(ignoreContent); err != nil {
+		return err
+	}
+
+	if opts.Progress && opts.progress == nil {
+		var total int64
+		if !s.useExecTransport() {
+			total, err = s.remoteTreeSize(sourcePath, opts)
 			if err != nil {
 				return err
 			}
+		}
+		opts.progress = newTransferProgress(nodeName, sourcePath, total, s.Emitter)
+		defer opts.progress.Done()
+	}
 
-			err = s.SftpClient.Chown(targetPath, uid, gid)
+	if s.useExecTransport() {
+		return s.recursivePullFileWithExec(nodeName, sourcePath, targetPath,
+			localAsTarget, opts)
+	}
+
+	return s.recursivePullFile(nodeName, path.Clean(sourcePath), sourcePath,
+		targetPath, localAsTarget, opts)
+}
+
+// pullGlob pulls the remote files matching the glob pattern. The
+// matched files are stored under the target directory.
+func (s *SshCExecutor) pullGlob(nodeName, pattern, targetPath string,
+	localAsTarget bool, opts *SyncOpts) error {
+
+	if s.SftpClient == nil {
+		return fmt.Errorf("glob patterns require the sftp transfer")
+	}
+
+	matches, err := s.SftpClient.Glob(path.Clean(pattern))
+	if err != nil {
+		return fmt.Errorf("error on expand %s: %s", pattern, err.Error())
+	}
+
+	if len(matches) == 0 {
+		s.Emitter.InfoLog(false,
+			fmt.Sprintf(">>> [%s] No files matching %s", nodeName, pattern))
+		return nil
+	}
+
+	// The patterns are applied relatively to every match.
+	if err := opts.buildIgnoreRules(nil); err != nil {
+		return err
+	}
+
+	if opts.Progress && opts.progress == nil {
+		var total int64
+		for _, m := range matches {
+			size, err := s.remoteTreeSize(m, opts)
 			if err != nil {
 				return err
 			}
+			total += size
 		}
+		opts.progress = newTransferProgress(nodeName, pattern, total, s.Emitter)
+		defer opts.progress.Done()
+	}
 
-		if logger.Config.GetGeneral().Debug {
-			s.Emitter.InfoLog(true,
-				logger.Aurora.Italic(
-					logger.Aurora.BrightMagenta(
-						fmt.Sprintf(">>> [%s] Pushing %s -> %s (%s)",
-							nodeName, p, targetPath, ftype))))
+	for _, m := range matches {
+		target := filepath.Join(targetPath, path.Base(m))
+		if !localAsTarget {
+			target = filepath.Join(targetPath, m)
 		}
 
-		return nil
+		err := os.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err
+		}
+
+		err = s.recursivePullFile(nodeName, m, m, target, true, opts)
+		if err != nil {
+			return err
+		}
 	}
 
-	return filepath.Walk(source, sendFile)
+	return nil
 }
 
-func (s *SshCExecutor) RecursivePullFile(nodeName, sourcePath, targetPath string, localAsTarget, ensurePerms bool) error {
+func (s *SshCExecutor) recursivePullFile(nodeName, sourceRoot, sourcePath, targetPath string,
+	localAsTarget bool, opts *SyncOpts) error {
 	var err error
 	var ftype string
 	var uid, gid int
 	var mode os.FileMode
 
+	ensurePerms := opts.EnsurePerms
+
 	// Retrieve the information of the remote source directory
 	fi, err := s.SftpClient.Stat(sourcePath)
 	if err != nil {
 		return err
 	}
 
+	if path.Clean(sourcePath) != sourceRoot {
+		rel := strings.TrimPrefix(path.Clean(sourcePath), sourceRoot+"/")
+		if opts.isIgnored(rel, fi.IsDir()) {
+			s.Emitter.DebugLog(false,
+				fmt.Sprintf("[%s] Excluding %s", nodeName, sourcePath))
+			return nil
+		}
+	}
+
 	ftype = "file"
 	if fi.IsDir() {
 		ftype = "directory"
 	} else if fi.Mode()&os.ModeSymlink != 0 {
 		ftype = "symlink"
+	} else if opts.filters.Skip(fi.Size(), fi.ModTime()) {
+		s.Emitter.DebugLog(false,
+			fmt.Sprintf("[%s] Skipping filtered %s", nodeName, sourcePath))
+		opts.Stats.AddSkipped()
+		opts.progress.Skip(fi.Size())
+		return nil
 	}
 	mode = fi.Mode()
 	if ensurePerms {
-		if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
-			uid = int(stat.Uid)
-			gid = int(stat.Gid)
+		if stat, ok := fi.Sys().(*sftp.FileStat); ok {
+			uid = int(stat.UID)
+			gid = int(stat.GID)
 		} else {
 			// Using os uid/gid
 			uid = os.Getuid()
@@ -323,6 +1101,11 @@ func (s *SshCExecutor) RecursivePullFile(nodeName, sourcePath, targetPath string
 			}
 		}
 
+		err = applyLocalPerms(target, true, opts.perms)
+		if err != nil {
+			return err
+		}
+
 		// Retrieve the list of the entries of the source directory
 		entries, err := s.SftpClient.ReadDir(sourcePath)
 		if err != nil {
@@ -332,21 +1115,59 @@ func (s *SshCExecutor) RecursivePullFile(nodeName, sourcePath, targetPath string
 		for _, ent := range entries {
 			nextP := path.Join(sourcePath, ent.Name())
 			nextT := path.Join(target, ent.Name())
-			err = s.RecursivePullFile(nodeName, nextP, nextT, true, ensurePerms)
+			err = s.recursivePullFile(nodeName, sourceRoot, nextP, nextT, true, opts)
 			if err != nil {
 				return err
 			}
 		}
 	} else if ftype == "file" {
 
+		// The large files are downloaded in a partial file
+		// kept on failure to resume the next download.
+		writePath := target
+		hasher := sha256.New()
+		resumable := opts.isResumable(fi.Size())
+		var offset int64
+		var f *os.File
+
+		if resumable {
+			writePath = getLocalResumePath(target)
+			offset = s.localResumeOffset(writePath, sourcePath, hasher, fi.Size())
+		}
+
 		// Open the local file
-		f, err := os.Create(target)
+		if offset > 0 {
+			s.Emitter.DebugLog(false,
+				fmt.Sprintf("[%s] Resuming download of %s from %d bytes",
+					nodeName, sourcePath, offset))
+			// The modes of the partial file are restored below.
+			_ = os.Chmod(writePath, 0600)
+			f, err = os.OpenFile(writePath, os.O_WRONLY, 0)
+			if err == nil {
+				_, err = f.Seek(offset, io.SeekStart)
+			}
+			opts.progress.Skip(offset)
+		} else {
+			f, err = os.Create(writePath)
+		}
 		if err != nil {
 			return err
 		}
 		defer f.Close()
 
-		err = os.Chmod(target, mode)
+		err = os.Chmod(writePath, mode)
+		if err != nil {
+			return err
+		}
+
+		if ensurePerms {
+			err = os.Chown(writePath, uid, gid)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = applyLocalPerms(writePath, false, opts.perms)
 		if err != nil {
 			return err
 		}
@@ -358,12 +1179,37 @@ func (s *SshCExecutor) RecursivePullFile(nodeName, sourcePath, targetPath string
 		}
 		defer sfile.Close()
 
-		_, err = io.Copy(f, sfile)
+		if offset > 0 {
+			if _, err := sfile.Seek(offset, io.SeekStart); err != nil {
+				return err
+			}
+		}
+
+		_, err = io.Copy(io.MultiWriter(f, hasher),
+			opts.progress.Reader(sourcePath, fi.Size()-offset, sfile))
+		opts.progress.FileDone(sourcePath)
 		if err != nil {
 			s.Emitter.ErrorLog(false, fmt.Sprintf("Error on pull file %s", target))
 			return err
 		}
 
+		if opts.Verify {
+			err = s.verifyChecksum(hex.EncodeToString(hasher.Sum(nil)), sourcePath)
+			if err != nil {
+				return fmt.Errorf("error on verify %s: %s", target, err.Error())
+			}
+		}
+
+		if resumable {
+			if err := f.Close(); err != nil {
+				return err
+			}
+			if err := os.Rename(writePath, target); err != nil {
+				return err
+			}
+		}
+		opts.Stats.AddTransferred(fi.Size() - offset)
+
 	} else if ftype == "symlink" {
 		// Read fileinfo of the link
 		fiLink, err := s.SftpClient.Lstat(sourcePath)
@@ -381,3 +1227,204 @@ func (s *SshCExecutor) RecursivePullFile(nodeName, sourcePath, targetPath string
 
 	return nil
 }
+
+// recursivePushFileWithBecome uploads the files in a staging
+// directory with the login user and then moves them in the target
+// path with elevated rights.
+func (s *SshCExecutor) recursivePushFileWithBecome(nodeName, source, target string,
+	opts *SyncOpts) error {
+
+	ensurePerms := opts.EnsurePerms
+	become := opts.Become
+
+	if s.SftpClient == nil {
+		return fmt.Errorf("Sftp client not initialized.")
+	}
+
+	tmpRoot, err := s.getBecomeStagingDir(target)
+	if err != nil {
+		return err
+	}
+
+	tmpTarget := path.Join(tmpRoot, target)
+	if strings.HasSuffix(target, "/") {
+		tmpTarget += "/"
+	}
+
+	nTransferred := opts.Stats.Transferred
+	tmpOpts := *opts
+	tmpOpts.Become = nil
+	tmpOpts.uploadRoot = tmpRoot
+	tmpOpts.compareRoot = ""
+	// The ownership is applied on the move of the files.
+	tmpPerms := *opts.perms
+	tmpPerms.uid = -1
+	tmpPerms.gid = -1
+	tmpOpts.perms = &tmpPerms
+	// The stale files are removed after the move
+	tmpOpts.Delete = false
+	err = s.RecursivePushFileWithOpts(nodeName, source, tmpTarget, &tmpOpts)
+	if err != nil {
+		return err
+	}
+
+	fi, err := s.SftpClient.Stat(path.Clean(tmpTarget))
+	if err != nil {
+		return err
+	}
+
+	targetClean := path.Clean(target)
+	tarOpts := "--no-same-owner"
+	tarCreateOpts := ""
+	if ensurePerms || opts.perms.HasOwnership() {
+		tarOpts = "--same-owner --numeric-owner"
+	}
+	if opts.perms.uid >= 0 {
+		tarCreateOpts += fmt.Sprintf(" --owner=%d", opts.perms.uid)
+	}
+	if opts.perms.gid >= 0 {
+		tarCreateOpts += fmt.Sprintf(" --group=%d", opts.perms.gid)
+	}
+
+	var cmd string
+	if fi.IsDir() {
+		// The files are extracted in a staging directory inside the
+		// target and then renamed one by one, so the files of the target
+		// are always complete. The missing directories are created
+		// with the modes of the source before the rename of the files.
+		stagingDir := path.Join(targetClean, ".sshc-"+uuid.New().String()[:8])
+		backupSuffix := ""
+		if opts.Backup {
+			backupSuffix = opts.backupSuffix
+		}
+		moveScript := `t="$1"; sfx="$2"; shift 2; for f; do d="$t/$f"; ` +
+			`if [ -n "$sfx" ] && [ -e "$d" ] && [ ! -d "$d" ]; then ` +
+			`[ -e "$d$sfx" ] || cp -np "$d" "$d$sfx" || exit 1; fi; ` +
+			`mv -f "$f" "$d" || exit 1; done`
+
+		cmd = fmt.Sprintf(
+			"mkdir -p %[1]s && mkdir %[2]s && { "+
+				"tar -C %[3]s%[4]s --exclude=%[8]s -cf - . | tar -C %[2]s %[5]s -xf - && "+
+				"( cd %[2]s && find . -mindepth 1 -type d -print0 | "+
+				"tar --null --no-recursion -T - -cf - ) | "+
+				"tar -C %[1]s %[5]s --no-overwrite-dir -xf - && "+
+				"( cd %[2]s && find . ! -type d -exec sh -c %[6]s sh %[1]s %[7]s {} + ); "+
+				"}; rc=$?; rm -rf %[2]s; exit $rc",
+			helpers.ShellQuote(targetClean),
+			helpers.ShellQuote(stagingDir),
+			helpers.ShellQuote(path.Clean(tmpTarget)),
+			tarCreateOpts,
+			tarOpts,
+			helpers.ShellQuote(moveScript),
+			helpers.ShellQuote(backupSuffix),
+			// The partial files of a previous transfer.
+			helpers.ShellQuote("*"+resumeSuffix),
+		)
+	} else {
+		cpOpts := "-f"
+		if ensurePerms {
+			cpOpts = "-fp"
+		}
+		if opts.Stats.Transferred == nTransferred {
+			// POST: the file is unchanged.
+			return nil
+		}
+
+		// The file is copied in the directory of the target and
+		// renamed only when it's complete.
+		uploadPath := getUploadTmpPath(targetClean)
+
+		cmd = fmt.Sprintf("mkdir -p %s && cp %s %s %s",
+			helpers.ShellQuote(path.Dir(targetClean)),
+			cpOpts,
+			helpers.ShellQuote(path.Clean(tmpTarget)),
+			helpers.ShellQuote(uploadPath),
+		)
+		if opts.Compare != "" {
+			cmd += fmt.Sprintf(" && touch -r %s %s",
+				helpers.ShellQuote(path.Clean(tmpTarget)),
+				helpers.ShellQuote(uploadPath))
+		}
+		if opts.perms.fileMode != nil {
+			cmd += fmt.Sprintf(" && chmod %o %s", *opts.perms.fileMode,
+				helpers.ShellQuote(uploadPath))
+		}
+		if spec := ownerSpec(opts.perms.uid, opts.perms.gid); spec != "" {
+			cmd += fmt.Sprintf(" && chown %s %s", spec,
+				helpers.ShellQuote(uploadPath))
+		}
+		if opts.Backup {
+			// Never overwrite an existing backup.
+			cmd += fmt.Sprintf(" && { [ ! -e %s ] || [ -e %s ] || cp -np %s %s; }",
+				helpers.ShellQuote(targetClean),
+				helpers.ShellQuote(targetClean+opts.backupSuffix),
+				helpers.ShellQuote(targetClean),
+				helpers.ShellQuote(targetClean+opts.backupSuffix))
+		}
+		cmd += fmt.Sprintf(" && mv -f %s %s || { rm -f %s; exit 1; }",
+			helpers.ShellQuote(uploadPath),
+			helpers.ShellQuote(targetClean),
+			helpers.ShellQuote(uploadPath))
+	}
+
+	s.Emitter.DebugLog(false,
+		fmt.Sprintf("[%s] Moving %s -> %s (%s)", nodeName, tmpTarget, target, become))
+
+	_, err = s.ExecCommandOutput(cmd, become)
+	if err != nil {
+		return fmt.Errorf("error on move files to %s: %s", target, err.Error())
+	}
+
+	// The staging directory is kept only on failure.
+	s.SftpClient.RemoveAll(tmpRoot)
+
+	if opts.Delete {
+		return s.mirrorDelete(nodeName, opts)
+	}
+
+	return nil
+}
+
+// getBecomeStagingDir returns the directory of the login user where
+// the files are uploaded before the move with elevated rights. The
+// path is derived from the target and the directory is kept on failure,
+// so the next transfer resumes the partial files of the large files.
+// The other files of a previous transfer are removed.
+func (s *SshCExecutor) getBecomeStagingDir(target string) (string, error) {
+	home, err := s.SftpClient.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("error on get home directory: %s", err.Error())
+	}
+
+	sum := sha256.Sum256([]byte(path.Clean(target)))
+	baseDir := path.Join(home, ".cache", "ssh-compose")
+	ans := path.Join(baseDir, "become", hex.EncodeToString(sum[:])[:16])
+
+	err = s.SftpClient.MkdirAll(ans)
+	if err != nil {
+		return "", fmt.Errorf("error on create staging dir %s: %s",
+			ans, err.Error())
+	}
+
+	// The files could contain secrets.
+	err = s.SftpClient.Chmod(baseDir, 0700)
+	if err != nil {
+		return "", err
+	}
+
+	walker := s.SftpClient.Walk(ans)
+	for walker.Step() {
+		if walker.Err() != nil {
+			continue
+		}
+		if walker.Stat().IsDir() || strings.HasSuffix(walker.Path(), resumeSuffix) {
+			continue
+		}
+		if err := s.SftpClient.Remove(walker.Path()); err != nil {
+			return "", fmt.Errorf("error on clean staging dir %s: %s",
+				ans, err.Error())
+		}
+	}
+
+	return ans, nil
+}
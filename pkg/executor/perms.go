@@ -0,0 +1,182 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/MottainaiCI/ssh-compose/pkg/helpers"
+
+	"github.com/pkg/sftp"
+)
+
+// resourcePerms contains the ownership and the modes
+// resolved of the files of a resource.
+type resourcePerms struct {
+	uid      int
+	gid      int
+	fileMode *os.FileMode
+	dirMode  *os.FileMode
+}
+
+func (p *resourcePerms) HasOwnership() bool { return p.uid >= 0 || p.gid >= 0 }
+
+func (p *resourcePerms) GetMode(isDir bool) *os.FileMode {
+	if isDir {
+		return p.dirMode
+	}
+	return p.fileMode
+}
+
+// ParseFileMode parses a file mode in octal notation (ex. 0644).
+func ParseFileMode(m string) (*os.FileMode, error) {
+	if m == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.ParseUint(m, 8, 32)
+	if err != nil || v > 07777 {
+		return nil, fmt.Errorf("invalid file mode %s", m)
+	}
+
+	ans := os.FileMode(v)
+	return &ans, nil
+}
+
+// resolvePerms resolves the ownership of the resource. The names
+// are resolved on the remote node if remote is true, otherwise
+// on the local host.
+func (s *SshCExecutor) resolvePerms(opts *SyncOpts, remote bool) (*resourcePerms, error) {
+	var err error
+	ans := &resourcePerms{uid: -1, gid: -1}
+
+	ans.fileMode, err = ParseFileMode(opts.FileMode)
+	if err != nil {
+		return nil, err
+	}
+	ans.dirMode, err = ParseFileMode(opts.DirMode)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Owner != "" {
+		ans.uid, err = s.resolveId(opts.Owner, false, remote)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Group != "" {
+		ans.gid, err = s.resolveId(opts.Group, true, remote)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ans, nil
+}
+
+func (s *SshCExecutor) resolveId(name string, group, remote bool) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+
+	if !remote {
+		if group {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return -1, err
+			}
+			return strconv.Atoi(g.Gid)
+		}
+		u, err := user.Lookup(name)
+		if err != nil {
+			return -1, err
+		}
+		return strconv.Atoi(u.Uid)
+	}
+
+	cmd := fmt.Sprintf("id -u %s", helpers.ShellQuote(name))
+	if group {
+		cmd = fmt.Sprintf("getent group %s | cut -d: -f3", helpers.ShellQuote(name))
+	}
+
+	out, err := s.ExecCommandOutput(cmd, nil)
+	if err != nil {
+		return -1, fmt.Errorf("error on resolve %s: %s", name, err.Error())
+	}
+
+	id, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return -1, fmt.Errorf("%s not found", name)
+	}
+
+	return id, nil
+}
+
+// applyRemotePerms sets the modes and the ownership of the
+// resource to the remote path through SFTP.
+func (s *SshCExecutor) applyRemotePerms(targetPath string, isDir bool, perms *resourcePerms) error {
+	if perms == nil {
+		return nil
+	}
+
+	if mode := perms.GetMode(isDir); mode != nil {
+		if err := s.SftpClient.Chmod(targetPath, *mode); err != nil {
+			return fmt.Errorf("error on chmod %s: %s", targetPath, err.Error())
+		}
+	}
+
+	if perms.HasOwnership() {
+		uid, gid := perms.uid, perms.gid
+		if uid < 0 || gid < 0 {
+			fi, err := s.SftpClient.Stat(targetPath)
+			if err != nil {
+				return err
+			}
+			fs, ok := fi.Sys().(*sftp.FileStat)
+			if ok {
+				if uid < 0 {
+					uid = int(fs.UID)
+				}
+				if gid < 0 {
+					gid = int(fs.GID)
+				}
+			}
+		}
+
+		if err := s.SftpClient.Chown(targetPath, uid, gid); err != nil {
+			return fmt.Errorf("error on chown %s: %s", targetPath, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// applyLocalPerms sets the modes and the ownership of the
+// resource to the local path.
+func applyLocalPerms(targetPath string, isDir bool, perms *resourcePerms) error {
+	if perms == nil {
+		return nil
+	}
+
+	if mode := perms.GetMode(isDir); mode != nil {
+		if err := os.Chmod(targetPath, *mode); err != nil {
+			return err
+		}
+	}
+
+	if perms.HasOwnership() {
+		if err := os.Lchown(targetPath, perms.uid, perms.gid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
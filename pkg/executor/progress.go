@@ -0,0 +1,253 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// Interval between two log lines when the output
+	// is not a terminal.
+	ProgressLogInterval = 5 * time.Second
+	// Interval between two refresh of the progress line
+	// on a terminal.
+	progressTTYInterval = 200 * time.Millisecond
+)
+
+// transferProgress reports the transferred bytes of the files of a
+// resource. On a terminal the status is rendered on a single line
+// updated in place, otherwise a log line is emitted periodically.
+type transferProgress struct {
+	nodeName string
+	name     string
+	total    int64
+	done     int64
+
+	files map[string]*fileProgress
+
+	isTerminal bool
+	out        io.Writer
+	interval   time.Duration
+	emitter    SshCExecutorEmitter
+
+	start    time.Time
+	lastShow time.Time
+	lineLen  int
+
+	mutex sync.Mutex
+}
+
+type fileProgress struct {
+	name  string
+	size  int64
+	done  int64
+	start time.Time
+}
+
+// progressReader updates the progress of a file on every read.
+type progressReader struct {
+	io.Reader
+	progress *transferProgress
+	file     *fileProgress
+}
+
+func newTransferProgress(nodeName, name string, total int64,
+	emitter SshCExecutorEmitter) *transferProgress {
+	now := time.Now()
+	ans := &transferProgress{
+		nodeName:   nodeName,
+		name:       name,
+		total:      total,
+		files:      make(map[string]*fileProgress, 0),
+		isTerminal: term.IsTerminal(int(os.Stdout.Fd())),
+		out:        os.Stdout,
+		interval:   ProgressLogInterval,
+		emitter:    emitter,
+		start:      now,
+		lastShow:   now,
+	}
+	if ans.isTerminal {
+		ans.interval = progressTTYInterval
+	}
+	return ans
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.progress.add(r.file, int64(n))
+	}
+	return n, err
+}
+
+// Reader returns a reader that tracks the bytes read of the file.
+func (t *transferProgress) Reader(name string, size int64, r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+
+	f := &fileProgress{
+		name:  name,
+		size:  size,
+		start: time.Now(),
+	}
+
+	t.mutex.Lock()
+	t.files[name] = f
+	t.mutex.Unlock()
+
+	return &progressReader{Reader: r, progress: t, file: f}
+}
+
+// FileDone removes the file from the active transfers.
+func (t *transferProgress) FileDone(name string) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	f, ok := t.files[name]
+	if !ok {
+		return
+	}
+	delete(t.files, name)
+
+	if !t.isTerminal && time.Since(f.start) >= t.interval {
+		// Report the end of the long transfers.
+		t.emitter.InfoLog(false,
+			fmt.Sprintf(">>> [%s] %s %s", t.nodeName, filepath.Base(f.name),
+				formatProgress(f.size, f.size, time.Since(f.start))))
+	}
+}
+
+// Skip removes from the total the bytes of an unchanged file.
+func (t *transferProgress) Skip(size int64) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.total -= size
+}
+
+func (t *transferProgress) add(f *fileProgress, n int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	f.done += n
+	t.done += n
+
+	if time.Since(t.lastShow) < t.interval {
+		return
+	}
+	t.lastShow = time.Now()
+
+	if t.isTerminal {
+		t.renderLine(f)
+	} else {
+		t.logLines()
+	}
+}
+
+func (t *transferProgress) renderLine(f *fileProgress) {
+	line := fmt.Sprintf(">>> [%s] %s %s | %s %s",
+		t.nodeName, t.name,
+		formatProgress(t.done, t.total, time.Since(t.start)),
+		filepath.Base(f.name),
+		formatProgress(f.done, f.size, time.Since(f.start)),
+	)
+
+	pad := ""
+	if len(line) < t.lineLen {
+		pad = strings.Repeat(" ", t.lineLen-len(line))
+	}
+	t.lineLen = len(line)
+
+	fmt.Fprintf(t.out, "\r%s%s", line, pad)
+}
+
+func (t *transferProgress) logLines() {
+	for _, f := range t.files {
+		if f.done == f.size {
+			continue
+		}
+		t.emitter.InfoLog(false,
+			fmt.Sprintf(">>> [%s] %s %s", t.nodeName, filepath.Base(f.name),
+				formatProgress(f.done, f.size, time.Since(f.start))))
+	}
+	t.emitter.InfoLog(false,
+		fmt.Sprintf(">>> [%s] %s %s", t.nodeName, t.name,
+			formatProgress(t.done, t.total, time.Since(t.start))))
+}
+
+// Done terminates the progress line or writes the final log line.
+func (t *transferProgress) Done() {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.isTerminal {
+		if t.lineLen > 0 {
+			line := fmt.Sprintf(">>> [%s] %s %s", t.nodeName, t.name,
+				formatProgress(t.done, t.total, time.Since(t.start)))
+			pad := ""
+			if len(line) < t.lineLen {
+				pad = strings.Repeat(" ", t.lineLen-len(line))
+			}
+			fmt.Fprintf(t.out, "\r%s%s\n", line, pad)
+		}
+	} else if time.Since(t.start) >= t.interval {
+		t.emitter.InfoLog(false,
+			fmt.Sprintf(">>> [%s] %s %s", t.nodeName, t.name,
+				formatProgress(t.done, t.total, time.Since(t.start))))
+	}
+}
+
+func formatProgress(done, total int64, elapsed time.Duration) string {
+	rate := float64(0)
+	if elapsed > 0 {
+		rate = float64(done) / elapsed.Seconds()
+	}
+
+	ans := fmt.Sprintf("%s/%s", formatBytes(done), formatBytes(total))
+	if total > 0 {
+		ans += fmt.Sprintf(" (%d%%)", done*100/total)
+	}
+	ans += fmt.Sprintf(" %s/s", formatBytes(int64(rate)))
+
+	if rate > 0 && total > done {
+		eta := time.Duration(float64(total-done)/rate) * time.Second
+		ans += fmt.Sprintf(" ETA %s", eta.Round(time.Second))
+	}
+
+	return ans
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,151 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/MottainaiCI/ssh-compose/pkg/helpers"
+)
+
+const (
+	// Default size of the files transferred with the resume
+	// support (64MB).
+	DefaultResumeThreshold int64 = 64 * 1024 * 1024
+
+	resumeSuffix = ".sshc-part"
+)
+
+// getResumePath returns the path of the partial file used to
+// resume the upload of the target file.
+func getResumePath(targetPath string) string {
+	return path.Join(path.Dir(targetPath),
+		fmt.Sprintf(".%s%s", path.Base(targetPath), resumeSuffix))
+}
+
+// getLocalResumePath returns the path of the partial file used to
+// resume the download of the target file.
+func getLocalResumePath(targetPath string) string {
+	return filepath.Join(filepath.Dir(targetPath),
+		fmt.Sprintf(".%s%s", filepath.Base(targetPath), resumeSuffix))
+}
+
+func (o *SyncOpts) isResumable(size int64) bool {
+	return o.ResumeThreshold > 0 && size >= o.ResumeThreshold
+}
+
+// RemotePrefixSha256 returns the SHA-256 checksum of the first n
+// bytes of a remote file.
+func (s *SshCExecutor) RemotePrefixSha256(remotePath string, n int64) (string, error) {
+	out, err := s.ExecCommandOutput(
+		fmt.Sprintf("head -c %d %s | sha256sum", n, helpers.ShellQuote(remotePath)), nil)
+	if err == nil {
+		fields := strings.Fields(out)
+		if len(fields) > 0 {
+			return fields[0], nil
+		}
+	}
+
+	if s.SftpClient == nil {
+		return "", fmt.Errorf("error on retrieve checksum of %s", remotePath)
+	}
+
+	// POST: head or sha256sum not available. Reading back
+	// the prefix through the SFTP session.
+	f, err := s.SftpClient.Open(remotePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.CopyN(h, f, n); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashPrefix writes the first n bytes of the reader to the hasher.
+func hashPrefix(h hash.Hash, r io.Reader, n int64) error {
+	_, err := io.CopyN(h, r, n)
+	return err
+}
+
+// remoteResumeOffset returns the size of the partial remote file
+// to continue the upload. The prefix already uploaded is confirmed
+// comparing the checksums. On success the local file is positioned
+// at the returned offset and the hasher contains the prefix.
+func (s *SshCExecutor) remoteResumeOffset(local *os.File, h hash.Hash,
+	partialPath string, size int64) int64 {
+
+	fi, err := s.SftpClient.Stat(partialPath)
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() == 0 || fi.Size() >= size {
+		return 0
+	}
+	n := fi.Size()
+
+	reset := func() int64 {
+		h.Reset()
+		local.Seek(0, io.SeekStart)
+		return 0
+	}
+
+	if err := hashPrefix(h, local, n); err != nil {
+		return reset()
+	}
+
+	remoteSum, err := s.RemotePrefixSha256(partialPath, n)
+	if err != nil || remoteSum != hex.EncodeToString(h.Sum(nil)) {
+		s.Emitter.DebugLog(false,
+			fmt.Sprintf("Partial file %s not matching. Restarting the upload.", partialPath))
+		return reset()
+	}
+
+	return n
+}
+
+// localResumeOffset returns the size of the partial local file
+// to continue the download. The prefix already downloaded is
+// confirmed comparing the checksums. On success the hasher
+// contains the prefix.
+func (s *SshCExecutor) localResumeOffset(partialPath, remotePath string, h hash.Hash,
+	size int64) int64 {
+
+	fi, err := os.Stat(partialPath)
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() == 0 || fi.Size() >= size {
+		return 0
+	}
+	n := fi.Size()
+
+	f, err := os.Open(partialPath)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	if err := hashPrefix(h, f, n); err != nil {
+		h.Reset()
+		return 0
+	}
+
+	remoteSum, err := s.RemotePrefixSha256(remotePath, n)
+	if err != nil || remoteSum != hex.EncodeToString(h.Sum(nil)) {
+		s.Emitter.DebugLog(false,
+			fmt.Sprintf("Partial file %s not matching. Restarting the download.", partialPath))
+		h.Reset()
+		return 0
+	}
+
+	return n
+}
@@ -0,0 +1,492 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/MottainaiCI/ssh-compose/pkg/helpers"
+)
+
+// scpChownMaxLen is the max length of the paths of a single chown
+// command, well under the ARG_MAX of the common systems.
+const scpChownMaxLen = 64 * 1024
+
+// scpConn implements the messages of the SCP protocol exchanged
+// with the remote scp command.
+type scpConn struct {
+	w io.Writer
+	r *bufio.Reader
+}
+
+// readAck reads the response of the remote scp command.
+func (c *scpConn) readAck() error {
+	b, err := c.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b == 0 {
+		return nil
+	}
+
+	msg, _ := c.r.ReadString('\n')
+	return fmt.Errorf("scp: %s", strings.TrimSpace(msg))
+}
+
+func (c *scpConn) sendAck() error {
+	_, err := c.w.Write([]byte{0})
+	return err
+}
+
+func (c *scpConn) send(msg string) error {
+	if _, err := io.WriteString(c.w, msg); err != nil {
+		return err
+	}
+	return c.readAck()
+}
+
+// runScp runs the remote scp command and calls the handler with the
+// connection of the protocol.
+func (s *SshCExecutor) runScp(cmd string, become *BecomeOpts,
+	handler func(*scpConn) error) error {
+
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+
+	var errBuffer bytes.Buffer
+	type cmdResult struct {
+		res int
+		err error
+	}
+	resCh := make(chan cmdResult, 1)
+
+	go func() {
+		res, err := s.ExecCommand(cmd, stdinR, stdoutW, &errBuffer, become)
+		stdoutW.Close()
+		// Unlock the handler on write
+		stdinR.Close()
+		resCh <- cmdResult{res, err}
+	}()
+
+	herr := handler(&scpConn{w: stdinW, r: bufio.NewReader(stdoutR)})
+	stdinW.Close()
+	// Drain the output to permit the exit of the command.
+	io.Copy(io.Discard, stdoutR)
+	result := <-resCh
+
+	if herr != nil {
+		if errBuffer.Len() > 0 {
+			return fmt.Errorf("%s: %s", herr.Error(), strings.TrimSpace(errBuffer.String()))
+		}
+		return herr
+	}
+	if result.err != nil {
+		return result.err
+	}
+	if result.res != 0 {
+		return fmt.Errorf("scp exited with %d: %s", result.res, errBuffer.String())
+	}
+
+	return nil
+}
+
+// recursivePushFileWithScp uploads the source tree through the SCP
+// protocol. The ownership is applied after the transfer.
+func (s *SshCExecutor) recursivePushFileWithScp(nodeName, source, target string,
+	opts *SyncOpts) error {
+
+	sourceRoot := filepath.Clean(source)
+	fi, err := os.Stat(sourceRoot)
+	if err != nil {
+		return err
+	}
+
+	targetDir := path.Clean(target)
+	fileName := ""
+	if !fi.IsDir() {
+		if strings.HasSuffix(target, "/") {
+			fileName = filepath.Base(sourceRoot)
+		} else {
+			targetDir = path.Dir(targetDir)
+			fileName = path.Base(target)
+		}
+	}
+
+	// Paths grouped by the ownership to apply.
+	owners := make(map[string][]string, 0)
+	addOwner := func(remotePath string, fInfo os.FileInfo) {
+		uid, gid := -1, -1
+		if opts.EnsurePerms {
+			if stat, ok := fInfo.Sys().(*syscall.Stat_t); ok {
+				uid, gid = int(stat.Uid), int(stat.Gid)
+			}
+		}
+		if opts.perms.uid >= 0 {
+			uid = opts.perms.uid
+		}
+		if opts.perms.gid >= 0 {
+			gid = opts.perms.gid
+		}
+		if spec := ownerSpec(uid, gid); spec != "" {
+			owners[spec] = append(owners[spec], remotePath)
+		}
+	}
+
+	getMode := func(fInfo os.FileInfo) os.FileMode {
+		if mode := opts.perms.GetMode(fInfo.IsDir()); mode != nil {
+			return *mode
+		}
+		return fInfo.Mode().Perm()
+	}
+
+	var sendEntry func(c *scpConn, p, name, rel, remotePath string, fInfo os.FileInfo) error
+	sendEntry = func(c *scpConn, p, name, rel, remotePath string, fInfo os.FileInfo) error {
+		if strings.ContainsAny(name, "\n\r") {
+			return fmt.Errorf("invalid file name %q", name)
+		}
+
+		if fInfo.Mode()&os.ModeSymlink != 0 {
+			// POST: the SCP protocol doesn't support symlinks.
+			// I follow the links to regular files as scp does.
+			linkInfo, err := os.Stat(p)
+			if err != nil || !linkInfo.Mode().IsRegular() {
+				s.Emitter.WarnLog(false,
+					fmt.Sprintf("[%s] Skipping symlink %s not supported by the scp transfer",
+						nodeName, p))
+				return nil
+			}
+			fInfo = linkInfo
+		}
+
+		mtime := fInfo.ModTime().Unix()
+		if err := c.send(fmt.Sprintf("T%d 0 %d 0\n", mtime, mtime)); err != nil {
+			return err
+		}
+
+		if fInfo.IsDir() {
+			if err := c.send(fmt.Sprintf("D%04o 0 %s\n", getMode(fInfo), name)); err != nil {
+				return err
+			}
+			addOwner(remotePath, fInfo)
+
+			if err := s.sendScpDir(c, p, rel, remotePath, opts, sendEntry); err != nil {
+				return err
+			}
+
+			return c.send("E\n")
+		}
+
+		if !fInfo.Mode().IsRegular() {
+			return fmt.Errorf("'%s' isn't a supported file type", p)
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err := c.send(fmt.Sprintf("C%04o %d %s\n", getMode(fInfo), fInfo.Size(), name)); err != nil {
+			return err
+		}
+
+		hasher := sha256.New()
+		n, err := io.Copy(io.MultiWriter(c.w, hasher),
+			opts.progress.Reader(p, fInfo.Size(), f))
+		opts.progress.FileDone(p)
+		if err != nil {
+			return err
+		}
+		if n != fInfo.Size() {
+			return fmt.Errorf("file %s changed during the transfer", p)
+		}
+		if err := c.sendAck(); err != nil {
+			return err
+		}
+		if err := c.readAck(); err != nil {
+			return err
+		}
+
+		if opts.Verify {
+			opts.checksums[remotePath] = hex.EncodeToString(hasher.Sum(nil))
+		}
+		addOwner(remotePath, fInfo)
+		opts.Stats.AddTransferred(fInfo.Size())
+
+		return nil
+	}
+
+	cmd := fmt.Sprintf("mkdir -p %s && scp -r -p -t %s",
+		helpers.ShellQuote(targetDir), helpers.ShellQuote(targetDir))
+
+	s.Emitter.DebugLog(false,
+		fmt.Sprintf("[%s] Copying %s -> %s (%s)", nodeName, source, target, cmd))
+
+	err = s.runScp(cmd, opts.Become, func(c *scpConn) error {
+		if err := c.readAck(); err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return sendEntry(c, sourceRoot, fileName, fileName,
+				path.Join(targetDir, fileName), fi)
+		}
+		return s.sendScpDir(c, sourceRoot, "", targetDir, opts, sendEntry)
+	})
+	if err != nil {
+		return fmt.Errorf("error on copy %s to %s: %s", source, target, err.Error())
+	}
+
+	ownerSpecs := []string{}
+	for spec := range owners {
+		ownerSpecs = append(ownerSpecs, spec)
+	}
+	sort.Strings(ownerSpecs)
+
+	for _, spec := range ownerSpecs {
+		// Split the paths in multiple commands to stay under the
+		// ARG_MAX limit of the remote node.
+		for _, paths := range helpers.ShellJoinBatches(owners[spec], scpChownMaxLen) {
+			_, err := s.ExecCommandOutput(
+				fmt.Sprintf("chown %s -- %s", spec, paths),
+				opts.Become)
+			if err != nil {
+				return fmt.Errorf("error on chown: %s", err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *SshCExecutor) sendScpDir(c *scpConn, dir, rel, remoteDir string, opts *SyncOpts,
+	sendEntry func(*scpConn, string, string, string, string, os.FileInfo) error) error {
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, ent := range entries {
+		fInfo, err := ent.Info()
+		if err != nil {
+			return err
+		}
+
+		entRel := path.Join(rel, ent.Name())
+		if opts.isIgnored(entRel, fInfo.IsDir()) {
+			s.Emitter.DebugLog(false, fmt.Sprintf("Excluding %s", entRel))
+			continue
+		}
+
+		err = sendEntry(c, filepath.Join(dir, ent.Name()), ent.Name(), entRel,
+			path.Join(remoteDir, ent.Name()), fInfo)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recursivePullFileWithScp downloads the remote tree through the
+// SCP protocol.
+func (s *SshCExecutor) recursivePullFileWithScp(nodeName, sourcePath, target string,
+	opts *SyncOpts) error {
+
+	sourceClean := path.Clean(sourcePath)
+	cmd := fmt.Sprintf("scp -r -p -f %s", helpers.ShellQuote(sourceClean))
+
+	s.Emitter.DebugLog(false,
+		fmt.Sprintf("[%s] Copying %s -> %s (%s)", nodeName, sourcePath, target, cmd))
+
+	if opts.EnsurePerms {
+		s.Emitter.DebugLog(false,
+			"The ownership of the files is not available with the scp transfer.")
+	}
+
+	err := s.runScp(cmd, opts.Become, func(c *scpConn) error {
+		// Relative paths of the open directories. The root
+		// entry is mapped to the target.
+		dirs := []string{}
+		skipDepth := 0
+		var mtime *time.Time
+
+		if err := c.sendAck(); err != nil {
+			return err
+		}
+
+		for {
+			line, err := c.r.ReadString('\n')
+			if err == io.EOF && line == "" {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if line == "" {
+				continue
+			}
+
+			switch line[0] {
+			case 1, 2:
+				return fmt.Errorf("scp: %s", strings.TrimSpace(line[1:]))
+			case 'T':
+				fields := strings.Fields(line[1:])
+				if len(fields) > 0 {
+					if sec, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+						t := time.Unix(sec, 0)
+						mtime = &t
+					}
+				}
+				if err := c.sendAck(); err != nil {
+					return err
+				}
+				continue
+			case 'E':
+				if skipDepth > 0 {
+					skipDepth--
+				} else if len(dirs) > 0 {
+					dirs = dirs[:len(dirs)-1]
+				}
+				if err := c.sendAck(); err != nil {
+					return err
+				}
+				continue
+			case 'C', 'D':
+			default:
+				return fmt.Errorf("unexpected scp message %q", line)
+			}
+
+			fields := strings.SplitN(strings.TrimRight(line[1:], "\n"), " ", 3)
+			if len(fields) != 3 {
+				return fmt.Errorf("invalid scp message %q", line)
+			}
+			mode, err := strconv.ParseUint(fields[0], 8, 32)
+			if err != nil {
+				return fmt.Errorf("invalid mode on scp message %q", line)
+			}
+			size, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid size on scp message %q", line)
+			}
+			name := fields[2]
+			if name == "." || name == ".." || strings.Contains(name, "/") {
+				return fmt.Errorf("invalid file name %q", name)
+			}
+			isDir := line[0] == 'D'
+
+			rel := ""
+			dest := target
+			if len(dirs) > 0 {
+				rel = path.Join(dirs[len(dirs)-1], name)
+				dest = filepath.Join(target, filepath.FromSlash(rel))
+			}
+
+			skip := skipDepth > 0 || (rel != "" && opts.isIgnored(rel, isDir))
+			if !skip && !isDir && mtime != nil && opts.filters.Skip(size, *mtime) {
+				skip = true
+				opts.Stats.AddSkipped()
+			}
+
+			if err := c.sendAck(); err != nil {
+				return err
+			}
+
+			if isDir {
+				if skip {
+					skipDepth++
+				} else {
+					if err := os.MkdirAll(dest, os.FileMode(mode)); err != nil {
+						return err
+					}
+					if err := applyLocalPerms(dest, true, opts.perms); err != nil {
+						return err
+					}
+					dirs = append(dirs, rel)
+				}
+				mtime = nil
+				continue
+			}
+
+			var w io.Writer = io.Discard
+			var f *os.File
+			hasher := sha256.New()
+			if !skip {
+				f, err = os.Create(dest)
+				if err != nil {
+					return err
+				}
+				w = io.MultiWriter(f, hasher)
+			}
+
+			_, err = io.CopyN(w, opts.progress.Reader(dest, size, c.r), size)
+			opts.progress.FileDone(dest)
+			if f != nil {
+				f.Close()
+			}
+			if err != nil {
+				return err
+			}
+			if err := c.readAck(); err != nil {
+				return err
+			}
+			if err := c.sendAck(); err != nil {
+				return err
+			}
+
+			if !skip {
+				if err := os.Chmod(dest, os.FileMode(mode)); err != nil {
+					return err
+				}
+				if err := applyLocalPerms(dest, false, opts.perms); err != nil {
+					return err
+				}
+				if mtime != nil {
+					_ = os.Chtimes(dest, *mtime, *mtime)
+				}
+				if opts.Verify {
+					remotePath := sourceClean
+					if rel != "" {
+						remotePath = path.Join(sourceClean, rel)
+					}
+					opts.checksums[remotePath] = hex.EncodeToString(hasher.Sum(nil))
+				}
+				opts.Stats.AddTransferred(size)
+			}
+			mtime = nil
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("error on copy %s to %s: %s", sourcePath, target, err.Error())
+	}
+
+	return nil
+}
+
+func ownerSpec(uid, gid int) string {
+	switch {
+	case uid >= 0 && gid >= 0:
+		return fmt.Sprintf("%d:%d", uid, gid)
+	case uid >= 0:
+		return strconv.Itoa(uid)
+	case gid >= 0:
+		return fmt.Sprintf(":%d", gid)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,715 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MottainaiCI/ssh-compose/pkg/helpers"
+
+	tarf_specs "github.com/geaaru/tar-formers/pkg/specs"
+)
+
+const (
+	// Name of the file with the ignore patterns
+	// available in the source tree.
+	SshcIgnoreFile = ".sshcignore"
+
+	// Compare the size of the files.
+	CompareSize = "size"
+	// Compare the size and the modification time of the files.
+	CompareMtime = "mtime"
+	// Compare the size and the SHA-256 checksum of the files.
+	CompareChecksum = "checksum"
+)
+
+// The backup suffix contains the timestamp with the microseconds.
+// The backups of the previous releases have only the seconds.
+var backupRegex = regexp.MustCompile(`\.[0-9]{14}([0-9]{6})?$`)
+
+// getBackupSuffix returns the suffix of the backup files with
+// the timestamp with the microseconds.
+func getBackupSuffix(t time.Time) string {
+	return "." + strings.ReplaceAll(t.Format("20060102150405.000000"), ".", "")
+}
+
+type SyncStats struct {
+	Transferred int
+	Skipped     int
+	Deleted     int
+	Bytes       int64
+
+	mutex sync.Mutex
+}
+
+type SyncOpts struct {
+	EnsurePerms bool
+
+	// Change detection mode. If empty all files are
+	// uploaded.
+	Compare string
+
+	// Transfer mode of the files: sftp (default) or tar.
+	Mode string
+	// Compression of the tar stream: none (default) or gzip.
+	Compression string
+	// Tar-formers rules used to filter and rename the
+	// files of the tar stream.
+	TarRules *tarf_specs.SpecFile
+
+	// Privilege escalation options. If nil the files
+	// are written with the login user.
+	Become *BecomeOpts
+
+	// Ownership (names or numbers) and modes (octal) applied
+	// to the files and directories of the resource.
+	Owner    string
+	Group    string
+	FileMode string
+	DirMode  string
+
+	// Verify the SHA-256 checksum of the transferred files.
+	Verify bool
+
+	// Keep the previous version of the replaced files
+	// with a timestamp suffix (YYYYMMDDhhmmss + microseconds).
+	Backup bool
+
+	// Gitignore-style patterns of the files to skip. The
+	// exclude patterns are evaluated after the patterns of
+	// the .sshcignore file. If the include patterns are
+	// defined only the files matching the patterns (or
+	// under a matching directory) and not excluded are
+	// transferred.
+	Exclude []string
+	Include []string
+
+	// Remove the remote files not available in the
+	// source directory (mirror mode).
+	Delete bool
+	// Paths (relative to the destination) or glob patterns
+	// of the remote files to keep in mirror mode.
+	Protect []string
+	// Show the files to delete without removing them.
+	DryRun bool
+
+	// Number of files transferred in parallel. Values
+	// lesser than 2 disable the parallel transfers.
+	Workers int
+
+	// Filters of the pulled files: minimum modification time
+	// (duration or date) and range of the size (ex. 10M).
+	NewerThan string
+	MinSize   string
+	MaxSize   string
+
+	// Show the progress of the transferred bytes.
+	Progress bool
+
+	// Minimum size of the files transferred with the resume
+	// support. Zero disables the resume of the transfers.
+	ResumeThreshold int64
+
+	// Counters of the processed files.
+	Stats *SyncStats
+
+	// Used to compare the files uploaded in a temporary
+	// directory with the final target.
+	uploadRoot  string
+	compareRoot string
+
+	mirror   *mirrorState
+	rules    *helpers.IgnoreRules
+	includes *helpers.IgnoreRules
+	perms    *resourcePerms
+
+	filters *fileFilters
+
+	progress *transferProgress
+
+	// Checksums of the files transferred without SFTP
+	// verified after the transfer.
+	checksums map[string]string
+
+	backupSuffix string
+}
+
+// fileFilters contains the filters resolved of the pulled files.
+type fileFilters struct {
+	newerThan time.Time
+	minSize   int64
+	maxSize   int64
+}
+
+// resolveFilters parses the filters of the files. It returns nil
+// if no filters are defined.
+func (o *SyncOpts) resolveFilters() (*fileFilters, error) {
+	if o.NewerThan == "" && o.MinSize == "" && o.MaxSize == "" {
+		return nil, nil
+	}
+
+	var err error
+	ans := &fileFilters{}
+
+	if o.NewerThan != "" {
+		ans.newerThan, err = helpers.ParseNewerThan(o.NewerThan, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.MinSize != "" {
+		ans.minSize, err = helpers.ParseSize(o.MinSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.MaxSize != "" {
+		ans.maxSize, err = helpers.ParseSize(o.MaxSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ans, nil
+}
+
+// Skip returns true if the file doesn't match the filters.
+func (f *fileFilters) Skip(size int64, mtime time.Time) bool {
+	if f == nil {
+		return false
+	}
+	if !f.newerThan.IsZero() && !mtime.After(f.newerThan) {
+		return true
+	}
+	if f.minSize > 0 && size < f.minSize {
+		return true
+	}
+	if f.maxSize > 0 && size > f.maxSize {
+		return true
+	}
+	return false
+}
+
+// mirrorState tracks the remote paths written by the sync
+// used to identify the stale files.
+type mirrorState struct {
+	root  string
+	paths map[string]bool
+}
+
+func NewSyncOpts() *SyncOpts {
+	return &SyncOpts{
+		EnsurePerms:     false,
+		Compare:         "",
+		Mode:            TransferModeSftp,
+		Compression:     CompressionNone,
+		TarRules:        nil,
+		Become:          nil,
+		Owner:           "",
+		Group:           "",
+		FileMode:        "",
+		DirMode:         "",
+		Backup:          false,
+		Verify:          false,
+		Exclude:         []string{},
+		Include:         []string{},
+		Delete:          false,
+		Protect:         []string{},
+		DryRun:          false,
+		Workers:         1,
+		Progress:        false,
+		ResumeThreshold: DefaultResumeThreshold,
+		Stats:           &SyncStats{},
+	}
+}
+
+func (o *SyncOpts) Validate() error {
+	switch o.Compare {
+	case "", CompareSize, CompareMtime, CompareChecksum:
+	default:
+		return fmt.Errorf("invalid compare mode %s", o.Compare)
+	}
+
+	switch o.Mode {
+	case "", TransferModeSftp:
+	case TransferModeTar:
+		if o.Compare != "" {
+			return fmt.Errorf("compare mode is not supported with tar mode")
+		}
+		if o.Verify {
+			return fmt.Errorf("verify is not supported with tar mode")
+		}
+	default:
+		return fmt.Errorf("invalid transfer mode %s", o.Mode)
+	}
+
+	switch o.Compression {
+	case "", CompressionNone, CompressionGzip:
+	default:
+		return fmt.Errorf("invalid compression %s", o.Compression)
+	}
+
+	if o.ResumeThreshold < 0 {
+		return fmt.Errorf("invalid resume threshold %d", o.ResumeThreshold)
+	}
+
+	if o.Workers < 0 {
+		return fmt.Errorf("invalid number of workers %d", o.Workers)
+	}
+
+	return nil
+}
+
+// buildIgnoreRules prepares the rules used to filter the files
+// of the tree. The .sshcignore file is always excluded.
+func (o *SyncOpts) buildIgnoreRules(ignoreContent []byte) error {
+	rules := helpers.NewIgnoreRules()
+
+	if err := rules.AddPattern("/" + SshcIgnoreFile); err != nil {
+		return err
+	}
+
+	if len(ignoreContent) > 0 {
+		if err := rules.Parse(ignoreContent); err != nil {
+			return fmt.Errorf("error on parse %s: %s", SshcIgnoreFile, err.Error())
+		}
+	}
+
+	if err := rules.AddPatterns(o.Exclude); err != nil {
+		return err
+	}
+
+	includes := helpers.NewIgnoreRules()
+	if err := includes.AddPatterns(o.Include); err != nil {
+		return err
+	}
+
+	o.rules = rules
+	o.includes = includes
+	return nil
+}
+
+// loadIgnoreRules prepares the rules used to filter the files
+// of the local source with its .sshcignore file.
+func (o *SyncOpts) loadIgnoreRules(source string) error {
+	var ignoreContent []byte
+	ignoreFile := filepath.Join(source, SshcIgnoreFile)
+	if fi, err := os.Stat(ignoreFile); err == nil && fi.Mode().IsRegular() {
+		data, err := os.ReadFile(ignoreFile)
+		if err != nil {
+			return err
+		}
+		ignoreContent = data
+	}
+
+	return o.buildIgnoreRules(ignoreContent)
+}
+
+// isIgnored returns true if the path must be skipped. The
+// directories are skipped only if excluded, the files also
+// if they don't match the include patterns.
+func (o *SyncOpts) isIgnored(relPath string, isDir bool) bool {
+	if o.rules != nil && o.rules.Ignore(relPath, isDir) {
+		return true
+	}
+
+	if !isDir && o.includes != nil && !o.includes.IsEmpty() {
+		return !o.includes.MatchTree(relPath, false)
+	}
+
+	return false
+}
+
+func (o *SyncOpts) getComparePath(targetPath string) string {
+	if o.uploadRoot == "" {
+		return targetPath
+	}
+	return o.compareRoot + strings.TrimPrefix(targetPath, o.uploadRoot)
+}
+
+func (o *SyncOpts) trackPath(remotePath string, isRoot bool) {
+	if o.mirror == nil {
+		return
+	}
+	p := o.getComparePath(remotePath)
+	if isRoot {
+		o.mirror.root = path.Clean(p)
+	}
+	o.mirror.paths[path.Clean(p)] = true
+}
+
+func (o *SyncOpts) isProtected(rel string) bool {
+	for _, pattern := range o.Protect {
+		pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+		if rel == pattern || strings.HasPrefix(rel, pattern+"/") {
+			return true
+		}
+		if m, _ := path.Match(pattern, rel); m {
+			return true
+		}
+	}
+	return false
+}
+
+// hasProtectedChildren returns true if a protected path is
+// under the directory.
+func (o *SyncOpts) hasProtectedChildren(rel string) bool {
+	for _, pattern := range o.Protect {
+		pattern = strings.TrimPrefix(pattern, "/")
+		if strings.HasPrefix(pattern, rel+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SyncStats) AddTransferred(bytes int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Transferred++
+	s.Bytes += bytes
+}
+
+func (s *SyncStats) AddSkipped() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Skipped++
+}
+
+func (s *SyncStats) AddDeleted() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Deleted++
+}
+
+func (s *SyncStats) String() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ans := fmt.Sprintf("transferred %d, skipped %d", s.Transferred, s.Skipped)
+	if s.Deleted > 0 {
+		ans += fmt.Sprintf(", deleted %d", s.Deleted)
+	}
+	return ans
+}
+
+// localTreeSize returns the bytes of the regular files of the
+// local tree not excluded by the ignore rules.
+func (o *SyncOpts) localTreeSize(source string) (int64, error) {
+	var ans int64
+	sourceRoot := filepath.Clean(source)
+
+	err := filepath.Walk(sourceRoot, func(p string, fInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != sourceRoot {
+			rel, _ := filepath.Rel(sourceRoot, p)
+			if o.isIgnored(filepath.ToSlash(rel), fInfo.IsDir()) {
+				if fInfo.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if fInfo.Mode().IsRegular() {
+			ans += fInfo.Size()
+		}
+		return nil
+	})
+
+	return ans, err
+}
+
+// remoteTreeSize returns the bytes of the regular files of the
+// remote tree not excluded by the ignore rules.
+func (s *SshCExecutor) remoteTreeSize(source string, opts *SyncOpts) (int64, error) {
+	var ans int64
+	sourceRoot := path.Clean(source)
+
+	walker := s.SftpClient.Walk(sourceRoot)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return 0, err
+		}
+
+		p := walker.Path()
+		isDir := walker.Stat().IsDir()
+		if p != sourceRoot {
+			rel := strings.TrimPrefix(p, sourceRoot+"/")
+			if opts.isIgnored(rel, isDir) {
+				if isDir {
+					walker.SkipDir()
+				}
+				continue
+			}
+		}
+		if walker.Stat().Mode().IsRegular() {
+			ans += walker.Stat().Size()
+		}
+	}
+
+	return ans, nil
+}
+
+// workerPool runs the file transfers of a sync resource on
+// a fixed number of goroutines. The first error stops the
+// dispatch of new transfers.
+type workerPool struct {
+	jobs chan func() error
+	wg   sync.WaitGroup
+
+	mutex sync.Mutex
+	err   error
+}
+
+func newWorkerPool(workers int) *workerPool {
+	p := &workerPool{
+		jobs: make(chan func() error),
+	}
+
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				if p.Err() != nil {
+					// POST: a previous transfer is failed.
+					continue
+				}
+				if err := job(); err != nil {
+					p.setErr(err)
+				}
+			}
+		}()
+	}
+
+	return p
+}
+
+func (p *workerPool) setErr(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *workerPool) Err() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.err
+}
+
+// Add queues a transfer. It returns the error of a previous
+// failed transfer.
+func (p *workerPool) Add(job func() error) error {
+	if err := p.Err(); err != nil {
+		return err
+	}
+	p.jobs <- job
+	return nil
+}
+
+// Wait waits the end of the queued transfers.
+func (p *workerPool) Wait() error {
+	close(p.jobs)
+	p.wg.Wait()
+	return p.Err()
+}
+
+// mirrorDelete removes the remote files under the destination
+// directory that aren't been written by the sync.
+func (s *SshCExecutor) mirrorDelete(nodeName string, opts *SyncOpts) error {
+	if opts.mirror == nil || opts.mirror.root == "" {
+		// POST: the source is a file. Nothing to do.
+		return nil
+	}
+
+	root := opts.mirror.root
+	if root == "/" || root == "." {
+		return fmt.Errorf("mirror mode is not permitted on destination %s", root)
+	}
+
+	toDelete := []string{}
+	walker := s.SftpClient.Walk(root)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return fmt.Errorf("error on walk %s: %s", walker.Path(), err.Error())
+		}
+
+		p := walker.Path()
+		if p == root || opts.mirror.paths[p] {
+			continue
+		}
+
+		if opts.Backup && backupRegex.MatchString(p) {
+			// Keep the backups of the replaced files.
+			continue
+		}
+
+		rel := strings.TrimPrefix(p, root+"/")
+		isDir := walker.Stat().IsDir()
+		if opts.isProtected(rel) || opts.isIgnored(rel, isDir) {
+			if isDir {
+				walker.SkipDir()
+			}
+			continue
+		}
+
+		if isDir && opts.hasProtectedChildren(rel) {
+			// The directory contains protected files.
+			continue
+		}
+
+		toDelete = append(toDelete, p)
+		if isDir {
+			walker.SkipDir()
+		}
+	}
+
+	for _, p := range toDelete {
+		if opts.DryRun {
+			s.Emitter.InfoLog(false,
+				fmt.Sprintf(">>> [%s] Would delete %s (dry-run)", nodeName, p))
+		} else {
+			s.Emitter.DebugLog(false,
+				fmt.Sprintf(">>> [%s] Deleting %s", nodeName, p))
+
+			var err error
+			if opts.Become != nil {
+				_, err = s.ExecCommandOutput(
+					fmt.Sprintf("rm -rf %s", helpers.ShellQuote(p)), opts.Become)
+			} else {
+				err = s.SftpClient.RemoveAll(p)
+			}
+			if err != nil {
+				return fmt.Errorf("error on delete %s: %s", p, err.Error())
+			}
+		}
+		opts.Stats.AddDeleted()
+	}
+
+	return nil
+}
+
+// isFileChanged returns true if the local file is different from
+// the remote file based on the compare mode.
+func (s *SshCExecutor) isFileChanged(localPath string, fInfo os.FileInfo,
+	remotePath string, opts *SyncOpts) (bool, error) {
+
+	rInfo, err := s.SftpClient.Stat(remotePath)
+	if err != nil || !rInfo.Mode().IsRegular() {
+		// POST: the file doesn't exist or it's not accessible.
+		return true, nil
+	}
+
+	if rInfo.Size() != fInfo.Size() {
+		return true, nil
+	}
+
+	switch opts.Compare {
+	case CompareMtime:
+		if rInfo.ModTime().Unix() != fInfo.ModTime().Unix() {
+			return true, nil
+		}
+	case CompareChecksum:
+		localSum, err := helpers.FileSha256(localPath)
+		if err != nil {
+			return true, err
+		}
+		remoteSum, err := s.RemoteSha256(remotePath, opts.Become)
+		if err != nil {
+			// POST: sha256sum not available. Reading back the file.
+			s.Emitter.DebugLog(false,
+				fmt.Sprintf("Error on run sha256sum for %s: %s. Reading back the file.",
+					remotePath, err.Error()))
+			remoteSum, err = s.RemoteSha256Sftp(remotePath)
+			if err != nil {
+				s.Emitter.DebugLog(false,
+					fmt.Sprintf("Error on retrieve checksum of %s: %s",
+						remotePath, err.Error()))
+				return true, nil
+			}
+		}
+		if localSum != remoteSum {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// RemoteSha256 returns the SHA-256 checksum of a remote file. The
+// checksum is calculated remotely through the sha256sum command
+// to avoid the download of the file.
+func (s *SshCExecutor) RemoteSha256(remotePath string, become *BecomeOpts) (string, error) {
+	out, err := s.ExecCommandOutput(
+		fmt.Sprintf("sha256sum %s", helpers.ShellQuote(remotePath)), become)
+	if err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("invalid sha256sum output for %s", remotePath)
+	}
+
+	return fields[0], nil
+}
+
+// RemoteSha256Sftp returns the SHA-256 checksum of a remote file
+// reading back the content through the SFTP session.
+func (s *SshCExecutor) RemoteSha256Sftp(remotePath string) (string, error) {
+	if s.SftpClient == nil {
+		return "", fmt.Errorf("Sftp client not initialized.")
+	}
+
+	f, err := s.SftpClient.Open(remotePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyChecksum compares the local checksum with the checksum
+// of the remote file retrieved with sha256sum or reading back
+// the file if the command is not available.
+func (s *SshCExecutor) verifyChecksum(localSum, remotePath string) error {
+	remoteSum, err := s.RemoteSha256(remotePath, nil)
+	if err != nil {
+		s.Emitter.DebugLog(false,
+			fmt.Sprintf("Error on run sha256sum for %s: %s. Reading back the file.",
+				remotePath, err.Error()))
+		remoteSum, err = s.RemoteSha256Sftp(remotePath)
+		if err != nil {
+			return fmt.Errorf("error on retrieve checksum of %s: %s",
+				remotePath, err.Error())
+		}
+	}
+
+	if localSum != remoteSum {
+		return fmt.Errorf("checksum mismatch for %s: local %s, remote %s",
+			remotePath, localSum, remoteSum)
+	}
+
+	return nil
+}
@@ -0,0 +1,263 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+
+	. "github.com/MottainaiCI/ssh-compose/pkg/executor"
+	log "github.com/MottainaiCI/ssh-compose/pkg/logger"
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+	tarf_specs "github.com/geaaru/tar-formers/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/sftp"
+)
+
+// newSftpExecutor returns an executor connected to an in-process
+// SFTP server without the support of the exec sessions.
+func newSftpExecutor() *SshCExecutor {
+	serverConn, clientConn := net.Pipe()
+
+	server, err := sftp.NewServer(serverConn)
+	Expect(err).Should(BeNil())
+	go server.Serve()
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	Expect(err).Should(BeNil())
+
+	ans := NewSshCExecutor("test", "127.0.0.1", 22)
+	ans.SftpClient = client
+	return ans
+}
+
+var _ = Describe("Sync files", func() {
+
+	log.NewSshCLogger(specs.NewSshComposeConfig(nil)).SetAsDefault()
+
+	Context("Checksum compare without exec sessions", func() {
+
+		It("Skip unchanged files", func() {
+			executor := newSftpExecutor()
+			defer executor.SftpClient.Close()
+
+			dir, err := os.MkdirTemp("", "sshc-sync")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			// The source paths are relative to the environment directory.
+			cwd, err := os.Getwd()
+			Expect(err).Should(BeNil())
+			Expect(os.Chdir(dir)).Should(BeNil())
+			defer os.Chdir(cwd)
+
+			source := "source/"
+			target := filepath.Join(dir, "target")
+			Expect(os.MkdirAll(source, 0755)).Should(BeNil())
+			Expect(os.WriteFile(filepath.Join(source, "a.txt"), []byte("a\n"), 0644)).Should(BeNil())
+			Expect(os.WriteFile(filepath.Join(source, "b.txt"), []byte("b\n"), 0644)).Should(BeNil())
+
+			push := func() *SyncStats {
+				opts := NewSyncOpts()
+				opts.Compare = CompareChecksum
+				err := executor.RecursivePushFileWithOpts("test", source, target, opts)
+				Expect(err).Should(BeNil())
+				return opts.Stats
+			}
+
+			stats := push()
+			Expect(stats.Transferred).To(Equal(2))
+
+			stats = push()
+			Expect(stats.Transferred).To(Equal(0))
+
+			Expect(os.WriteFile(filepath.Join(source, "a.txt"), []byte("c\n"), 0644)).Should(BeNil())
+			stats = push()
+			Expect(stats.Transferred).To(Equal(1))
+
+			data, err := os.ReadFile(filepath.Join(target, "a.txt"))
+			Expect(err).Should(BeNil())
+			Expect(string(data)).To(Equal("c\n"))
+		})
+	})
+
+	Context("Exclude and include patterns", func() {
+
+		It("Transfer only the included files", func() {
+			executor := newSftpExecutor()
+			defer executor.SftpClient.Close()
+
+			dir, err := os.MkdirTemp("", "sshc-sync")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			cwd, err := os.Getwd()
+			Expect(err).Should(BeNil())
+			Expect(os.Chdir(dir)).Should(BeNil())
+			defer os.Chdir(cwd)
+
+			files := map[string]string{
+				"source/.sshcignore":     "*.tmp\n",
+				"source/app.conf":        "conf",
+				"source/app.tmp":         "tmp",
+				"source/README.md":       "readme",
+				"source/static/a.css":    "css",
+				"source/cache/data.conf": "cache",
+			}
+			for f, content := range files {
+				Expect(os.MkdirAll(filepath.Dir(f), 0755)).Should(BeNil())
+				Expect(os.WriteFile(f, []byte(content), 0644)).Should(BeNil())
+			}
+
+			target := filepath.Join(dir, "target")
+			opts := NewSyncOpts()
+			opts.Exclude = []string{"cache/"}
+			opts.Include = []string{"*.conf", "*.tmp", "static/"}
+			err = executor.RecursivePushFileWithOpts("test", "source/", target, opts)
+			Expect(err).Should(BeNil())
+
+			exists := func(f string) bool {
+				_, err := os.Stat(filepath.Join(target, f))
+				return err == nil
+			}
+
+			Expect(exists("app.conf")).To(BeTrue())
+			Expect(exists("static/a.css")).To(BeTrue())
+			// Not included
+			Expect(exists("README.md")).To(BeFalse())
+			// Excluded by the .sshcignore file
+			Expect(exists("app.tmp")).To(BeFalse())
+			// Excluded directory
+			Expect(exists("cache/data.conf")).To(BeFalse())
+			Expect(exists(".sshcignore")).To(BeFalse())
+		})
+	})
+
+	Context("Backup of the replaced files", func() {
+
+		It("Keep every backup of the same file", func() {
+			executor := newSftpExecutor()
+			defer executor.SftpClient.Close()
+
+			dir, err := os.MkdirTemp("", "sshc-sync")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			cwd, err := os.Getwd()
+			Expect(err).Should(BeNil())
+			Expect(os.Chdir(dir)).Should(BeNil())
+			defer os.Chdir(cwd)
+
+			target := filepath.Join(dir, "target")
+			Expect(os.MkdirAll("source", 0755)).Should(BeNil())
+
+			for _, content := range []string{"a\n", "b\n", "c\n"} {
+				Expect(os.WriteFile("source/app.conf", []byte(content), 0644)).Should(BeNil())
+				opts := NewSyncOpts()
+				opts.Backup = true
+				err := executor.RecursivePushFileWithOpts("test", "source/", target, opts)
+				Expect(err).Should(BeNil())
+			}
+
+			backups, err := filepath.Glob(filepath.Join(target, "app.conf.*"))
+			Expect(err).Should(BeNil())
+			Expect(len(backups)).To(Equal(2))
+
+			data, err := os.ReadFile(filepath.Join(target, "app.conf"))
+			Expect(err).Should(BeNil())
+			Expect(string(data)).To(Equal("c\n"))
+		})
+
+		It("Reject the backup with the scp transfer", func() {
+			executor := NewSshCExecutor("test", "127.0.0.1", 22)
+			executor.Transport = TransportScp
+
+			dir, err := os.MkdirTemp("", "sshc-sync")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			source := filepath.Join(dir, "source")
+			Expect(os.MkdirAll(source, 0755)).Should(BeNil())
+
+			opts := NewSyncOpts()
+			opts.Backup = true
+			err = executor.RecursivePushFileWithOpts("test", source,
+				filepath.Join(dir, "target"), opts)
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).To(ContainSubstring("backup mode"))
+		})
+	})
+
+	Context("Targets of the push", func() {
+
+		It("Resolve the paths with the tar rules and the patterns", func() {
+			executor := newExecExecutor([]string{})
+			defer executor.Client.Close()
+			executor.SftpClient = newSftpExecutor().SftpClient
+
+			dir, err := os.MkdirTemp("", "sshc-sync")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			files := map[string]string{
+				"source/.sshcignore":   "*.tmp\n",
+				"source/app.conf":      "conf",
+				"source/app.tmp":       "tmp",
+				"source/skip.conf":     "skip",
+				"source/etc/a.conf":    "a",
+				"source/cache/b.conf":  "cache",
+				"source/vendor/c.conf": "vendor",
+			}
+			sourceFiles := []string{}
+			for f, content := range files {
+				f = filepath.Join(dir, f)
+				Expect(os.MkdirAll(filepath.Dir(f), 0755)).Should(BeNil())
+				Expect(os.WriteFile(f, []byte(content), 0644)).Should(BeNil())
+				sourceFiles = append(sourceFiles, f)
+			}
+
+			source := filepath.Join(dir, "source")
+			target := filepath.Join(dir, "target")
+
+			newOpts := func() *SyncOpts {
+				opts := NewSyncOpts()
+				opts.Mode = TransferModeTar
+				opts.Exclude = []string{"cache/"}
+				opts.TarRules = tarf_specs.NewSpecFile()
+				opts.TarRules.IgnoreFiles = []string{"skip.conf", "vendor"}
+				opts.TarRules.Rename = []tarf_specs.RenameRule{
+					{Source: "app.conf", Dest: "main.conf"},
+				}
+				return opts
+			}
+
+			err = executor.RecursivePushFileWithOpts("test", source, target, newOpts())
+			Expect(err).Should(BeNil())
+
+			targets, err := executor.GetPushTargets(source, target, sourceFiles, newOpts())
+			Expect(err).Should(BeNil())
+			Expect(targets).To(Equal(map[string]string{
+				filepath.Join(target, "main.conf"):  filepath.Join(source, "app.conf"),
+				filepath.Join(target, "etc/a.conf"): filepath.Join(source, "etc/a.conf"),
+			}))
+
+			pushed := []string{}
+			err = filepath.Walk(target, func(p string, fi os.FileInfo, err error) error {
+				if err == nil && !fi.IsDir() {
+					pushed = append(pushed, p)
+				}
+				return err
+			})
+			Expect(err).Should(BeNil())
+			Expect(pushed).To(HaveLen(len(targets)))
+			for _, p := range pushed {
+				Expect(targets).To(HaveKey(p))
+			}
+		})
+	})
+})
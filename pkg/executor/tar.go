@@ -0,0 +1,266 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/MottainaiCI/ssh-compose/pkg/helpers"
+)
+
+const (
+	// Upload every file through a SFTP request.
+	TransferModeSftp = "sftp"
+	// Stream a tar archive to the tar command of the remote node.
+	TransferModeTar = "tar"
+
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// recursivePushFileWithTar streams the source tree as a tar archive
+// through an exec session and extracts it on the target directory.
+// The tar-formers rules are applied on the fly to filter and to
+// rename the files.
+func (s *SshCExecutor) recursivePushFileWithTar(nodeName, source, target string,
+	opts *SyncOpts) error {
+
+	sourceRoot := filepath.Clean(source)
+	fi, err := os.Lstat(sourceRoot)
+	if err != nil {
+		return err
+	}
+
+	targetDir := path.Clean(target)
+	fileName := ""
+	if !fi.IsDir() {
+		if strings.HasSuffix(target, "/") {
+			fileName = filepath.Base(sourceRoot)
+		} else {
+			targetDir = path.Dir(targetDir)
+			fileName = path.Base(target)
+		}
+	} else {
+		opts.trackPath(targetDir, true)
+	}
+
+	if opts.TarRules != nil {
+		if err := opts.TarRules.Prepare(); err != nil {
+			return fmt.Errorf("error on prepare tar rules: %s", err.Error())
+		}
+	}
+
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+
+	go func() {
+		err := s.writeTarStream(pw, sourceRoot, fi, fileName, targetDir, opts)
+		pw.CloseWithError(err)
+		errCh <- err
+	}()
+
+	tarOpts := "--no-same-owner"
+	if opts.EnsurePerms || opts.perms.HasOwnership() {
+		tarOpts = "--same-owner --numeric-owner -p"
+	} else if opts.perms.fileMode != nil || opts.perms.dirMode != nil {
+		tarOpts += " -p"
+	}
+	if opts.Compression == CompressionGzip {
+		tarOpts += " -z"
+	}
+	if opts.Backup {
+		tarOpts += " --backup=simple --suffix=" + helpers.ShellQuote(opts.backupSuffix)
+	}
+
+	cmd := fmt.Sprintf("mkdir -p %s && tar -C %s %s -xf -",
+		helpers.ShellQuote(targetDir),
+		helpers.ShellQuote(targetDir),
+		tarOpts,
+	)
+
+	s.Emitter.DebugLog(false,
+		fmt.Sprintf("[%s] Streaming %s -> %s (%s)", nodeName, source, target, cmd))
+
+	var errBuffer bytes.Buffer
+	res, err := s.ExecCommand(cmd, pr, nil, &errBuffer, opts.Become)
+	// Unlock the writer if the command is exited before
+	// the end of the stream.
+	pr.Close()
+	werr := <-errCh
+
+	if err != nil {
+		return fmt.Errorf("error on extract tar stream to %s: %s", targetDir, err.Error())
+	}
+	if werr != nil && werr != io.ErrClosedPipe {
+		return fmt.Errorf("error on create tar stream of %s: %s", source, werr.Error())
+	}
+	if res != 0 {
+		return fmt.Errorf("error on extract tar stream to %s (%d): %s",
+			targetDir, res, errBuffer.String())
+	}
+
+	if opts.Delete {
+		return s.mirrorDelete(nodeName, opts)
+	}
+
+	return nil
+}
+
+// useTarStream returns true if the files are pushed with a tar
+// stream and so with the tar rules.
+func (s *SshCExecutor) useTarStream(opts *SyncOpts) bool {
+	if s.useExecTransport() {
+		return s.Transport != TransportScp
+	}
+	return opts.Mode == TransferModeTar
+}
+
+func (s *SshCExecutor) writeTarStream(w io.Writer, sourceRoot string,
+	rootInfo os.FileInfo, fileName, targetDir string, opts *SyncOpts) error {
+
+	var gw *gzip.Writer
+	var tw *tar.Writer
+
+	if opts.Compression == CompressionGzip {
+		gw = gzip.NewWriter(w)
+		tw = tar.NewWriter(gw)
+	} else {
+		tw = tar.NewWriter(w)
+	}
+
+	// addEntry writes the file to the archive. It returns true if
+	// the file is skipped by the tar rules.
+	addEntry := func(p, name string, fInfo os.FileInfo) (bool, error) {
+		if opts.TarRules != nil {
+			if opts.TarRules.IsPath2Skip(name) {
+				s.Emitter.DebugLog(false, fmt.Sprintf("Skipping %s", p))
+				return true, nil
+			}
+			name = opts.TarRules.GetRename(name)
+		}
+
+		link := ""
+		if fInfo.Mode()&os.ModeSymlink != 0 {
+			var err error
+			link, err = os.Readlink(p)
+			if err != nil {
+				return false, err
+			}
+		} else if !fInfo.Mode().IsRegular() && !fInfo.IsDir() {
+			return false, fmt.Errorf("'%s' isn't a supported file type", p)
+		}
+
+		header, err := tar.FileInfoHeader(fInfo, link)
+		if err != nil {
+			return false, err
+		}
+		header.Name = name
+		if fInfo.IsDir() {
+			header.Name += "/"
+		}
+		if !opts.EnsurePerms {
+			header.Uname = ""
+			header.Gname = ""
+		}
+		if opts.perms != nil && fInfo.Mode()&os.ModeSymlink == 0 {
+			if mode := opts.perms.GetMode(fInfo.IsDir()); mode != nil {
+				header.Mode = int64(*mode)
+			}
+			if opts.perms.uid >= 0 {
+				header.Uid = opts.perms.uid
+				header.Uname = ""
+			}
+			if opts.perms.gid >= 0 {
+				header.Gid = opts.perms.gid
+				header.Gname = ""
+			}
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return false, err
+		}
+
+		if fInfo.Mode().IsRegular() {
+			f, err := os.Open(p)
+			if err != nil {
+				return false, err
+			}
+			hasher := sha256.New()
+			_, err = io.Copy(io.MultiWriter(tw, hasher),
+				opts.progress.Reader(p, fInfo.Size(), f))
+			opts.progress.FileDone(p)
+			f.Close()
+			if err != nil {
+				return false, err
+			}
+			if opts.checksums != nil && opts.Verify {
+				opts.checksums[path.Join(targetDir, name)] = hex.EncodeToString(hasher.Sum(nil))
+			}
+		}
+
+		if fInfo.Mode().IsRegular() {
+			opts.Stats.AddTransferred(fInfo.Size())
+		} else if !fInfo.IsDir() {
+			opts.Stats.AddTransferred(0)
+		}
+		opts.trackPath(path.Join(targetDir, name), false)
+
+		return false, nil
+	}
+
+	var err error
+	if !rootInfo.IsDir() {
+		_, err = addEntry(sourceRoot, fileName, rootInfo)
+	} else {
+		err = filepath.Walk(sourceRoot, func(p string, fInfo os.FileInfo, err error) error {
+			if err != nil {
+				return fmt.Errorf("failed to walk path for %s: %s", p, err)
+			}
+			if p == sourceRoot {
+				return nil
+			}
+
+			rel, _ := filepath.Rel(sourceRoot, p)
+			rel = filepath.ToSlash(rel)
+			if opts.isIgnored(rel, fInfo.IsDir()) {
+				if fInfo.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			skipped, err := addEntry(p, rel, fInfo)
+			if err != nil {
+				return err
+			}
+			if skipped && fInfo.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gw != nil {
+		return gw.Close()
+	}
+
+	return nil
+}
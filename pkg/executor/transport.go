@@ -0,0 +1,371 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package executor
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/MottainaiCI/ssh-compose/pkg/helpers"
+)
+
+const (
+	// Transfer the files through the SFTP subsystem.
+	TransportSftp = "sftp"
+	// Transfer the files through the SCP protocol.
+	TransportScp = "scp"
+	// Transfer the files through tar/cat commands.
+	TransportExec = "exec"
+)
+
+func ValidateTransport(t string) error {
+	switch t {
+	case "", TransportSftp, TransportScp, TransportExec:
+		return nil
+	default:
+		return fmt.Errorf("invalid transfer %s", t)
+	}
+}
+
+// UseSftp returns true if the files are transferred through
+// the SFTP client.
+func (s *SshCExecutor) UseSftp() bool {
+	return s.SftpClient != nil
+}
+
+// useExecTransport returns true if the SFTP client is not
+// available and the files are transferred through exec sessions.
+func (s *SshCExecutor) useExecTransport() bool {
+	return s.SftpClient == nil &&
+		(s.Transport == TransportScp || s.Transport == TransportExec)
+}
+
+// prepareExecTransport checks the options supported by the
+// transports without SFTP.
+func (s *SshCExecutor) prepareExecTransport(nodeName string, opts *SyncOpts) error {
+	if opts.Delete {
+		return fmt.Errorf("mirror mode requires the sftp transfer")
+	}
+
+	// The scp command writes the files in place and it doesn't
+	// permit to keep the replaced files.
+	if opts.Backup && s.Transport == TransportScp {
+		return fmt.Errorf("backup mode is not supported by the scp transfer")
+	}
+
+	if opts.Compare != "" {
+		s.Emitter.WarnLog(false,
+			fmt.Sprintf("[%s] Compare mode is not supported by the %s transfer. All files are transferred.",
+				nodeName, s.Transport))
+		opts.Compare = ""
+	}
+
+	// The data are streamed through the stdin or the stdout of the
+	// commands that with a pseudo terminal are altered.
+	if opts.Become != nil && opts.Become.UsePty() {
+		return fmt.Errorf("become method %s is not supported by the %s transfer",
+			opts.Become.Method, s.Transport)
+	}
+
+	opts.checksums = make(map[string]string, 0)
+
+	return nil
+}
+
+// verifyChecksums compares the checksums of the local files
+// with the checksums of the transferred files.
+func (s *SshCExecutor) verifyChecksums(opts *SyncOpts) error {
+	paths := []string{}
+	for p := range opts.checksums {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		remoteSum, err := s.RemoteSha256(p, opts.Become)
+		if err != nil {
+			return fmt.Errorf("error on retrieve checksum of %s: %s", p, err.Error())
+		}
+		if remoteSum != opts.checksums[p] {
+			return fmt.Errorf("checksum mismatch for %s: local %s, remote %s",
+				p, opts.checksums[p], remoteSum)
+		}
+	}
+
+	return nil
+}
+
+func (s *SshCExecutor) recursivePushFileWithExec(nodeName, source, target string,
+	opts *SyncOpts) error {
+
+	if err := s.prepareExecTransport(nodeName, opts); err != nil {
+		return err
+	}
+
+	var err error
+	if s.Transport == TransportScp {
+		err = s.recursivePushFileWithScp(nodeName, source, target, opts)
+	} else {
+		err = s.recursivePushFileWithTar(nodeName, source, target, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.Verify {
+		return s.verifyChecksums(opts)
+	}
+
+	return nil
+}
+
+func (s *SshCExecutor) recursivePullFileWithExec(nodeName, sourcePath, targetPath string,
+	localAsTarget bool, opts *SyncOpts) error {
+
+	if err := s.prepareExecTransport(nodeName, opts); err != nil {
+		return err
+	}
+
+	target := targetPath
+	if !localAsTarget {
+		target = filepath.Join(targetPath, sourcePath)
+	}
+
+	var err error
+	if s.Transport == TransportScp {
+		err = s.recursivePullFileWithScp(nodeName, sourcePath, target, opts)
+	} else {
+		err = s.recursivePullFileWithTar(nodeName, sourcePath, target, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.Verify {
+		return s.verifyChecksums(opts)
+	}
+
+	return nil
+}
+
+// readRemoteFileExec returns the content of a remote file through
+// the cat command. It returns nil if the file doesn't exist.
+func (s *SshCExecutor) readRemoteFileExec(remotePath string, become *BecomeOpts) []byte {
+	var outBuffer bytes.Buffer
+
+	res, err := s.ExecCommand(
+		fmt.Sprintf("cat %s", helpers.ShellQuote(remotePath)),
+		nil, &outBuffer, nil, become)
+	if err != nil || res != 0 {
+		return nil
+	}
+
+	return outBuffer.Bytes()
+}
+
+// uploadContentExec writes the content to the remote path through
+// the cat command.
+func (s *SshCExecutor) uploadContentExec(targetPath string, content []byte, mode os.FileMode) error {
+	uploadPath := getUploadTmpPath(targetPath)
+
+	cmd := fmt.Sprintf("cat > %s && chmod %o %s && mv -f %s %s || { rm -f %s; exit 1; }",
+		helpers.ShellQuote(uploadPath),
+		mode.Perm(), helpers.ShellQuote(uploadPath),
+		helpers.ShellQuote(uploadPath), helpers.ShellQuote(targetPath),
+		helpers.ShellQuote(uploadPath),
+	)
+
+	var errBuffer bytes.Buffer
+	res, err := s.ExecCommand(cmd, bytes.NewReader(content), nil, &errBuffer, nil)
+	if err != nil {
+		return fmt.Errorf("error on upload %s: %s", targetPath, err.Error())
+	}
+	if res != 0 {
+		return fmt.Errorf("error on upload %s (%d): %s",
+			targetPath, res, errBuffer.String())
+	}
+
+	return nil
+}
+
+// recursivePullFileWithTar streams the remote tree as a tar archive
+// through an exec session and extracts it on the local target.
+func (s *SshCExecutor) recursivePullFileWithTar(nodeName, sourcePath, target string,
+	opts *SyncOpts) error {
+
+	sourceClean := path.Clean(sourcePath)
+
+	tarOpts := ""
+	if opts.Compression == CompressionGzip {
+		tarOpts = " -z"
+	}
+
+	cmd := fmt.Sprintf("tar -C %s%s -cf - %s",
+		helpers.ShellQuote(path.Dir(sourceClean)),
+		tarOpts,
+		helpers.ShellQuote(path.Base(sourceClean)),
+	)
+
+	s.Emitter.DebugLog(false,
+		fmt.Sprintf("[%s] Streaming %s -> %s (%s)", nodeName, sourcePath, target, cmd))
+
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+
+	go func() {
+		err := s.extractTarStream(pr, sourceClean, target, opts)
+		// Unlock the remote command if the extraction is
+		// failed before the end of the stream.
+		pr.CloseWithError(err)
+		errCh <- err
+	}()
+
+	var errBuffer bytes.Buffer
+	res, err := s.ExecCommand(cmd, nil, pw, &errBuffer, opts.Become)
+	pw.Close()
+	xerr := <-errCh
+
+	if xerr != nil {
+		return fmt.Errorf("error on extract tar stream to %s: %s", target, xerr.Error())
+	}
+	if err != nil {
+		return fmt.Errorf("error on create tar stream of %s: %s", sourcePath, err.Error())
+	}
+	if res != 0 {
+		return fmt.Errorf("error on create tar stream of %s (%d): %s",
+			sourcePath, res, errBuffer.String())
+	}
+
+	return nil
+}
+
+func (s *SshCExecutor) extractTarStream(r io.Reader, sourceClean, target string,
+	opts *SyncOpts) error {
+
+	if opts.Compression == CompressionGzip {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	base := path.Base(sourceClean)
+	skipDirs := []string{}
+	tr := tar.NewReader(r)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(header.Name)
+		if name != base && !strings.HasPrefix(name, base+"/") {
+			return fmt.Errorf("unexpected entry %s", header.Name)
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(name, base), "/")
+		if rel == ".." || strings.HasPrefix(rel, "../") || strings.Contains(rel, "/../") {
+			return fmt.Errorf("invalid entry %s", header.Name)
+		}
+
+		skip := false
+		for _, d := range skipDirs {
+			if strings.HasPrefix(rel, d+"/") {
+				skip = true
+				break
+			}
+		}
+		isDir := header.Typeflag == tar.TypeDir
+		if skip || (rel != "" && opts.isIgnored(rel, isDir)) {
+			if isDir && !skip {
+				skipDirs = append(skipDirs, rel)
+			}
+			continue
+		}
+
+		dest := target
+		if rel != "" {
+			dest = filepath.Join(target, filepath.FromSlash(rel))
+		}
+		mode := os.FileMode(header.Mode).Perm()
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dest, mode); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if opts.filters.Skip(header.Size, header.ModTime) {
+				opts.Stats.AddSkipped()
+				opts.progress.Skip(header.Size)
+				continue
+			}
+
+			f, err := os.Create(dest)
+			if err != nil {
+				return err
+			}
+
+			hasher := sha256.New()
+			_, err = io.Copy(io.MultiWriter(f, hasher),
+				opts.progress.Reader(dest, header.Size, tr))
+			opts.progress.FileDone(dest)
+			f.Close()
+			if err != nil {
+				return err
+			}
+
+			if err := os.Chmod(dest, mode); err != nil {
+				return err
+			}
+
+			if opts.Verify {
+				opts.checksums[path.Join(sourceClean, rel)] = hex.EncodeToString(hasher.Sum(nil))
+			}
+			opts.Stats.AddTransferred(header.Size)
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, dest); err != nil {
+				return err
+			}
+			opts.Stats.AddTransferred(0)
+			continue
+		default:
+			s.Emitter.DebugLog(false,
+				fmt.Sprintf("Skipping unsupported entry %s", header.Name))
+			continue
+		}
+
+		if opts.EnsurePerms {
+			if err := os.Chown(dest, header.Uid, header.Gid); err != nil {
+				return err
+			}
+		}
+
+		if err := applyLocalPerms(dest, isDir, opts.perms); err != nil {
+			return err
+		}
+
+		if !isDir {
+			_ = os.Chtimes(dest, header.ModTime, header.ModTime)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package helpers
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3
+
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// UnifiedDiff returns the differences between the two contents in
+// the unified format. It returns an empty string if the contents
+// are equal.
+func UnifiedDiff(fromFile, toFile, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", fromFile, toFile))
+
+	// Positions of the changed lines.
+	changes := []int{}
+	for idx, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, idx)
+		}
+	}
+
+	for c := 0; c < len(changes); {
+		start := changes[c] - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := changes[c]
+		for c < len(changes) && changes[c] <= end+2*diffContext {
+			end = changes[c]
+			c++
+		}
+		end += diffContext
+		if end >= len(ops) {
+			end = len(ops) - 1
+		}
+
+		// Line numbers of the hunk start.
+		aLine, bLine := 1, 1
+		for _, op := range ops[:start] {
+			if op.kind != '+' {
+				aLine++
+			}
+			if op.kind != '-' {
+				bLine++
+			}
+		}
+
+		aLen, bLen := 0, 0
+		var body strings.Builder
+		for _, op := range ops[start : end+1] {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+			body.WriteByte(op.kind)
+			body.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				body.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		if aLen == 0 {
+			aLine--
+		}
+		if bLen == 0 {
+			bLine--
+		}
+
+		sb.WriteString(fmt.Sprintf("@@ -%s +%s @@\n",
+			hunkRange(aLine, aLen), hunkRange(bLine, bLen)))
+		sb.WriteString(body.String())
+	}
+
+	return sb.String()
+}
+
+func hunkRange(start, length int) string {
+	if length == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, length)
+}
+
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the edit script between the lines with
+// the Myers algorithm.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	trace := [][]int{}
+
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		vc := make([]int, len(v))
+		copy(vc, v)
+		trace = append(trace, vc)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// Backtrack the edit script.
+	ops := []diffOp{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		vd := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && vd[offset+k-1] < vd[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := vd[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{kind: ' ', line: a[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				y--
+				ops = append(ops, diffOp{kind: '+', line: b[y]})
+			} else {
+				x--
+				ops = append(ops, diffOp{kind: '-', line: a[x]})
+			}
+		}
+	}
+
+	// Reverse the operations.
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
@@ -0,0 +1,42 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package helpers_test
+
+import (
+	. "github.com/MottainaiCI/ssh-compose/pkg/helpers"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diff", func() {
+
+	Context("UnifiedDiff", func() {
+
+		It("Equal contents", func() {
+			Expect(UnifiedDiff("a", "b", "x\ny\n", "x\ny\n")).To(Equal(""))
+		})
+
+		It("Changed line", func() {
+			a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+			b := "1\n2\n3\n4\n5\nsix\n7\n8\n9\n10\n"
+			Expect(UnifiedDiff("remote", "local", a, b)).To(Equal(
+				"--- remote\n+++ local\n@@ -3,7 +3,7 @@\n 3\n 4\n 5\n-6\n+six\n 7\n 8\n 9\n"))
+		})
+
+		It("Separated hunks", func() {
+			a := "a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n"
+			b := "A\n1\n2\n3\n4\n5\n6\n7\n8\nb\nc\n"
+			Expect(UnifiedDiff("remote", "local", a, b)).To(Equal(
+				"--- remote\n+++ local\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n" +
+					"@@ -8,3 +8,4 @@\n 7\n 8\n b\n+c\n"))
+		})
+
+		It("New file", func() {
+			Expect(UnifiedDiff("/dev/null", "local", "", "x\ny")).To(Equal(
+				"--- /dev/null\n+++ local\n@@ -0,0 +1,2 @@\n+x\n+y\n\\ No newline at end of file\n"))
+		})
+	})
+})
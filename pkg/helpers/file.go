@@ -5,6 +5,9 @@ See AUTHORS and LICENSE for the license details and contributors.
 package helpers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"os"
 )
 
@@ -17,3 +20,20 @@ func Exists(name string) bool {
 	}
 	return true
 }
+
+// FileSha256 returns the SHA-256 checksum of the file as
+// hex string.
+func FileSha256(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
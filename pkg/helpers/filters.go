@@ -0,0 +1,81 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package helpers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HasGlobMeta returns true if the path contains glob special characters.
+func HasGlobMeta(p string) bool {
+	return strings.ContainsAny(p, `*?[\`)
+}
+
+// ParseSize parses a size in bytes with an optional unit
+// suffix: K, M, G, T (powers of 1024).
+func ParseSize(s string) (int64, error) {
+	str := strings.TrimSpace(strings.ToUpper(s))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "B"), "I")
+	if str == "" {
+		return 0, fmt.Errorf("invalid size %s", s)
+	}
+
+	mult := int64(1)
+	switch str[len(str)-1] {
+	case 'K':
+		mult = 1 << 10
+	case 'M':
+		mult = 1 << 20
+	case 'G':
+		mult = 1 << 30
+	case 'T':
+		mult = 1 << 40
+	}
+	if mult > 1 {
+		str = str[:len(str)-1]
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %s", s)
+	}
+
+	return int64(v * float64(mult)), nil
+}
+
+// ParseNewerThan returns the minimum modification time from a
+// duration relative to now (ex. 30m, 12h, 7d) or from a date
+// in RFC3339 or YYYY-MM-DD format.
+func ParseNewerThan(s string, now time.Time) (time.Time, error) {
+	str := strings.TrimSpace(s)
+	if str == "" {
+		return time.Time{}, fmt.Errorf("invalid newer_than value")
+	}
+
+	if t, err := time.Parse(time.RFC3339, str); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", str, time.Local); err == nil {
+		return t, nil
+	}
+
+	if strings.HasSuffix(str, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(str, "d"), 64)
+		if err != nil || days < 0 {
+			return time.Time{}, fmt.Errorf("invalid newer_than value %s", s)
+		}
+		return now.Add(-time.Duration(days * float64(24*time.Hour))), nil
+	}
+
+	d, err := time.ParseDuration(str)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid newer_than value %s", s)
+	}
+
+	return now.Add(-d), nil
+}
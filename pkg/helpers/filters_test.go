@@ -0,0 +1,69 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package helpers_test
+
+import (
+	"time"
+
+	. "github.com/MottainaiCI/ssh-compose/pkg/helpers"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("", func() {
+
+	Context("ParseSize", func() {
+
+		It("Bytes", func() {
+			Expect(ParseSize("512")).To(Equal(int64(512)))
+		})
+
+		It("Units", func() {
+			Expect(ParseSize("10K")).To(Equal(int64(10240)))
+			Expect(ParseSize("1.5M")).To(Equal(int64(1572864)))
+			Expect(ParseSize("2GiB")).To(Equal(int64(2147483648)))
+			Expect(ParseSize("1mb")).To(Equal(int64(1048576)))
+		})
+
+		It("Invalid", func() {
+			_, err := ParseSize("10X")
+			Expect(err).ShouldNot(BeNil())
+			_, err = ParseSize("")
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("ParseNewerThan", func() {
+
+		now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+		It("Duration", func() {
+			Expect(ParseNewerThan("2h", now)).To(Equal(now.Add(-2 * time.Hour)))
+		})
+
+		It("Days", func() {
+			Expect(ParseNewerThan("7d", now)).To(Equal(now.Add(-7 * 24 * time.Hour)))
+		})
+
+		It("Date", func() {
+			Expect(ParseNewerThan("2025-03-01T00:00:00Z", now)).To(
+				Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)))
+		})
+
+		It("Invalid", func() {
+			_, err := ParseNewerThan("yesterday", now)
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("HasGlobMeta", func() {
+
+		It("Glob", func() {
+			Expect(HasGlobMeta("/var/log/app/*.log")).To(BeTrue())
+			Expect(HasGlobMeta("/var/log/app/")).To(BeFalse())
+		})
+	})
+})
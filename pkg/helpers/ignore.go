@@ -0,0 +1,170 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package helpers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+)
+
+type ignorePattern struct {
+	raw      string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+	segments []string
+}
+
+// IgnoreRules is a list of gitignore-style patterns. The patterns
+// are evaluated in order and the last matching pattern wins.
+type IgnoreRules struct {
+	patterns []*ignorePattern
+}
+
+func NewIgnoreRules() *IgnoreRules {
+	return &IgnoreRules{
+		patterns: []*ignorePattern{},
+	}
+}
+
+func (r *IgnoreRules) IsEmpty() bool { return len(r.patterns) == 0 }
+
+// AddPattern parses a gitignore-style pattern:
+//   - a leading ! negates the pattern
+//   - a trailing / matches only directories
+//   - a pattern without slash matches the name at any level
+//   - a pattern with a slash is relative to the root
+//   - ** matches any number of directories
+func (r *IgnoreRules) AddPattern(pattern string) error {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return nil
+	}
+
+	p := &ignorePattern{raw: pattern}
+
+	if strings.HasPrefix(pattern, "!") {
+		p.negate = true
+		pattern = pattern[1:]
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+
+	if strings.Contains(pattern, "/") {
+		p.anchored = true
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+
+	if pattern == "" {
+		return fmt.Errorf("invalid pattern %s", p.raw)
+	}
+
+	p.segments = strings.Split(pattern, "/")
+	for _, seg := range p.segments {
+		if _, err := path.Match(seg, "abc"); err != nil {
+			return fmt.Errorf("invalid pattern %s: %s", p.raw, err.Error())
+		}
+	}
+
+	r.patterns = append(r.patterns, p)
+	return nil
+}
+
+func (r *IgnoreRules) AddPatterns(patterns []string) error {
+	for _, p := range patterns {
+		if err := r.AddPattern(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parse adds the patterns of an ignore file content.
+func (r *IgnoreRules) Parse(content []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if err := r.AddPattern(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Ignore returns true if the path (relative to the root of the
+// tree) must be ignored.
+func (r *IgnoreRules) Ignore(relPath string, isDir bool) bool {
+	relPath = strings.Trim(path.Clean(relPath), "/")
+	if relPath == "." || relPath == "" {
+		return false
+	}
+
+	ans := false
+	for _, p := range r.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.match(relPath) {
+			ans = !p.negate
+		}
+	}
+
+	return ans
+}
+
+// MatchTree returns true if the path or one of its parent
+// directories match the rules.
+func (r *IgnoreRules) MatchTree(relPath string, isDir bool) bool {
+	relPath = strings.Trim(path.Clean(relPath), "/")
+	if r.Ignore(relPath, isDir) {
+		return true
+	}
+	for dir := path.Dir(relPath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if r.Ignore(dir, true) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *ignorePattern) match(relPath string) bool {
+	if !p.anchored {
+		m, _ := path.Match(p.segments[0], path.Base(relPath))
+		return m
+	}
+	return matchSegments(p.segments, strings.Split(relPath, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try to consume any number of directories.
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+
+		m, _ := path.Match(pattern[0], parts[0])
+		if !m {
+			return false
+		}
+		pattern = pattern[1:]
+		parts = parts[1:]
+	}
+
+	return len(parts) == 0
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package helpers_test
+
+import (
+	. "github.com/MottainaiCI/ssh-compose/pkg/helpers"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("", func() {
+
+	Context("IgnoreRules", func() {
+
+		r := NewIgnoreRules()
+		err := r.Parse([]byte(`
+# Comment
+.git/
+*.pyc
+/secrets
+docs/**/*.tmp
+!keep.pyc
+`))
+
+		It("Parse", func() {
+			Expect(err).Should(BeNil())
+			Expect(r.IsEmpty()).To(BeFalse())
+		})
+
+		It("Directory only pattern", func() {
+			Expect(r.Ignore(".git", true)).To(BeTrue())
+			Expect(r.Ignore("sub/.git", true)).To(BeTrue())
+			Expect(r.Ignore(".git", false)).To(BeFalse())
+		})
+
+		It("Basename pattern", func() {
+			Expect(r.Ignore("a.pyc", false)).To(BeTrue())
+			Expect(r.Ignore("lib/a/b.pyc", false)).To(BeTrue())
+			Expect(r.Ignore("lib/a/b.py", false)).To(BeFalse())
+		})
+
+		It("Anchored pattern", func() {
+			Expect(r.Ignore("secrets", false)).To(BeTrue())
+			Expect(r.Ignore("sub/secrets", false)).To(BeFalse())
+		})
+
+		It("Double star pattern", func() {
+			Expect(r.Ignore("docs/a.tmp", false)).To(BeTrue())
+			Expect(r.Ignore("docs/a/b/c.tmp", false)).To(BeTrue())
+			Expect(r.Ignore("src/a.tmp", false)).To(BeFalse())
+		})
+
+		It("Negate pattern", func() {
+			Expect(r.Ignore("lib/keep.pyc", false)).To(BeFalse())
+		})
+
+		It("Invalid pattern", func() {
+			Expect(NewIgnoreRules().AddPattern("[a")).ShouldNot(BeNil())
+		})
+
+		It("Match tree", func() {
+			t := NewIgnoreRules()
+			Expect(t.AddPatterns([]string{"static/", "*.conf"})).Should(BeNil())
+			Expect(t.MatchTree("static/css/a.css", false)).To(BeTrue())
+			Expect(t.MatchTree("etc/app.conf", false)).To(BeTrue())
+			Expect(t.MatchTree("etc/app.yml", false)).To(BeFalse())
+		})
+	})
+})
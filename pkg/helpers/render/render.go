@@ -61,57 +61,87 @@ func RenderContentWithTemplates(
 	overrideValues map[string]interface{},
 	templateDirs []string) (string, error) {
 
-	var err error
+	valuesFiles := []string{}
+	if valuesFile != "" {
+		valuesFiles = append(valuesFiles, valuesFile)
+	}
 
-	if valuesFile == "" && defaultFile == "" {
-		return "", errors.New("Both render files are missing")
+	return RenderContentWithValues(raw, valuesFiles, defaultFile, originFile,
+		overrideValues, templateDirs)
+}
+
+// loadValuesFile reads the YAML values of the render file.
+func loadValuesFile(file string) (map[string]interface{}, error) {
+	ans := make(map[string]interface{}, 0)
+
+	if _, err := os.Stat(file); err != nil && os.IsNotExist(err) {
+		return ans, errors.New(fmt.Sprintf(
+			"Render value file %s not existing ", file))
 	}
 
-	// Avoid dep cycles importing helpers
-	exists := func(name string) bool {
-		if _, err := os.Stat(name); err != nil {
-			if os.IsNotExist(err) {
-				return false
-			}
+	val, err := os.ReadFile(file)
+	if err != nil {
+		return ans, errors.New(fmt.Sprintf(
+			"Error on reading Render value file %s: %s", file, err.Error()))
+	}
+
+	if err = yaml.Unmarshal(val, &ans); err != nil {
+		return ans, errors.New(fmt.Sprintf(
+			"Error on unmarshal file %s: %s", file, err.Error()))
+	}
+
+	if ans == nil {
+		ans = make(map[string]interface{}, 0)
+	}
+
+	return ans, nil
+}
+
+// MergeValues merges the src values over the dst values. The maps
+// are merged recursively, the other values of src replace the
+// values of dst.
+func MergeValues(dst, src map[string]interface{}) map[string]interface{} {
+	for k, v := range src {
+		srcMap, isSrcMap := v.(map[string]interface{})
+		dstMap, isDstMap := dst[k].(map[string]interface{})
+		if isSrcMap && isDstMap {
+			dst[k] = MergeValues(dstMap, srcMap)
+		} else {
+			dst[k] = v
 		}
-		return true
+	}
+	return dst
+}
+
+// RenderContentWithValues renders the content with the values of
+// the ordered list of values files. The files are merged deeply and
+// the last files win.
+func RenderContentWithValues(
+	raw string, valuesFiles []string, defaultFile, originFile string,
+	overrideValues map[string]interface{},
+	templateDirs []string) (string, error) {
+
+	var err error
+
+	if len(valuesFiles) == 0 && defaultFile == "" {
+		return "", errors.New("Both render files are missing")
 	}
 
 	values := make(map[string]interface{}, 0)
 	d := make(map[string]interface{}, 0)
 
-	if valuesFile != "" {
-		if !exists(valuesFile) {
-			return "", errors.New(fmt.Sprintf(
-				"Render value file %s not existing ", valuesFile))
-		}
-		val, err := os.ReadFile(valuesFile)
+	for _, valuesFile := range valuesFiles {
+		val, err := loadValuesFile(valuesFile)
 		if err != nil {
-			return "", errors.New(fmt.Sprintf(
-				"Error on reading Render value file %s: %s", valuesFile, err.Error()))
-		}
-
-		if err = yaml.Unmarshal(val, &values); err != nil {
-			return "", errors.New(fmt.Sprintf(
-				"Error on unmarsh file %s: %s", valuesFile, err.Error()))
+			return "", err
 		}
+		values = MergeValues(values, val)
 	}
 
 	if defaultFile != "" {
-		if !exists(defaultFile) {
-			return "", errors.New(fmt.Sprintf(
-				"Render value file %s not existing ", defaultFile))
-		}
-
-		def, err := os.ReadFile(defaultFile)
+		d, err = loadValuesFile(defaultFile)
 		if err != nil {
-			return "", errors.New(fmt.Sprintf(
-				"Error on reading Render value file %s: %s", valuesFile, err.Error()))
-		}
-
-		if err = yaml.Unmarshal(def, &d); err != nil {
-			return "", errors.New(fmt.Sprintf(
-				"Error on unmarshal file %s: %s", defaultFile, err.Error()))
+			return "", err
 		}
 	}
 
@@ -1,6 +1,10 @@
 package helpers_render_test
 
 import (
+	"os"
+	"path/filepath"
+
+	. "github.com/MottainaiCI/ssh-compose/pkg/helpers/render"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/engine"
@@ -153,4 +157,72 @@ val: "1"
 		})
 	})
 
+	Context("Layered values files", func() {
+
+		It("Merge values files in order", func() {
+			dir, err := os.MkdirTemp("", "sshc-render")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			files := map[string]string{
+				"default.yml": `
+app:
+  name: "myapp"
+  replicas: 1
+  port: 80
+`,
+				"values.yml": `
+app:
+  replicas: 2
+  tags:
+    - common
+`,
+				"production.yml": `
+app:
+  replicas: 5
+  tags:
+    - prod
+env: production
+`,
+			}
+			for name, content := range files {
+				Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).Should(BeNil())
+			}
+
+			out, err := RenderContentWithValues(
+				"{{ .Values.app.name }}-{{ .Values.app.replicas }}-{{ .Values.app.port }}-{{ .Values.app.tags }}-{{ .Values.env }}",
+				[]string{
+					filepath.Join(dir, "values.yml"),
+					filepath.Join(dir, "production.yml"),
+				},
+				filepath.Join(dir, "default.yml"),
+				"test", nil, []string{},
+			)
+			Expect(err).Should(BeNil())
+			Expect(out).To(Equal("myapp-5-80-[prod]-production"))
+		})
+
+		It("Missing values file", func() {
+			_, err := RenderContentWithValues("{{ .Values.env }}",
+				[]string{"/not-existing-values.yml"}, "", "test", nil, []string{})
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Merge maps", func() {
+			Expect(MergeValues(
+				map[string]interface{}{
+					"a": map[string]interface{}{"b": 1, "c": 2},
+					"d": 1,
+				},
+				map[string]interface{}{
+					"a": map[string]interface{}{"c": 3},
+					"d": map[string]interface{}{"e": 1},
+				},
+			)).To(Equal(map[string]interface{}{
+				"a": map[string]interface{}{"b": 1, "c": 3},
+				"d": map[string]interface{}{"e": 1},
+			}))
+		})
+	})
+
 })
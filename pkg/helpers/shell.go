@@ -0,0 +1,61 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package helpers
+
+import (
+	"regexp"
+	"strings"
+)
+
+var shellVarRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ShellQuote returns the string quoted to be used as a single
+// argument of a POSIX shell.
+func ShellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// ShellJoin returns the arguments quoted and joined as a
+// single command line of a POSIX shell.
+func ShellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for idx, a := range args {
+		quoted[idx] = ShellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// ShellJoinBatches returns the arguments quoted and joined in
+// multiple command lines no longer than maxLen bytes, to avoid
+// the ARG_MAX limit with a long list of paths. An argument longer
+// than maxLen is returned alone.
+func ShellJoinBatches(args []string, maxLen int) []string {
+	ans := []string{}
+	line := ""
+	for _, a := range args {
+		q := ShellQuote(a)
+		if line != "" && len(line)+1+len(q) > maxLen {
+			ans = append(ans, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += q
+	}
+	if line != "" {
+		ans = append(ans, line)
+	}
+	return ans
+}
+
+// IsValidShellVar returns true if the string could be used
+// as the name of a shell variable.
+func IsValidShellVar(s string) bool {
+	return shellVarRegex.MatchString(s)
+}
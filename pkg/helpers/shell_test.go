@@ -0,0 +1,77 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package helpers_test
+
+import (
+	. "github.com/MottainaiCI/ssh-compose/pkg/helpers"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("", func() {
+
+	Context("ShellQuote", func() {
+
+		It("Empty string", func() {
+			Expect(ShellQuote("")).To(Equal("''"))
+		})
+
+		It("Simple string", func() {
+			Expect(ShellQuote("echo $HOME")).To(Equal("'echo $HOME'"))
+		})
+
+		It("String with single quotes", func() {
+			Expect(ShellQuote("echo 'foo'")).To(Equal(`'echo '"'"'foo'"'"''`))
+		})
+
+		It("Multiline string", func() {
+			Expect(ShellQuote("echo 1\necho 2")).To(Equal("'echo 1\necho 2'"))
+		})
+	})
+
+	Context("ShellJoin", func() {
+
+		It("Entrypoint with spaces", func() {
+			Expect(ShellJoin([]string{"/opt/my bin/sh", "-c"})).To(
+				Equal("'/opt/my bin/sh' '-c'"))
+		})
+
+		It("Empty args", func() {
+			Expect(ShellJoin([]string{})).To(Equal(""))
+		})
+	})
+
+	Context("ShellJoinBatches", func() {
+
+		It("Split on the max length", func() {
+			Expect(ShellJoinBatches([]string{"a", "b", "c"}, 7)).To(
+				Equal([]string{"'a' 'b'", "'c'"}))
+		})
+
+		It("Argument longer than the max length", func() {
+			Expect(ShellJoinBatches([]string{"a", "long", "b"}, 5)).To(
+				Equal([]string{"'a'", "'long'", "'b'"}))
+		})
+
+		It("Empty args", func() {
+			Expect(ShellJoinBatches([]string{}, 10)).To(BeEmpty())
+		})
+	})
+
+	Context("IsValidShellVar", func() {
+
+		It("Valid names", func() {
+			Expect(IsValidShellVar("FOO_1")).To(BeTrue())
+			Expect(IsValidShellVar("_foo")).To(BeTrue())
+		})
+
+		It("Invalid names", func() {
+			Expect(IsValidShellVar("1FOO")).To(BeFalse())
+			Expect(IsValidShellVar("foo-bar")).To(BeFalse())
+			Expect(IsValidShellVar("")).To(BeFalse())
+		})
+	})
+})
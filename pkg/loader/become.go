@@ -0,0 +1,47 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package loader
+
+import (
+	"fmt"
+
+	ssh_executor "github.com/MottainaiCI/ssh-compose/pkg/executor"
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+)
+
+// getBecomeOpts returns the become options of the executor or nil
+// if the privilege escalation is not enabled.
+func (i *SshCInstance) getBecomeOpts(executor *ssh_executor.SshCExecutor,
+	proj *specs.SshCProject, group *specs.SshCGroup, node *specs.SshCNode,
+	hook *specs.SshCHook) (*ssh_executor.BecomeOpts, error) {
+
+	become := specs.ResolveBecome(group, node, hook)
+	if !become.Enabled {
+		return nil, nil
+	}
+
+	pass := executor.BecomePass
+	if become.PassVar != "" {
+		v, present := proj.GetEnvVar(become.PassVar)
+		if !present {
+			return nil, fmt.Errorf("become password variable %s not found",
+				become.PassVar)
+		}
+
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("become password variable %s is not a string",
+				become.PassVar)
+		}
+		pass = s
+	}
+
+	ans := ssh_executor.NewBecomeOpts(become.User, become.Method, pass)
+	if err := ans.Validate(); err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package loader
+
+import (
+	"fmt"
+
+	ssh_executor "github.com/MottainaiCI/ssh-compose/pkg/executor"
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+	"github.com/MottainaiCI/ssh-compose/pkg/template"
+)
+
+// compiledFiles tracks the files compiled by the current deploy
+// for the project, the group and the node in processing.
+type compiledFiles struct {
+	project  []string
+	group    []string
+	node     []string
+	nodeName string
+}
+
+// setupCompileCache loads the compile cache of the environment
+// if it's enabled.
+func (i *SshCInstance) setupCompileCache(env *specs.SshCEnvironment) error {
+	i.compileCache = nil
+	i.compiled = compiledFiles{}
+
+	if !i.Config.GetGeneral().CompileCache {
+		return nil
+	}
+
+	cache, err := template.NewEnvCompileCache(env)
+	if err != nil {
+		return fmt.Errorf("error on load compile cache: %s", err.Error())
+	}
+	i.compileCache = cache
+
+	return nil
+}
+
+func (i *SshCInstance) saveCompileCache() {
+	if i.compileCache == nil {
+		return
+	}
+
+	err := i.compileCache.Save()
+	if err != nil {
+		i.Logger.Warning(fmt.Sprintf("Error on save compile cache %s: %s",
+			i.compileCache.File, err.Error()))
+	}
+}
+
+// getCompilerOpts returns the compiler options that store the
+// compiled files in the input list.
+func (i *SshCInstance) getCompilerOpts(files *[]string) template.CompilerOpts {
+	*files = []string{}
+	return template.CompilerOpts{
+		Cache: i.compileCache,
+		OnCompiled: func(f string) {
+			*files = append(*files, f)
+		},
+	}
+}
+
+// getCompiledFiles returns the files compiled for the node. With
+// a nil node are returned only the files of the project and of
+// the group.
+func (i *SshCInstance) getCompiledFiles(node *specs.SshCNode) []string {
+	ans := append([]string{}, i.compiled.project...)
+	ans = append(ans, i.compiled.group...)
+	if node != nil && node.GetName() == i.compiled.nodeName {
+		ans = append(ans, i.compiled.node...)
+	}
+	return ans
+}
+
+// templatesChanged returns true if the files are changed from the
+// last deploy. Without the compile cache all files are considered
+// changed.
+func (i *SshCInstance) templatesChanged(files []string) bool {
+	if i.compileCache == nil || i.SkipCompile {
+		return true
+	}
+	return i.compileCache.HasChanges(files)
+}
+
+// resourceChanged returns true if the compiled files under the
+// source of the sync resource are changed.
+func (i *SshCInstance) resourceChanged(executor *ssh_executor.SshCExecutor,
+	resource *specs.SshCSyncResource, syncSourceDir string, compiled []string) bool {
+	targets, err := getResourceSyncTargets(executor, resource, syncSourceDir, compiled)
+	if err != nil {
+		// The error is reported by the push.
+		i.Logger.Debug(err.Error())
+		return true
+	}
+
+	files := []string{}
+	for _, f := range targets {
+		files = append(files, f)
+	}
+	return i.templatesChanged(files)
+}
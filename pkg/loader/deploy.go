@@ -5,15 +5,20 @@ See AUTHORS and LICENSE for the license details and contributors.
 package loader
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"os"
 	"path"
 	"path/filepath"
 	"strings"
 
 	ssh_executor "github.com/MottainaiCI/ssh-compose/pkg/executor"
+	helpers "github.com/MottainaiCI/ssh-compose/pkg/helpers"
 	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
 	"github.com/MottainaiCI/ssh-compose/pkg/template"
+
+	"github.com/google/uuid"
 )
 
 func (i *SshCInstance) GetNodeHooks4Event(event string, proj *specs.SshCProject, group *specs.SshCGroup, node *specs.SshCNode) []specs.SshCHook {
@@ -63,8 +68,15 @@ func (i *SshCInstance) ApplyProject(projectName string) error {
 		return err
 	}
 
+	err = i.setupCompileCache(env)
+	if err != nil {
+		return err
+	}
+	defer i.saveCompileCache()
+
 	// Compiler project files
-	err = template.CompileProjectFiles(proj, compiler, template.CompilerOpts{})
+	err = template.CompileProjectFiles(proj, compiler,
+		i.getCompilerOpts(&i.compiled.project))
 	if err != nil {
 		return err
 	}
@@ -177,8 +189,7 @@ func (i *SshCInstance) ProcessHooks(hooks *[]specs.SshCHook, proj *specs.SshCPro
 				node + ": " + err.Error())
 			return err
 		}
-		// TODO: propagate sftp client options
-		err = executor.SetupSftp()
+		err = executor.SetupSftp(executor.GetSftpClientOptions()...)
 		if err != nil {
 			i.Logger.Error("Error on setup sftp client on executor of the node " +
 				node + ": " + err.Error())
@@ -192,8 +203,7 @@ func (i *SshCInstance) ProcessHooks(hooks *[]specs.SshCHook, proj *specs.SshCPro
 					fmt.Sprintf(">>> [%s] Pulling %d resources... - :bus:",
 						node, nPullResources))))
 
-		// TODO: Propagate this options from config
-		ensurePerms := false
+		ensurePerms := i.Config.GetGeneral().EnsurePerms
 
 		for idx, resource := range h.PullResources {
 
@@ -209,8 +219,23 @@ func (i *SshCInstance) ProcessHooks(hooks *[]specs.SshCHook, proj *specs.SshCPro
 				targetPath += "/"
 			}
 
-			err = executor.RecursivePullFile(node,
-				resource.Source, targetPath, !h.PullKeepSourcePath, ensurePerms)
+			syncOpts := ssh_executor.NewSyncOpts()
+			syncOpts.EnsurePerms = ensurePerms
+			syncOpts.Owner = resource.Owner
+			syncOpts.Group = resource.Group
+			syncOpts.FileMode = resource.FileMode
+			syncOpts.DirMode = resource.DirMode
+			syncOpts.Verify = resource.Verify
+			syncOpts.Exclude = resource.Exclude
+			syncOpts.Include = resource.Include
+			syncOpts.NewerThan = resource.NewerThan
+			syncOpts.MinSize = resource.MinSize
+			syncOpts.MaxSize = resource.MaxSize
+			syncOpts.Progress = i.Config.GetLogging().PushProgressBar
+			syncOpts.ResumeThreshold = i.Config.GetGeneral().ResumeThreshold
+
+			err = executor.RecursivePullFileWithOpts(node,
+				resource.Source, targetPath, !h.PullKeepSourcePath, syncOpts)
 			if err != nil {
 				i.Logger.Debug("Error on pull from sourcePath " + resource.Source +
 					" to dest " + targetPath)
@@ -229,8 +254,11 @@ func (i *SshCInstance) ProcessHooks(hooks *[]specs.SshCHook, proj *specs.SshCPro
 
 	runSingleCmd := func(h *specs.SshCHook, node, cmds string) error {
 		var executor *ssh_executor.SshCExecutor
+		var nodeEntity *specs.SshCNode = nil
 		var err error
 
+		cmdOpts := ssh_executor.NewCommandOpts()
+
 		envs, err := proj.GetEnvsMap()
 		if err != nil {
 			return err
@@ -240,9 +268,9 @@ func (i *SshCInstance) ProcessHooks(hooks *[]specs.SshCHook, proj *specs.SshCPro
 		}
 
 		if node != "host" {
-			var nodeEntity *specs.SshCNode = nil
+			var groupEntity *specs.SshCGroup = nil
 
-			_, _, _, nodeEntity = i.GetEntitiesByNodeName(node)
+			_, _, groupEntity, nodeEntity = i.GetEntitiesByNodeName(node)
 			if nodeEntity != nil {
 				json, err := nodeEntity.ToJson()
 				if err != nil {
@@ -261,6 +289,12 @@ func (i *SshCInstance) ProcessHooks(hooks *[]specs.SshCHook, proj *specs.SshCPro
 					return err
 				}
 
+				cmdOpts.Become, err = i.getBecomeOpts(executor, proj,
+					groupEntity, nodeEntity, h)
+				if err != nil {
+					return err
+				}
+
 			} else {
 				return fmt.Errorf("error on retrieve executor of the node %s", node)
 			}
@@ -284,27 +318,67 @@ func (i *SshCInstance) ProcessHooks(hooks *[]specs.SshCHook, proj *specs.SshCPro
 			storeVar = false
 		}
 
+		if h.HasStdin() {
+			stdin, err := i.getHookStdin(h, proj, env, nodeEntity)
+			if err != nil {
+				return err
+			}
+			cmdOpts.Stdin = bytes.NewReader(stdin)
+		}
+
+		switch h.GetEnvMode() {
+		case specs.EnvModeJson, specs.EnvModeExport, specs.EnvModeNone:
+		default:
+			return fmt.Errorf("invalid env_mode %s", h.EnvMode)
+		}
+
+		if len(h.Envs) > 0 {
+			hookEnvs, err := i.getHookEnvs(h, proj, env, nodeEntity)
+			if err != nil {
+				return err
+			}
+			for k, v := range hookEnvs {
+				envs[k] = v
+			}
+			cmdOpts.Envs = hookEnvs
+		}
+		cmdOpts.Cwd = h.Cwd
+		if h.Node == "host" && h.Cwd != "" && !filepath.IsAbs(h.Cwd) {
+			// As the sync sources, a relative path on the host is
+			// relative to the directory of the environment file.
+			envBaseAbs, err := filepath.Abs(filepath.Dir(env.File))
+			if err != nil {
+				return err
+			}
+			cmdOpts.Cwd = filepath.Join(envBaseAbs, h.Cwd)
+		}
+		if h.HasArgs() && !h.HasScript() {
+			cmdOpts.Args = h.Args
+		}
+		cmdOpts.EnvMode = h.GetEnvMode()
+
 		if h.Node == "host" {
 			if storeVar {
-				res, err = executor.RunHostCommandWithOutput4Var(cmds, h.Out2Var, h.Err2Var, &envs, h.Entrypoint)
+				res, err = executor.RunHostCommandWithOutput4VarWithOpts(cmds, h.Out2Var, h.Err2Var, &envs, h.Entrypoint, cmdOpts)
 			} else {
 
 				if i.Config.GetLogging().RuntimeCmdsOutput {
 					emitter := executor.GetEmitter()
-					res, err = executor.RunHostCommandWithOutput(
+					res, err = executor.RunHostCommandWithOutputWithOpts(
 						cmds, envs,
 						(emitter.(*ssh_executor.SshCEmitter)).GetHostWriterStdout(),
 						(emitter.(*ssh_executor.SshCEmitter)).GetHostWriterStderr(),
 						h.Entrypoint,
+						cmdOpts,
 					)
 				} else {
-					res, err = executor.RunHostCommand(cmds, envs, h.Entrypoint)
+					res, err = executor.RunHostCommandWithOpts(cmds, envs, h.Entrypoint, cmdOpts)
 				}
 			}
 		} else {
 
 			if storeVar {
-				res, err = executor.RunCommandWithOutput4Var(node, cmds, h.Out2Var, h.Err2Var, &envs, h.Entrypoint)
+				res, err = executor.RunCommandWithOutput4VarWithOpts(node, cmds, h.Out2Var, h.Err2Var, &envs, h.Entrypoint, cmdOpts)
 			} else {
 				if i.Config.GetLogging().RuntimeCmdsOutput {
 
@@ -333,16 +407,17 @@ func (i *SshCInstance) ProcessHooks(hooks *[]specs.SshCHook, proj *specs.SshCPro
 
 					} else {
 
-						res, err = executor.RunCommandWithOutput(
+						res, err = executor.RunCommandWithOutputWithOpts(
 							node, cmds, envs,
 							(emitter.(*ssh_executor.SshCEmitter)).GetSshWriterStdout(),
 							(emitter.(*ssh_executor.SshCEmitter)).GetSshWriterStderr(),
-							h.Entrypoint)
+							h.Entrypoint,
+							cmdOpts)
 
 					}
 				} else {
-					res, err = executor.RunCommand(
-						node, cmds, envs, h.Entrypoint,
+					res, err = executor.RunCommandWithOpts(
+						node, cmds, envs, h.Entrypoint, cmdOpts,
 					)
 				}
 			}
@@ -374,6 +449,93 @@ func (i *SshCInstance) ProcessHooks(hooks *[]specs.SshCHook, proj *specs.SshCPro
 		return nil
 	}
 
+	runScript := func(h *specs.SshCHook, node string) error {
+		var nodeEntity *specs.SshCNode = nil
+		var groupEntity *specs.SshCGroup = nil
+		var scriptPath string
+
+		if node != "host" {
+			_, _, groupEntity, nodeEntity = i.GetEntitiesByNodeName(node)
+			if nodeEntity == nil {
+				return fmt.Errorf("error on retrieve node entity of the node %s", node)
+			}
+		}
+
+		content, err := i.getHookScript(h, proj, env, nodeEntity)
+		if err != nil {
+			return err
+		}
+
+		scriptName := fmt.Sprintf("ssh-compose-%s-%s",
+			uuid.New().String(), filepath.Base(h.Script))
+
+		if node == "host" {
+			scriptPath = filepath.Join(os.TempDir(), scriptName)
+			defer os.Remove(scriptPath)
+
+			err = os.WriteFile(scriptPath, content, 0700)
+			if err != nil {
+				return fmt.Errorf("error on write script %s: %s",
+					scriptPath, err.Error())
+			}
+
+		} else {
+			executor, err := i.getExecutor(node, nodeEntity.Endpoint)
+			if err != nil {
+				i.Logger.Error("Error on retrieve executor of the node " +
+					node + ": " + err.Error())
+				return err
+			}
+			err = executor.SetupSftp(executor.GetSftpClientOptions()...)
+			if err != nil {
+				i.Logger.Error("Error on setup sftp client on executor of the node " +
+					node + ": " + err.Error())
+				return err
+			}
+
+			// The script is uploaded with the become user used to
+			// execute it, so the script is readable and executable
+			// only by the user that runs it.
+			uploadOpts := ssh_executor.NewSyncOpts()
+			uploadOpts.FileMode = "0700"
+			uploadOpts.Become, err = i.getBecomeOpts(executor, proj,
+				groupEntity, nodeEntity, h)
+			if err != nil {
+				return err
+			}
+
+			scriptPath = path.Join(h.GetScriptTmpDir(), scriptName)
+
+			// The script is removed also if the upload or the
+			// command fail.
+			defer func() {
+				_, err := executor.ExecCommandOutput(
+					"rm -f "+helpers.ShellQuote(scriptPath), uploadOpts.Become)
+				if err != nil {
+					i.Logger.Warning(fmt.Sprintf(
+						"[%s] Error on remove script %s: %s",
+						node, scriptPath, err.Error()))
+				}
+			}()
+
+			err = executor.UploadContentWithOpts(node, scriptPath, content, uploadOpts)
+			if err != nil {
+				return fmt.Errorf("error on upload script %s to node %s: %s",
+					h.Script, node, err.Error())
+			}
+		}
+
+		i.Logger.DebugC(
+			i.Logger.Aurora.Italic(
+				i.Logger.Aurora.BrightCyan(
+					fmt.Sprintf(">>> [%s] Running script %s (%s)",
+						node, h.Script, scriptPath))))
+
+		cmd := helpers.ShellJoin(append([]string{scriptPath}, h.ScriptArgs...))
+
+		return runSingleCmd(h, node, cmd)
+	}
+
 	// Retrieve list of nodes
 	if group != nil {
 		nodes = group.Nodes
@@ -391,6 +553,11 @@ func (i *SshCInstance) ProcessHooks(hooks *[]specs.SshCHook, proj *specs.SshCPro
 			continue
 		}
 
+		if h.OnChanges && !i.templatesChanged(i.getCompiledFiles(targetNode)) {
+			i.Logger.Debug("Skipped hooks without changes of the templates ", h)
+			continue
+		}
+
 		if h.HasPullResources() {
 
 			switch h.Node {
@@ -416,6 +583,57 @@ func (i *SshCInstance) ProcessHooks(hooks *[]specs.SshCHook, proj *specs.SshCPro
 				}
 			}
 
+		} else if h.HasScript() {
+
+			switch h.Node {
+			case "", "*":
+				if targetNode != nil {
+					err := runScript(&h, targetNode.GetName())
+					if err != nil {
+						return err
+					}
+				} else {
+					for _, node := range nodes {
+						err := runScript(&h, node.GetName())
+						if err != nil {
+							return err
+						}
+					}
+				}
+
+			default:
+				err := runScript(&h, h.Node)
+				if err != nil {
+					return err
+				}
+			}
+
+		} else if h.HasArgs() {
+
+			cmds := strings.Join(h.Args, " ")
+			switch h.Node {
+			case "", "*":
+				if targetNode != nil {
+					err := runSingleCmd(&h, targetNode.GetName(), cmds)
+					if err != nil {
+						return err
+					}
+				} else {
+					for _, node := range nodes {
+						err := runSingleCmd(&h, node.GetName(), cmds)
+						if err != nil {
+							return err
+						}
+					}
+				}
+
+			default:
+				err := runSingleCmd(&h, h.Node, cmds)
+				if err != nil {
+					return err
+				}
+			}
+
 		} else if h.Commands != nil && len(h.Commands) > 0 {
 
 			for _, cmds := range h.Commands {
@@ -471,7 +689,8 @@ func (i *SshCInstance) ApplyGroup(group *specs.SshCGroup, proj *specs.SshCProjec
 	compiler.InitVars()
 
 	// Compile group templates
-	err = template.CompileGroupFiles(group, compiler, template.CompilerOpts{})
+	err = template.CompileGroupFiles(group, compiler,
+		i.getCompilerOpts(&i.compiled.group))
 	if err != nil {
 		return err
 	}
@@ -522,7 +741,7 @@ func (i *SshCInstance) ApplyCommand(c *specs.SshCCommand, proj *specs.SshCProjec
 
 	env := i.GetEnvByProjectName(proj.GetName())
 	if env == nil {
-		return errors.New(fmt.Sprintf("No environment found for project " + proj.GetName()))
+		return errors.New("No environment found for project " + proj.GetName())
 	}
 
 	envBaseDir, err := filepath.Abs(filepath.Dir(env.File))
@@ -534,7 +753,7 @@ func (i *SshCInstance) ApplyCommand(c *specs.SshCCommand, proj *specs.SshCProjec
 	if len(c.VarFiles) > 0 {
 		for _, varFile := range c.VarFiles {
 
-			envs, err := i.loadEnvFile(envBaseDir, varFile, proj)
+			envs, err := i.loadEnvFile(envBaseDir, varFile, proj, env)
 			if err != nil {
 				return errors.New(
 					fmt.Sprintf(
@@ -559,7 +778,7 @@ func (i *SshCInstance) ApplyCommand(c *specs.SshCCommand, proj *specs.SshCProjec
 			for _, hfile := range hinclude.GetFiles() {
 				// Load project included hooks
 				hf := path.Join(envBaseDir, hfile)
-				hooks, err := i.getHooks(hfile, hf, proj)
+				hooks, err := i.getHooks(hfile, hf, proj, env)
 				if err != nil {
 					return err
 				}
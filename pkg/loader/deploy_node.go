@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	ssh_executor "github.com/MottainaiCI/ssh-compose/pkg/executor"
 	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
 	"github.com/MottainaiCI/ssh-compose/pkg/template"
 )
@@ -35,10 +36,14 @@ func (i *SshCInstance) ApplyNode(node *specs.SshCNode,
 	// We need reload variables updated from out2var/err2var hooks.
 	compiler.InitVars()
 
+	i.compiled.node = []string{}
+	i.compiled.nodeName = node.GetName()
+
 	if len(node.ConfigTemplates) > 0 && !i.SkipCompile {
 
 		// Compile node templates
-		err = template.CompileNodeFiles(*node, compiler, template.CompilerOpts{})
+		err = template.CompileNodeFiles(*node, compiler,
+			i.getCompilerOpts(&i.compiled.node))
 		if err != nil {
 			return err
 		}
@@ -46,16 +51,7 @@ func (i *SshCInstance) ApplyNode(node *specs.SshCNode,
 	}
 
 	if len(node.SyncResources) > 0 && !i.SkipSync {
-		if node.SourceDir != "" {
-			if node.IsSourcePathRelative() {
-				syncSourceDir = filepath.Join(envBaseAbs, node.SourceDir)
-			} else {
-				syncSourceDir = node.SourceDir
-			}
-		} else {
-			// Use env file directory
-			syncSourceDir = envBaseAbs
-		}
+		syncSourceDir = getNodeSyncSourceDir(node, envBaseAbs)
 
 		executor, err := i.getExecutor(node.GetName(), node.Endpoint)
 		if err != nil {
@@ -63,14 +59,18 @@ func (i *SshCInstance) ApplyNode(node *specs.SshCNode,
 				node.GetName() + ": " + err.Error())
 			return err
 		}
-		// TODO: propagate sftp client options
-		err = executor.SetupSftp()
+		err = executor.SetupSftp(executor.GetSftpClientOptions()...)
 		if err != nil {
 			i.Logger.Error("Error on setup sftp client on executor of the node " +
 				node.GetName() + ": " + err.Error())
 			return err
 		}
 
+		become, err := i.getBecomeOpts(executor, proj, group, node, nil)
+		if err != nil {
+			return err
+		}
+
 		i.Logger.Debug(i.Logger.Aurora.Bold(
 			i.Logger.Aurora.BrightCyan(
 				">>> [" + node.GetName() + "] Using sync source basedir " +
@@ -83,10 +83,21 @@ func (i *SshCInstance) ApplyNode(node *specs.SshCNode,
 					fmt.Sprintf(">>> [%s] Syncing %d resources... - :bus:",
 						node.GetName(), nResources))))
 
+		compiled := i.getCompiledFiles(node)
+
 		for idx, resource := range node.SyncResources {
 
 			var sourcePath string
 
+			if resource.OnChanges && !i.resourceChanged(executor, &resource,
+				syncSourceDir, compiled) {
+				i.Logger.InfoC(
+					i.Logger.Aurora.BrightCyan(
+						fmt.Sprintf(">>> [%s] - [%2d/%2d] %s unchanged - :check_mark:",
+							node.GetName(), idx+1, nResources, resource.Destination)))
+				continue
+			}
+
 			if filepath.IsAbs(resource.Source) {
 				sourcePath = resource.Source
 			} else {
@@ -100,15 +111,39 @@ func (i *SshCInstance) ApplyNode(node *specs.SshCNode,
 							node.GetName(), resource.Source,
 							resource.Destination))))
 
-			// TODO: Propagate this options from config
-			ensurePerms := false
+			ensurePerms := i.Config.GetGeneral().EnsurePerms
 
 			if strings.HasSuffix(resource.Source, "/") {
 				sourcePath += "/"
 			}
 
-			err = executor.RecursivePushFile(node.GetName(),
-				sourcePath, resource.Destination, ensurePerms)
+			syncOpts := ssh_executor.NewSyncOpts()
+			syncOpts.EnsurePerms = ensurePerms
+			syncOpts.Become = become
+			syncOpts.Compare = resource.Compare
+			syncOpts.Owner = resource.Owner
+			syncOpts.Group = resource.Group
+			syncOpts.FileMode = resource.FileMode
+			syncOpts.DirMode = resource.DirMode
+			syncOpts.Verify = resource.Verify
+			syncOpts.Exclude = resource.Exclude
+			syncOpts.Include = resource.Include
+			syncOpts.Mode = resource.Mode
+			syncOpts.Compression = resource.Compression
+			syncOpts.TarRules = resource.TarRules
+			syncOpts.Backup = resource.Backup
+			syncOpts.Delete = resource.Delete
+			syncOpts.Protect = resource.Protect
+			syncOpts.DryRun = i.DeleteDryRun
+			syncOpts.Progress = i.Config.GetLogging().PushProgressBar
+			syncOpts.ResumeThreshold = i.Config.GetGeneral().ResumeThreshold
+			syncOpts.Workers = resource.Workers
+			if syncOpts.Workers == 0 {
+				syncOpts.Workers = executor.GetSftpWorkers()
+			}
+
+			err = executor.RecursivePushFileWithOpts(node.GetName(),
+				sourcePath, resource.Destination, syncOpts)
 			if err != nil {
 				i.Logger.Debug("Error on sync from sourcePath " + sourcePath +
 					" to dest " + resource.Destination)
@@ -118,8 +153,20 @@ func (i *SshCInstance) ApplyNode(node *specs.SshCNode,
 
 			i.Logger.InfoC(
 				i.Logger.Aurora.BrightCyan(
-					fmt.Sprintf(">>> [%s] - [%2d/%2d] %s - :check_mark:",
-						node.GetName(), idx+1, nResources, resource.Destination)))
+					fmt.Sprintf(">>> [%s] - [%2d/%2d] %s (%s) - :check_mark:",
+						node.GetName(), idx+1, nResources, resource.Destination,
+						syncOpts.Stats)))
+		}
+
+	}
+
+	if (len(group.RemoteTemplates) > 0 || len(node.RemoteTemplates) > 0) &&
+		!i.SkipCompile && !i.SkipSync {
+
+		// Compile and write the remote templates
+		err = i.applyRemoteTemplates(node, group, proj, compiler)
+		if err != nil {
+			return err
 		}
 
 	}
@@ -135,3 +182,16 @@ func (i *SshCInstance) ApplyNode(node *specs.SshCNode,
 
 	return nil
 }
+
+// getNodeSyncSourceDir returns the directory used as base
+// of the relative sources of the sync resources.
+func getNodeSyncSourceDir(node *specs.SshCNode, envBaseAbs string) string {
+	if node.SourceDir != "" {
+		if node.IsSourcePathRelative() {
+			return filepath.Join(envBaseAbs, node.SourceDir)
+		}
+		return node.SourceDir
+	}
+	// Use env file directory
+	return envBaseAbs
+}
@@ -0,0 +1,247 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	ssh_executor "github.com/MottainaiCI/ssh-compose/pkg/executor"
+	helpers "github.com/MottainaiCI/ssh-compose/pkg/helpers"
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+	"github.com/MottainaiCI/ssh-compose/pkg/template"
+)
+
+// DiffProject compiles the templates of the project and prints the
+// unified diff between the compiled files and the remote files of
+// the matching sync resources. It returns true if there are
+// differences. The hooks are not executed.
+func (i *SshCInstance) DiffProject(projectName string, opts template.CompilerOpts) (bool, error) {
+	drift := false
+
+	env := i.GetEnvByProjectName(projectName)
+	if env == nil {
+		return false, errors.New("No environment found for project " + projectName)
+	}
+
+	proj := env.GetProjectByName(projectName)
+	if proj == nil {
+		return false, errors.New("No project found with name " + projectName)
+	}
+
+	compiler, err := template.NewProjectTemplateCompiler(env, proj)
+	if err != nil {
+		return false, err
+	}
+
+	compiled := []string{}
+	opts.OnCompiled = func(f string) {
+		compiled = append(compiled, f)
+	}
+
+	err = template.CompileProjectFiles(proj, compiler, opts)
+	if err != nil {
+		return false, err
+	}
+	nProjFiles := len(compiled)
+
+	for _, grp := range proj.Groups {
+
+		if !grp.ToProcess(i.GroupsEnabled, i.GroupsDisabled) ||
+			!opts.IsGroupEnabled(grp.Name) {
+			continue
+		}
+
+		compiled = compiled[:nProjFiles]
+		compiler.InitVars()
+
+		err = template.CompileGroupFiles(&grp, compiler, opts)
+		if err != nil {
+			return false, err
+		}
+		nGroupFiles := len(compiled)
+
+		for _, node := range grp.Nodes {
+			compiled = compiled[:nGroupFiles]
+
+			err = template.CompileNodeFiles(node, compiler, opts)
+			if err != nil {
+				i.cleanupExecutorMap()
+				return false, err
+			}
+
+			d, err := i.diffNode(&node, &grp, proj, env, compiler, compiled)
+			if err != nil {
+				i.cleanupExecutorMap()
+				return false, err
+			}
+			if d {
+				drift = true
+			}
+		}
+
+		i.cleanupExecutorMap()
+	}
+
+	return drift, nil
+}
+
+// getNodeSyncTargets returns the remote paths of the compiled
+// files pushed by the sync resources of the node.
+func getNodeSyncTargets(executor *ssh_executor.SshCExecutor, node *specs.SshCNode,
+	syncSourceDir string, compiled []string) (map[string]string, error) {
+	ans := make(map[string]string, 0)
+
+	for idx := range node.SyncResources {
+		targets, err := getResourceSyncTargets(executor, &node.SyncResources[idx],
+			syncSourceDir, compiled)
+		if err != nil {
+			return ans, err
+		}
+		for remotePath, f := range targets {
+			ans[remotePath] = f
+		}
+	}
+
+	return ans, nil
+}
+
+// getResourceSyncTargets returns the remote paths of the compiled
+// files pushed by the sync resource. The paths are resolved with
+// the exclude/include patterns and the tar rules of the resource
+// like the push.
+func getResourceSyncTargets(executor *ssh_executor.SshCExecutor,
+	resource *specs.SshCSyncResource, syncSourceDir string,
+	compiled []string) (map[string]string, error) {
+
+	sourcePath := resource.Source
+	if !filepath.IsAbs(sourcePath) {
+		sourcePath = filepath.Join(syncSourceDir, resource.Source)
+	}
+
+	syncOpts := ssh_executor.NewSyncOpts()
+	syncOpts.Exclude = resource.Exclude
+	syncOpts.Include = resource.Include
+	syncOpts.Mode = resource.Mode
+	syncOpts.TarRules = resource.TarRules
+
+	targets, err := executor.GetPushTargets(sourcePath, resource.Destination,
+		compiled, syncOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error on resolve the targets of %s: %s",
+			resource.Source, err.Error())
+	}
+
+	return targets, nil
+}
+
+func (i *SshCInstance) diffNode(node *specs.SshCNode, group *specs.SshCGroup,
+	proj *specs.SshCProject, env *specs.SshCEnvironment,
+	compiler template.SshCTemplateCompiler, compiled []string) (bool, error) {
+	drift := false
+
+	envBaseAbs, err := filepath.Abs(filepath.Dir(env.File))
+	if err != nil {
+		return false, err
+	}
+
+	remoteTemplates, err := template.CompileNodeRemoteTemplates(node, group, compiler)
+	if err != nil {
+		return false, err
+	}
+
+	if len(node.SyncResources) == 0 && len(remoteTemplates) == 0 {
+		return false, nil
+	}
+
+	executor, err := i.getExecutor(node.GetName(), node.Endpoint)
+	if err != nil {
+		i.Logger.Error("Error on retrieve executor of the node " +
+			node.GetName() + ": " + err.Error())
+		return false, err
+	}
+	err = executor.SetupSftp(executor.GetSftpClientOptions()...)
+	if err != nil {
+		i.Logger.Error("Error on setup sftp client on executor of the node " +
+			node.GetName() + ": " + err.Error())
+		return false, err
+	}
+
+	// Remote path -> compiled content
+	contents := make(map[string][]byte, 0)
+	sources := make(map[string]string, 0)
+
+	targets, err := getNodeSyncTargets(executor, node,
+		getNodeSyncSourceDir(node, envBaseAbs), compiled)
+	if err != nil {
+		return false, err
+	}
+	for remotePath, f := range targets {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return false, err
+		}
+		contents[remotePath] = data
+		sources[remotePath] = f
+	}
+
+	for _, t := range remoteTemplates {
+		contents[t.Destination] = t.Content
+		sources[t.Destination] = t.SourceFile
+	}
+
+	if len(contents) == 0 {
+		return false, nil
+	}
+
+	become, err := i.getBecomeOpts(executor, proj, group, node, nil)
+	if err != nil {
+		return false, err
+	}
+
+	i.Logger.InfoC(
+		i.Logger.Aurora.Bold(
+			i.Logger.Aurora.BrightCyan(
+				fmt.Sprintf(">>> [%s] Comparing %d files... :mag:",
+					node.GetName(), len(contents)))))
+
+	remotePaths := []string{}
+	for p := range contents {
+		remotePaths = append(remotePaths, p)
+	}
+	sort.Strings(remotePaths)
+
+	for _, remotePath := range remotePaths {
+		remoteData, exists, err := executor.ReadFileContent(remotePath, become)
+		if err != nil {
+			i.Logger.Error(fmt.Sprintf("[%s] Error on read %s: %s",
+				node.GetName(), remotePath, err.Error()))
+			return false, err
+		}
+
+		fromFile := node.GetName() + ":" + remotePath
+		if !exists {
+			fromFile = "/dev/null"
+		}
+
+		diff := helpers.UnifiedDiff(fromFile, sources[remotePath],
+			string(remoteData), string(contents[remotePath]))
+		if diff == "" {
+			i.Logger.DebugC(
+				i.Logger.Aurora.Italic(
+					i.Logger.Aurora.BrightCyan(
+						fmt.Sprintf(">>> [%s] %s unchanged", node.GetName(), remotePath))))
+			continue
+		}
+
+		drift = true
+		fmt.Print(diff)
+	}
+
+	return drift, nil
+}
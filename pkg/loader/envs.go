@@ -54,24 +54,25 @@ func (i *SshCInstance) LoadEnvironments() error {
 				continue
 			}
 
-			if i.Config.IsEnableRenderEngine() {
-				// Render file
-				renderOut, err := helpers_render.RenderContentWithTemplates(string(content),
-					i.Config.RenderValuesFile,
-					i.Config.RenderDefaultFile,
-					file.Name(),
-					i.Config.RenderEnvsVars,
-					i.Config.RenderTemplatesDirs,
-				)
-				if err != nil {
-					i.Logger.Error("Error on render file", file.Name())
-					return err
-				}
+			// The values files of the environment are read before the
+			// rendering so that a single pass with all values is done.
+			valuesFiles, err := specs.RenderValuesFilesFromYaml(content)
+			if err != nil {
+				return fmt.Errorf("error on read render_values_files of file %s: %s",
+					file.Name(), err.Error())
+			}
 
-				content = []byte(renderOut)
+			rendered, err := i.renderContent(content, file.Name(),
+				&specs.SshCEnvironment{
+					File:              path.Join(edir, file.Name()),
+					RenderValuesFiles: valuesFiles,
+				})
+			if err != nil {
+				i.Logger.Error("Error on render file", file.Name())
+				return err
 			}
 
-			env, err := specs.EnvironmentFromYaml(content, path.Join(edir, file.Name()))
+			env, err := specs.EnvironmentFromYaml(rendered, path.Join(edir, file.Name()))
 			if err != nil {
 				i.Logger.Debug("On parse file", file.Name(), ":", err.Error())
 				i.Logger.Debug("File", file.Name(), "skipped.")
@@ -128,20 +129,9 @@ func (i *SshCInstance) loadExtraFiles(env *specs.SshCEnvironment) error {
 				continue
 			}
 
-			if i.Config.IsEnableRenderEngine() {
-				// Render file
-				renderOut, err := helpers_render.RenderContentWithTemplates(string(content),
-					i.Config.RenderValuesFile,
-					i.Config.RenderDefaultFile,
-					cfile,
-					i.Config.RenderEnvsVars,
-					i.Config.RenderTemplatesDirs,
-				)
-				if err != nil {
-					return err
-				}
-
-				content = []byte(renderOut)
+			content, err = i.renderContent(content, cfile, env)
+			if err != nil {
+				return err
 			}
 
 			cmd, err := specs.CommandFromYaml(content)
@@ -179,20 +169,9 @@ func (i *SshCInstance) loadExtraFiles(env *specs.SshCEnvironment) error {
 					continue
 				}
 
-				if i.Config.IsEnableRenderEngine() {
-					// Render file
-					renderOut, err := helpers_render.RenderContentWithTemplates(string(content),
-						i.Config.RenderValuesFile,
-						i.Config.RenderDefaultFile,
-						gfile,
-						i.Config.RenderEnvsVars,
-						i.Config.RenderTemplatesDirs,
-					)
-					if err != nil {
-						return err
-					}
-
-					content = []byte(renderOut)
+				content, err = i.renderContent(content, gfile, env)
+				if err != nil {
+					return err
 				}
 
 				grp, err := specs.GroupFromYaml(content)
@@ -214,7 +193,7 @@ func (i *SshCInstance) loadExtraFiles(env *specs.SshCEnvironment) error {
 		if len(proj.IncludeEnvFiles) > 0 {
 			// Load external env vars files
 			for _, efile := range proj.IncludeEnvFiles {
-				evars, err := i.loadEnvFile(envBaseDir, efile, &env.Projects[idx])
+				evars, err := i.loadEnvFile(envBaseDir, efile, &env.Projects[idx], env)
 				if err != nil {
 					return err
 				} else if evars != nil {
@@ -249,7 +228,7 @@ func (i *SshCInstance) loadIncludeHooks(env *specs.SshCEnvironment) error {
 
 					// Load project included hooks
 					hf := path.Join(envBaseDir, hfile)
-					hooks, err := i.getHooks(hfile, hf, &proj)
+					hooks, err := i.getHooks(hfile, hf, &proj, env)
 					if err != nil {
 						return err
 					}
@@ -283,7 +262,7 @@ func (i *SshCInstance) loadIncludeHooks(env *specs.SshCEnvironment) error {
 					for _, hfile := range hinclude.GetFiles() {
 
 						hf := path.Join(envBaseDir, hfile)
-						hooks, err := i.getHooks(hfile, hf, &proj)
+						hooks, err := i.getHooks(hfile, hf, &proj, env)
 						if err != nil {
 							return err
 						}
@@ -312,7 +291,7 @@ func (i *SshCInstance) loadIncludeHooks(env *specs.SshCEnvironment) error {
 					for _, hinclude := range n.IncludeHooksFiles {
 						for _, hfile := range hinclude.GetFiles() {
 							hf := path.Join(envBaseDir, hfile)
-							hooks, err := i.getHooks(hfile, hf, &proj)
+							hooks, err := i.getHooks(hfile, hf, &proj, env)
 							if err != nil {
 								return err
 							}
@@ -338,7 +317,8 @@ func (i *SshCInstance) loadIncludeHooks(env *specs.SshCEnvironment) error {
 	return nil
 }
 
-func (i *SshCInstance) getHooks(hfile, hfileAbs string, proj *specs.SshCProject) (*specs.SshCHooks, error) {
+func (i *SshCInstance) getHooks(hfile, hfileAbs string, proj *specs.SshCProject,
+	env *specs.SshCEnvironment) (*specs.SshCHooks, error) {
 
 	ans := &specs.SshCHooks{}
 
@@ -356,20 +336,9 @@ func (i *SshCInstance) getHooks(hfile, hfileAbs string, proj *specs.SshCProject)
 		return ans, nil
 	}
 
-	if i.Config.IsEnableRenderEngine() {
-		// Render file
-		renderOut, err := helpers_render.RenderContentWithTemplates(string(content),
-			i.Config.RenderValuesFile,
-			i.Config.RenderDefaultFile,
-			hfile,
-			i.Config.RenderEnvsVars,
-			i.Config.RenderTemplatesDirs,
-		)
-		if err != nil {
-			return ans, err
-		}
-
-		content = []byte(renderOut)
+	content, err = i.renderContent(content, hfile, env)
+	if err != nil {
+		return ans, err
 	}
 
 	hooks, err := specs.HooksFromYaml(content)
@@ -387,7 +356,8 @@ func (i *SshCInstance) getHooks(hfile, hfileAbs string, proj *specs.SshCProject)
 	return ans, nil
 }
 
-func (i *SshCInstance) loadEnvFile(envBaseDir, efile string, proj *specs.SshCProject) (*specs.SshCEnvVars, error) {
+func (i *SshCInstance) loadEnvFile(envBaseDir, efile string, proj *specs.SshCProject,
+	env *specs.SshCEnvironment) (*specs.SshCEnvVars, error) {
 	if !helpers.Exists(path.Join(envBaseDir, efile)) {
 		i.Logger.Warning("For project", proj.Name, "included env file", efile,
 			"is not present.")
@@ -409,20 +379,9 @@ func (i *SshCInstance) loadEnvFile(envBaseDir, efile string, proj *specs.SshCPro
 		return nil, nil
 	}
 
-	if i.Config.IsEnableRenderEngine() {
-		// Render file
-		renderOut, err := helpers_render.RenderContentWithTemplates(string(content),
-			i.Config.RenderValuesFile,
-			i.Config.RenderDefaultFile,
-			efile,
-			i.Config.RenderEnvsVars,
-			i.Config.RenderTemplatesDirs,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		content = []byte(renderOut)
+	content, err = i.renderContent(content, efile, env)
+	if err != nil {
+		return nil, err
 	}
 
 	evars, err := specs.EnvVarsFromYaml(content)
@@ -491,8 +450,8 @@ func (i *SshCInstance) decodeEncryptedEnvVars(env *specs.SshCEnvironment) error
 					continue
 				}
 				// Render the decrypt content
-				renderOut, err := helpers_render.RenderContentWithTemplates(string(decodedBytes),
-					i.Config.RenderValuesFile,
+				renderOut, err := helpers_render.RenderContentWithValues(string(decodedBytes),
+					i.GetRenderValuesFiles(env),
 					i.Config.RenderDefaultFile,
 					"-",
 					i.Config.RenderEnvsVars,
@@ -525,3 +484,49 @@ skipDecode:
 
 	return nil
 }
+
+// GetRenderValuesFiles returns the render values files of the
+// configuration followed by the values files of the environment.
+func (i *SshCInstance) GetRenderValuesFiles(env *specs.SshCEnvironment) []string {
+	ans := i.Config.GetRenderValuesFiles()
+
+	if env != nil && len(env.RenderValuesFiles) > 0 {
+		envBaseDir, err := filepath.Abs(path.Dir(env.File))
+		if err != nil {
+			envBaseDir = path.Dir(env.File)
+		}
+
+		for _, f := range env.RenderValuesFiles {
+			if !filepath.IsAbs(f) {
+				f = filepath.Join(envBaseDir, f)
+			}
+			ans = append(ans, f)
+		}
+	}
+
+	return ans
+}
+
+// renderContent renders the content with the render engine if
+// there are render files configured.
+func (i *SshCInstance) renderContent(content []byte, originFile string,
+	env *specs.SshCEnvironment) ([]byte, error) {
+
+	valuesFiles := i.GetRenderValuesFiles(env)
+	if len(valuesFiles) == 0 && i.Config.RenderDefaultFile == "" {
+		return content, nil
+	}
+
+	renderOut, err := helpers_render.RenderContentWithValues(string(content),
+		valuesFiles,
+		i.Config.RenderDefaultFile,
+		originFile,
+		i.Config.RenderEnvsVars,
+		i.Config.RenderTemplatesDirs,
+	)
+	if err != nil {
+		return content, err
+	}
+
+	return []byte(renderOut), nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package loader_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/MottainaiCI/ssh-compose/pkg/loader"
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Load environments", func() {
+
+	Context("Render values files of the environment", func() {
+
+		var dir string
+		var config *specs.SshComposeConfig
+
+		BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "sshc-envs")
+			Expect(err).Should(BeNil())
+
+			envsDir := filepath.Join(dir, "envs")
+			Expect(os.MkdirAll(envsDir, 0755)).Should(BeNil())
+
+			Expect(os.WriteFile(filepath.Join(envsDir, "project.yml"), []byte(`
+version: "1"
+render_values_files:
+- ../values/project.yml
+projects:
+- name: {{ .Values.project.name }}
+  vars:
+  - envs:
+      key1: {{ .Values.project.key1 }}
+`), 0644)).Should(BeNil())
+
+			valuesDir := filepath.Join(dir, "values")
+			Expect(os.MkdirAll(valuesDir, 0755)).Should(BeNil())
+
+			Expect(os.WriteFile(filepath.Join(valuesDir, "project.yml"), []byte(`
+project:
+  name: "project1"
+  key1: "value1"
+  key2: "value2"
+`), 0644)).Should(BeNil())
+
+			Expect(os.WriteFile(filepath.Join(dir, "vars.yml"), []byte(`
+envs:
+  key2: {{ .Values.project.key2 }}
+`), 0644)).Should(BeNil())
+
+			config = specs.NewSshComposeConfig(nil)
+			config.EnvironmentDirs = []string{envsDir}
+			config.General.RemotesConfDir = filepath.Join(dir, "remotes")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("Render nested keys defined only in the environment values", func() {
+			composer, err := NewSshCInstance(config)
+			Expect(err).Should(BeNil())
+
+			Expect(composer.LoadEnvironments()).Should(BeNil())
+			Expect(len(composer.Environments)).To(Equal(1))
+
+			env := composer.GetEnvByProjectName("project1")
+			Expect(env).ShouldNot(BeNil())
+
+			proj := env.GetProjectByName("project1")
+			Expect(proj.Environments[0].EnvVars["key1"]).To(Equal("value1"))
+
+			err = proj.LoadEnvVarsFile(filepath.Join(dir, "vars.yml"), config,
+				composer.GetRenderValuesFiles(env))
+			Expect(err).Should(BeNil())
+			Expect(proj.Environments[len(proj.Environments)-1].EnvVars["key2"]).To(
+				Equal("value2"))
+		})
+
+	})
+
+})
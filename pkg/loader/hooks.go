@@ -0,0 +1,133 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package loader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+	"github.com/MottainaiCI/ssh-compose/pkg/template"
+)
+
+// getHookCompiler returns a template compiler initialized with the
+// project variables and, if available, with the variables of the node.
+func (i *SshCInstance) getHookCompiler(proj *specs.SshCProject,
+	env *specs.SshCEnvironment, node *specs.SshCNode) (template.SshCTemplateCompiler, error) {
+
+	compiler, err := template.NewProjectTemplateCompiler(env, proj)
+	if err != nil {
+		return nil, err
+	}
+
+	if node != nil {
+		// Set node key with current node
+		(*compiler.GetVars())["node"] = *node
+
+		if len(node.Labels) > 0 {
+			for k, v := range node.Labels {
+				(*compiler.GetVars())[k] = v
+			}
+		}
+	}
+
+	return compiler, nil
+}
+
+func (i *SshCInstance) getHookStdin(h *specs.SshCHook, proj *specs.SshCProject,
+	env *specs.SshCEnvironment, node *specs.SshCNode) ([]byte, error) {
+
+	var content []byte
+
+	if h.StdinFile != "" {
+		stdinFile := h.StdinFile
+		if !filepath.IsAbs(stdinFile) {
+			envBaseAbs, err := filepath.Abs(filepath.Dir(env.File))
+			if err != nil {
+				return nil, err
+			}
+			stdinFile = filepath.Join(envBaseAbs, stdinFile)
+		}
+
+		data, err := os.ReadFile(stdinFile)
+		if err != nil {
+			return nil, fmt.Errorf("error on read stdin file %s: %s",
+				stdinFile, err.Error())
+		}
+		content = data
+	} else {
+		content = []byte(h.Stdin)
+	}
+
+	if h.StdinTemplate {
+		compiler, err := i.getHookCompiler(proj, env, node)
+		if err != nil {
+			return nil, err
+		}
+
+		out, err := compiler.CompileRaw(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("error on render stdin content: %s",
+				err.Error())
+		}
+		content = []byte(out)
+	}
+
+	return content, nil
+}
+
+func (i *SshCInstance) getHookScript(h *specs.SshCHook, proj *specs.SshCProject,
+	env *specs.SshCEnvironment, node *specs.SshCNode) ([]byte, error) {
+
+	scriptFile := h.Script
+	if !filepath.IsAbs(scriptFile) {
+		envBaseAbs, err := filepath.Abs(filepath.Dir(env.File))
+		if err != nil {
+			return nil, err
+		}
+		scriptFile = filepath.Join(envBaseAbs, scriptFile)
+	}
+
+	data, err := os.ReadFile(scriptFile)
+	if err != nil {
+		return nil, fmt.Errorf("error on read script %s: %s",
+			scriptFile, err.Error())
+	}
+
+	compiler, err := i.getHookCompiler(proj, env, node)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := compiler.CompileRaw(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("error on render script %s: %s",
+			scriptFile, err.Error())
+	}
+
+	return []byte(out), nil
+}
+
+func (i *SshCInstance) getHookEnvs(h *specs.SshCHook, proj *specs.SshCProject,
+	env *specs.SshCEnvironment, node *specs.SshCNode) (map[string]string, error) {
+
+	compiler, err := i.getHookCompiler(proj, env, node)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make(map[string]string, len(h.Envs))
+	for k, v := range h.Envs {
+		out, err := compiler.CompileRaw(v)
+		if err != nil {
+			return nil, fmt.Errorf("error on render env %s: %s",
+				k, err.Error())
+		}
+		ans[k] = out
+	}
+
+	return ans, nil
+}
@@ -8,6 +8,7 @@ import (
 	ssh_executor "github.com/MottainaiCI/ssh-compose/pkg/executor"
 	log "github.com/MottainaiCI/ssh-compose/pkg/logger"
 	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+	"github.com/MottainaiCI/ssh-compose/pkg/template"
 )
 
 type SshCInstance struct {
@@ -16,6 +17,7 @@ type SshCInstance struct {
 	Environments   []specs.SshCEnvironment
 	SkipSync       bool
 	SkipCompile    bool
+	DeleteDryRun   bool
 	FlagsDisabled  []string
 	FlagsEnabled   []string
 	GroupsEnabled  []string
@@ -24,6 +26,9 @@ type SshCInstance struct {
 	Remotes *specs.RemotesConfig
 
 	executorMap map[string]*ssh_executor.SshCExecutor
+
+	compileCache *template.CompileCache
+	compiled     compiledFiles
 }
 
 func NewSshCInstance(config *specs.SshComposeConfig) (*SshCInstance, error) {
@@ -72,6 +77,8 @@ func (i *SshCInstance) SetSkipSync(v bool)          { i.SkipSync = v }
 func (i *SshCInstance) GetSkipSync() bool           { return i.SkipSync }
 func (i *SshCInstance) SetSkipCompile(v bool)       { i.SkipCompile = v }
 func (i *SshCInstance) GetSkipCompile() bool        { return i.SkipCompile }
+func (i *SshCInstance) SetDeleteDryRun(v bool)      { i.DeleteDryRun = v }
+func (i *SshCInstance) GetDeleteDryRun() bool       { return i.DeleteDryRun }
 func (i *SshCInstance) GetGroupsEnabled() []string  { return i.GroupsEnabled }
 func (i *SshCInstance) GetGroupsDisabled() []string { return i.GroupsDisabled }
 func (i *SshCInstance) SetGroupsEnabled(groups []string) {
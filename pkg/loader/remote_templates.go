@@ -0,0 +1,85 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package loader
+
+import (
+	"fmt"
+
+	ssh_executor "github.com/MottainaiCI/ssh-compose/pkg/executor"
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+	"github.com/MottainaiCI/ssh-compose/pkg/template"
+)
+
+// applyRemoteTemplates compiles in memory the remote templates of
+// the group and of the node and writes them on the node without
+// storing the compiled files on the local disk.
+func (i *SshCInstance) applyRemoteTemplates(node *specs.SshCNode,
+	group *specs.SshCGroup, proj *specs.SshCProject,
+	compiler template.SshCTemplateCompiler) error {
+
+	templates, err := template.CompileNodeRemoteTemplates(node, group, compiler)
+	if err != nil {
+		i.Logger.Error(fmt.Sprintf("[%s] Error on compile remote templates: %s",
+			node.GetName(), err.Error()))
+		return err
+	}
+
+	if len(templates) == 0 {
+		return nil
+	}
+
+	executor, err := i.getExecutor(node.GetName(), node.Endpoint)
+	if err != nil {
+		i.Logger.Error("Error on retrieve executor of the node " +
+			node.GetName() + ": " + err.Error())
+		return err
+	}
+	err = executor.SetupSftp(executor.GetSftpClientOptions()...)
+	if err != nil {
+		i.Logger.Error("Error on setup sftp client on executor of the node " +
+			node.GetName() + ": " + err.Error())
+		return err
+	}
+
+	become, err := i.getBecomeOpts(executor, proj, group, node, nil)
+	if err != nil {
+		return err
+	}
+
+	i.Logger.InfoC(
+		i.Logger.Aurora.Bold(
+			i.Logger.Aurora.BrightCyan(
+				fmt.Sprintf(">>> [%s] Writing %d remote templates... :icecream:",
+					node.GetName(), len(templates)))))
+
+	for idx, t := range templates {
+		i.Logger.DebugC(
+			i.Logger.Aurora.Italic(
+				i.Logger.Aurora.BrightCyan(
+					fmt.Sprintf(">>> [%s] %s => %s",
+						node.GetName(), t.SourceFile, t.Destination))))
+
+		opts := ssh_executor.NewSyncOpts()
+		opts.Become = become
+		opts.Owner = t.Owner
+		opts.Group = t.Group
+		opts.FileMode = t.Mode
+
+		err = executor.UploadContentWithOpts(node.GetName(), t.Destination,
+			t.Content, opts)
+		if err != nil {
+			i.Logger.Error(fmt.Sprintf("[%s] Error on write remote template %s: %s",
+				node.GetName(), t.Source, err.Error()))
+			return err
+		}
+
+		i.Logger.InfoC(
+			i.Logger.Aurora.BrightCyan(
+				fmt.Sprintf(">>> [%s] - [%2d/%2d] %s :check_mark:",
+					node.GetName(), idx+1, len(templates), t.Destination)))
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+type SshCBecome struct {
+	Enabled bool
+	User    string
+	Method  string
+	PassVar string
+}
+
+// ResolveBecome returns the privilege escalation options to use
+// merging the options of the group, the node and the hook.
+// The hook options win over the node options and the node
+// options win over the group options.
+func ResolveBecome(group *SshCGroup, node *SshCNode, hook *SshCHook) *SshCBecome {
+	ans := &SshCBecome{}
+
+	merge := func(become *bool, user, method, passVar string) {
+		if become != nil {
+			ans.Enabled = *become
+		}
+		if user != "" {
+			ans.User = user
+		}
+		if method != "" {
+			ans.Method = method
+		}
+		if passVar != "" {
+			ans.PassVar = passVar
+		}
+	}
+
+	if group != nil {
+		merge(group.Become, group.BecomeUser, group.BecomeMethod, group.BecomePassVar)
+	}
+	if node != nil {
+		merge(node.Become, node.BecomeUser, node.BecomeMethod, node.BecomePassVar)
+	}
+	if hook != nil {
+		merge(hook.Become, hook.BecomeUser, hook.BecomeMethod, hook.BecomePassVar)
+	}
+
+	return ans
+}
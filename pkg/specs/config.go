@@ -26,6 +26,7 @@ type SshComposeConfig struct {
 
 	RenderDefaultFile   string                 `mapstructure:"render_default_file,omitempty" json:"render_default_file,omitempty" yaml:"render_default_file,omitempty"`
 	RenderValuesFile    string                 `mapstructure:"render_values_file,omitempty" json:"render_values_file,omitempty" yaml:"render_values_file,omitempty"`
+	RenderValuesFiles   []string               `mapstructure:"render_values_files,omitempty" json:"render_values_files,omitempty" yaml:"render_values_files,omitempty"`
 	RenderEnvsVars      map[string]interface{} `mapstructure:"-" json:"-" yaml:"-"`
 	RenderTemplatesDirs []string               `mapstructure:"render_templates_dirs,omitempty" json:"render_templates_dirs,omitempty" yaml:"render_templates_dirs,omitempty"`
 }
@@ -47,6 +48,14 @@ type SshCGeneral struct {
 	Debug            bool   `mapstructure:"debug,omitempty" json:"debug,omitempty" yaml:"debug,omitempty"`
 	RemotesConfDir   string `mapstructure:"remotes_confdir,omitempty" json:"remotes_confdir,omitempty" yaml:"remotes_confdir,omitempty"`
 	EnvSessionPrefix string `mapstructure:"env_session_prefix,omitempty" json:"env_session_prefix,omitempty" yaml:"env_session_prefix,omitempty"`
+	// Sync the uid/gid and the file modes of the source files.
+	EnsurePerms bool `mapstructure:"ensure_perms,omitempty" json:"ensure_perms,omitempty" yaml:"ensure_perms,omitempty"`
+	// Minimum size in bytes of the files transferred with the
+	// resume support. Zero disables the resume.
+	ResumeThreshold int64 `mapstructure:"resume_threshold,omitempty" json:"resume_threshold,omitempty" yaml:"resume_threshold,omitempty"`
+	// Skip the compilation of the templates with unchanged
+	// sources, variables and engine options.
+	CompileCache bool `mapstructure:"compile_cache,omitempty" json:"compile_cache,omitempty" yaml:"compile_cache,omitempty"`
 }
 
 type SshCLogging struct {
@@ -68,6 +77,9 @@ type SshCLogging struct {
 	// Enable/Disable commands output logging
 	RuntimeCmdsOutput bool `mapstructure:"runtime_cmds_output,omitempty" json:"runtime_cmds_output,omitempty" yaml:"runtime_cmds_output,omitempty"`
 	CmdsOutput        bool `mapstructure:"cmds_output,omitempty" json:"cmds_output,omitempty" yaml:"cmds_output,omitempty"`
+
+	// Enable/Disable the progress of the transferred files
+	PushProgressBar bool `mapstructure:"push_progressbar,omitempty" json:"push_progressbar,omitempty" yaml:"push_progressbar,omitempty"`
 }
 
 func NewSshComposeConfig(viper *v.Viper) *SshComposeConfig {
@@ -85,9 +97,13 @@ func (c *SshComposeConfig) Clone() *SshComposeConfig {
 	ans.EnvironmentDirs = c.EnvironmentDirs
 	ans.RenderDefaultFile = c.RenderDefaultFile
 	ans.RenderValuesFile = c.RenderValuesFile
+	ans.RenderValuesFiles = c.RenderValuesFiles
 	ans.RenderTemplatesDirs = c.RenderTemplatesDirs
 
 	ans.General.Debug = c.General.Debug
+	ans.General.EnsurePerms = c.General.EnsurePerms
+	ans.General.ResumeThreshold = c.General.ResumeThreshold
+	ans.General.CompileCache = c.General.CompileCache
 
 	ans.Logging.Path = c.Logging.Path
 	ans.Logging.EnableLogFile = c.Logging.EnableLogFile
@@ -97,6 +113,7 @@ func (c *SshComposeConfig) Clone() *SshComposeConfig {
 	ans.Logging.Color = c.Logging.Color
 	ans.Logging.RuntimeCmdsOutput = c.Logging.RuntimeCmdsOutput
 	ans.Logging.CmdsOutput = c.Logging.CmdsOutput
+	ans.Logging.PushProgressBar = c.Logging.PushProgressBar
 
 	return ans
 }
@@ -118,12 +135,23 @@ func (c *SshComposeConfig) GetSecurity() *SshCSecurity {
 }
 
 func (c *SshComposeConfig) IsEnableRenderEngine() bool {
-	if c.RenderValuesFile != "" || c.RenderDefaultFile != "" {
+	if c.RenderValuesFile != "" || c.RenderDefaultFile != "" ||
+		len(c.RenderValuesFiles) > 0 {
 		return true
 	}
 	return false
 }
 
+// GetRenderValuesFiles returns the ordered list of the render values
+// files. The render_values_file option is the first of the list.
+func (c *SshComposeConfig) GetRenderValuesFiles() []string {
+	ans := []string{}
+	if c.RenderValuesFile != "" {
+		ans = append(ans, c.RenderValuesFile)
+	}
+	return append(ans, c.RenderValuesFiles...)
+}
+
 func (c *SshComposeConfig) Unmarshal() error {
 	var err error
 
@@ -167,6 +195,10 @@ func (c *SshComposeConfig) SetRenderEnvs(envs []string) error {
 func GenDefault(viper *v.Viper) {
 	viper.SetDefault("general.debug", false)
 	viper.SetDefault("general.env_session_prefix", "SSH_COMPOSE")
+	viper.SetDefault("general.ensure_perms", false)
+	// 64MB
+	viper.SetDefault("general.resume_threshold", 67108864)
+	viper.SetDefault("general.compile_cache", false)
 	viper.SetDefault("render_default_file", "")
 	viper.SetDefault("render_values_file", "")
 	viper.SetDefault("render_templates_dirs", []string{})
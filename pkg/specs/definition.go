@@ -14,6 +14,10 @@ type SshCEnvironment struct {
 
 	TemplateEngine SshCTemplateEngine `json:"template_engine,omitempty" yaml:"template_engine,omitempty"`
 
+	// Render values files (relative to the environment directory)
+	// merged over the values files of the configuration.
+	RenderValuesFiles []string `json:"render_values_files,omitempty" yaml:"render_values_files,omitempty"`
+
 	Projects []SshCProject `json:"projects" yaml:"projects"`
 
 	Commands             []SshCCommand `json:"commands,omitempty" yaml:"commands,omitempty"`
@@ -29,9 +33,11 @@ type SshCPackExtra struct {
 }
 
 type SshCHook struct {
-	Event      string   `json:"event" yaml:"event"`
-	Node       string   `json:"node" yaml:"node"`
-	Commands   []string `json:"commands,omitempty" yaml:"commands,omitempty"`
+	Event    string   `json:"event" yaml:"event"`
+	Node     string   `json:"node" yaml:"node"`
+	Commands []string `json:"commands,omitempty" yaml:"commands,omitempty"`
+	// Command and arguments executed without shell.
+	Args       []string `json:"args,omitempty" yaml:"args,omitempty"`
 	Out2Var    string   `json:"out2var,omitempty" yaml:"out2var,omitempty"`
 	Err2Var    string   `json:"err2var,omitempty" yaml:"err2var,omitempty"`
 	Entrypoint []string `json:"entrypoint,omitempty" yaml:"entrypoint,omitempty"`
@@ -41,9 +47,44 @@ type SshCHook struct {
 	// Cisco specific flags
 	CiscoEna bool `json:"cisco_ena,omitempty" yaml:"cisco_ena,omitempty"`
 
+	// Stdin content of the commands. The content could be
+	// defined inline or through a local file (relative to the
+	// environment directory) and rendered with the project
+	// template compiler.
+	Stdin         string `json:"stdin,omitempty" yaml:"stdin,omitempty"`
+	StdinFile     string `json:"stdin_file,omitempty" yaml:"stdin_file,omitempty"`
+	StdinTemplate bool   `json:"stdin_template,omitempty" yaml:"stdin_template,omitempty"`
+
+	// Local script (relative to the environment directory) rendered
+	// with the project template compiler, uploaded and executed.
+	Script       string   `json:"script,omitempty" yaml:"script,omitempty"`
+	ScriptArgs   []string `json:"script_args,omitempty" yaml:"script_args,omitempty"`
+	ScriptTmpDir string   `json:"script_tmpdir,omitempty" yaml:"script_tmpdir,omitempty"`
+
+	// Working directory of the commands. On the host a relative
+	// path is relative to the environment directory.
+	Cwd string `json:"cwd,omitempty" yaml:"cwd,omitempty"`
+	// Additional environment variables of the hook rendered
+	// with the project template compiler.
+	Envs map[string]string `json:"envs,omitempty" yaml:"envs,omitempty"`
+	// Define how the variables are propagated to the remote
+	// commands: json (default), export, none.
+	EnvMode string `json:"env_mode,omitempty" yaml:"env_mode,omitempty"`
+
+	// Privilege escalation options
+	Become        *bool  `json:"become,omitempty" yaml:"become,omitempty"`
+	BecomeUser    string `json:"become_user,omitempty" yaml:"become_user,omitempty"`
+	BecomeMethod  string `json:"become_method,omitempty" yaml:"become_method,omitempty"`
+	BecomePassVar string `json:"become_pass_var,omitempty" yaml:"become_pass_var,omitempty"`
+
 	// Pull resources
 	PullResources      []*SshCSyncResource `json:"pull,omitempty" yaml:"pull,omitempty"`
 	PullKeepSourcePath bool                `json:"pull_keep_sourcepath,omitempty" yaml:"pull_keep_sourcepath,omitempty"`
+
+	// Run the hook only if the compiled templates of the project,
+	// of the group and of the node are changed (requires the
+	// compile cache).
+	OnChanges bool `json:"on_changes,omitempty" yaml:"on_changes,omitempty"`
 }
 
 type SshCHooks struct {
@@ -95,11 +136,18 @@ type SshCGroup struct {
 
 	Ephemeral bool `json:"ephemeral,omitempty" yaml:"ephemeral,omitempty"`
 
+	// Privilege escalation options
+	Become        *bool  `json:"become,omitempty" yaml:"become,omitempty"`
+	BecomeUser    string `json:"become_user,omitempty" yaml:"become_user,omitempty"`
+	BecomeMethod  string `json:"become_method,omitempty" yaml:"become_method,omitempty"`
+	BecomePassVar string `json:"become_pass_var,omitempty" yaml:"become_pass_var,omitempty"`
+
 	Nodes []SshCNode `json:"nodes" yaml:"nodes"`
 
 	Hooks             []SshCHook           `json:"hooks" yaml:"hooks"`
 	IncludeHooksFiles []*SshCInclude       `json:"include_hooks_files,omitempty" yaml:"include_hooks_files,omitempty"`
 	ConfigTemplates   []SshCConfigTemplate `json:"config_templates,omitempty" yaml:"config_templates,omitempty"`
+	RemoteTemplates   []SshCRemoteTemplate `json:"remote_templates,omitempty" yaml:"remote_templates,omitempty"`
 }
 
 type SshCEnvVars struct {
@@ -120,7 +168,14 @@ type SshCNode struct {
 
 	Entrypoint []string `json:"entrypoint,omitempty" yaml:"entrypoint,omitempty"`
 
+	// Privilege escalation options
+	Become        *bool  `json:"become,omitempty" yaml:"become,omitempty"`
+	BecomeUser    string `json:"become_user,omitempty" yaml:"become_user,omitempty"`
+	BecomeMethod  string `json:"become_method,omitempty" yaml:"become_method,omitempty"`
+	BecomePassVar string `json:"become_pass_var,omitempty" yaml:"become_pass_var,omitempty"`
+
 	ConfigTemplates []SshCConfigTemplate `json:"config_templates,omitempty" yaml:"config_templates,omitempty"`
+	RemoteTemplates []SshCRemoteTemplate `json:"remote_templates,omitempty" yaml:"remote_templates,omitempty"`
 	SyncResources   []SshCSyncResource   `json:"sync_resources,omitempty" yaml:"sync_resources,omitempty"`
 
 	Hooks             []SshCHook     `json:"hooks" yaml:"hooks"`
@@ -130,11 +185,90 @@ type SshCNode struct {
 type SshCConfigTemplate struct {
 	Source      string `json:"source" yaml:"source"`
 	Destination string `json:"dst" yaml:"dst"`
+
+	// Override the template engine of the environment.
+	Engine string   `json:"engine,omitempty" yaml:"engine,omitempty"`
+	Opts   []string `json:"opts,omitempty" yaml:"opts,omitempty"`
+	// Fail on undefined variables.
+	Strict *bool `json:"strict,omitempty" yaml:"strict,omitempty"`
+
+	// Options of the directory templates: the suffix removed
+	// from the names of the compiled files and the gitignore-style
+	// patterns of the files copied without compilation.
+	StripSuffix string   `json:"strip_suffix,omitempty" yaml:"strip_suffix,omitempty"`
+	PassThrough []string `json:"pass_through,omitempty" yaml:"pass_through,omitempty"`
+}
+
+// SshCRemoteTemplate is a template compiled in memory and
+// written directly to the remote path of the node.
+type SshCRemoteTemplate struct {
+	Source      string `json:"source" yaml:"source"`
+	Destination string `json:"dst" yaml:"dst"`
+
+	// Ownership (names or numbers) and mode (octal notation)
+	// of the remote file.
+	Owner string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Group string `json:"group,omitempty" yaml:"group,omitempty"`
+	Mode  string `json:"mode,omitempty" yaml:"mode,omitempty"`
 }
 
 type SshCSyncResource struct {
 	Source      string `json:"source" yaml:"source"`
 	Destination string `json:"dst" yaml:"dst"`
+
+	// Change detection mode used to skip the unchanged
+	// files: size, mtime, checksum.
+	Compare string `json:"compare,omitempty" yaml:"compare,omitempty"`
+
+	// Ownership (names or numbers) and modes (octal notation)
+	// of the files and directories of the resource.
+	Owner    string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Group    string `json:"group,omitempty" yaml:"group,omitempty"`
+	FileMode string `json:"file_mode,omitempty" yaml:"file_mode,omitempty"`
+	DirMode  string `json:"dir_mode,omitempty" yaml:"dir_mode,omitempty"`
+
+	// Verify the SHA-256 checksum of the transferred files.
+	Verify bool `json:"verify,omitempty" yaml:"verify,omitempty"`
+
+	// Keep the previous version of the replaced files with
+	// a timestamp suffix.
+	Backup bool `json:"backup,omitempty" yaml:"backup,omitempty"`
+
+	// Gitignore-style patterns of the files to exclude. The
+	// patterns of the .sshcignore file in the source tree are
+	// evaluated before. If the include patterns are defined only
+	// the matching files not excluded are transferred.
+	Exclude []string `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+	Include []string `json:"include,omitempty" yaml:"include,omitempty"`
+
+	// Remove the remote files not available in the source
+	// directory. The protect list defines the paths (relative
+	// to the destination) or the glob patterns to keep.
+	Delete  bool     `json:"delete,omitempty" yaml:"delete,omitempty"`
+	Protect []string `json:"protect,omitempty" yaml:"protect,omitempty"`
+
+	// Transfer mode: sftp (default) or tar. The tar mode streams
+	// the files as a tar archive (optionally compressed with gzip)
+	// to the tar command of the remote node applying the tar-formers
+	// rename and filter rules.
+	Mode        string               `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Compression string               `json:"compression,omitempty" yaml:"compression,omitempty"`
+	TarRules    *tarf_specs.SpecFile `json:"tar_rules,omitempty" yaml:"tar_rules,omitempty"`
+
+	// Number of files transferred in parallel. If not defined
+	// it's used the sftp_workers option of the remote.
+	Workers int `json:"workers,omitempty" yaml:"workers,omitempty"`
+
+	// Filters of the pulled files. The newer_than option accepts
+	// a duration (ex. 12h, 7d) or a date. The sizes accept the
+	// K, M, G suffixes.
+	NewerThan string `json:"newer_than,omitempty" yaml:"newer_than,omitempty"`
+	MinSize   string `json:"min_size,omitempty" yaml:"min_size,omitempty"`
+	MaxSize   string `json:"max_size,omitempty" yaml:"max_size,omitempty"`
+
+	// Sync the resource only if the compiled files under the
+	// source are changed (requires the compile cache).
+	OnChanges bool `json:"on_changes,omitempty" yaml:"on_changes,omitempty"`
 }
 
 type SshCCommand struct {
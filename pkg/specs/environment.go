@@ -7,6 +7,7 @@ package specs
 import (
 	"errors"
 	"path"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -32,6 +33,49 @@ func EnvironmentFromYaml(data []byte, file string) (*SshCEnvironment, error) {
 	return ans, nil
 }
 
+// RenderValuesFilesFromYaml returns the render_values_files option of
+// an environment file before that the file is rendered. Until it's
+// rendered the file could be not a valid YAML and so only the top level
+// block of the option is parsed in this case.
+func RenderValuesFilesFromYaml(data []byte) ([]string, error) {
+	ans := struct {
+		RenderValuesFiles []string `yaml:"render_values_files,omitempty"`
+	}{}
+
+	if err := yaml.Unmarshal(data, &ans); err == nil {
+		return ans.RenderValuesFiles, nil
+	}
+
+	block := []string{}
+	lines := strings.Split(string(data), "\n")
+	for idx, line := range lines {
+		if !strings.HasPrefix(line, "render_values_files:") {
+			continue
+		}
+
+		block = append(block, line)
+		for _, next := range lines[idx+1:] {
+			if next != "" && !strings.HasPrefix(next, " ") &&
+				!strings.HasPrefix(next, "\t") && !strings.HasPrefix(next, "-") &&
+				!strings.HasPrefix(next, "#") {
+				break
+			}
+			block = append(block, next)
+		}
+		break
+	}
+
+	if len(block) == 0 {
+		return []string{}, nil
+	}
+
+	if err := yaml.Unmarshal([]byte(strings.Join(block, "\n")), &ans); err != nil {
+		return nil, err
+	}
+
+	return ans.RenderValuesFiles, nil
+}
+
 func (e *SshCEnvironment) GetProjectByName(pName string) *SshCProject {
 	for idx, p := range e.Projects {
 		if p.Name == pName {
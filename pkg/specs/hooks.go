@@ -17,6 +17,10 @@ const (
 	HookPostGroup    = "post-group"
 	HookPostProject  = "post-project"
 	HookFinally      = "finally"
+
+	EnvModeJson   = "json"
+	EnvModeExport = "export"
+	EnvModeNone   = "none"
 )
 
 func getHooks(hooks *[]SshCHook, event string) []SshCHook {
@@ -73,6 +77,41 @@ func (h *SshCHook) HasPullResources() bool {
 	return false
 }
 
+func (h *SshCHook) HasArgs() bool {
+	if len(h.Args) > 0 {
+		return true
+	}
+	return false
+}
+
+func (h *SshCHook) HasStdin() bool {
+	if h.Stdin != "" || h.StdinFile != "" {
+		return true
+	}
+	return false
+}
+
+func (h *SshCHook) HasScript() bool {
+	if h.Script != "" {
+		return true
+	}
+	return false
+}
+
+func (h *SshCHook) GetScriptTmpDir() string {
+	if h.ScriptTmpDir == "" {
+		return "/tmp"
+	}
+	return h.ScriptTmpDir
+}
+
+func (h *SshCHook) GetEnvMode() string {
+	if h.EnvMode == "" {
+		return EnvModeJson
+	}
+	return h.EnvMode
+}
+
 func (h *SshCHook) ToProcess(enabledFlags, disabledFlags []string) bool {
 	ans := false
 
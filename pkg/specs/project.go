@@ -48,6 +48,54 @@ func (p *SshCProject) GetGroupByName(name string) *SshCGroup {
 	return nil
 }
 
+// GetNodeByName returns the node with the input name and
+// its group.
+func (p *SshCProject) GetNodeByName(name string) (*SshCGroup, *SshCNode) {
+	for idx := range p.Groups {
+		for nidx := range p.Groups[idx].Nodes {
+			if p.Groups[idx].Nodes[nidx].GetName() == name {
+				return &p.Groups[idx], &p.Groups[idx].Nodes[nidx]
+			}
+		}
+	}
+	return nil, nil
+}
+
+// GetSecretVar returns the value of the variable defined in
+// the decrypted vars files. The last definition wins.
+func (p *SshCProject) GetSecretVar(name string) (interface{}, bool) {
+	var ans interface{} = nil
+	present := false
+
+	for _, e := range p.Environments {
+		if !e.Encrypted {
+			continue
+		}
+		if v, ok := e.EnvVars[name]; ok {
+			ans = v
+			present = true
+		}
+	}
+
+	return ans, present
+}
+
+// GetEnvVar returns the value of the variable with the
+// input name. The last definition wins.
+func (p *SshCProject) GetEnvVar(name string) (interface{}, bool) {
+	var ans interface{} = nil
+	present := false
+
+	for _, e := range p.Environments {
+		if v, ok := e.EnvVars[name]; ok {
+			ans = v
+			present = true
+		}
+	}
+
+	return ans, present
+}
+
 func (p *SshCProject) GetEnvsMap() (map[string]string, error) {
 	ans := map[string]string{}
 
@@ -122,26 +170,23 @@ func (p *SshCProjectSanitized) GetName() string         { return p.Name }
 func (p *SshCProjectSanitized) GetDescription() string  { return p.Description }
 func (p *SshCProjectSanitized) GetGroups() *[]SshCGroup { return &p.Groups }
 
-func (p *SshCProject) LoadEnvVarsFile(file string, config *SshComposeConfig) error {
+// LoadEnvVarsFile loads an additional vars file rendered with the
+// values files in input, that are the values files of the configuration
+// followed by the values files of the environment of the project.
+func (p *SshCProject) LoadEnvVarsFile(file string, config *SshComposeConfig,
+	valuesFiles []string) error {
 	content, err := os.ReadFile(file)
 	if err != nil {
 		return err
 	}
 
-	// Render the decrypt content
-	renderOut, err := helpers_render.RenderContentWithTemplates(string(content),
-		config.RenderValuesFile,
-		config.RenderDefaultFile,
-		"-",
-		config.RenderEnvsVars,
-		config.RenderTemplatesDirs,
-	)
+	renderOut, err := renderVarsContent(content, config, valuesFiles)
 	if err != nil {
 		return fmt.Errorf("error on render vars of the file %s: %s",
 			file, err.Error())
 	}
 
-	evars, err := EnvVarsFromYaml(content)
+	evars, err := EnvVarsFromYaml(renderOut)
 	if err != nil {
 		return err
 	}
@@ -185,24 +230,20 @@ func (p *SshCProject) LoadEnvVarsFile(file string, config *SshComposeConfig) err
 				file, err.Error())
 		}
 		// Render the decrypt content
-		renderOut, err = helpers_render.RenderContentWithTemplates(string(decodedBytes),
-			config.RenderValuesFile,
-			config.RenderDefaultFile,
-			"-",
-			config.RenderEnvsVars,
-			config.RenderTemplatesDirs,
-		)
+		renderOut, err = renderVarsContent(decodedBytes, config, valuesFiles)
 		if err != nil {
 			return fmt.Errorf("error on render encrypted vars of the file %s: %s",
 				file, err.Error())
 		}
 
-		evarsDecoded, err := EnvVarsFromYaml([]byte(renderOut))
+		evarsDecoded, err := EnvVarsFromYaml(renderOut)
 		if err != nil {
 			return fmt.Errorf("error on parse decrypted vars content for file %s:\n%s",
 				file, err.Error())
 		}
 
+		// Keep the flag used to identify the secret variables.
+		evarsDecoded.Encrypted = true
 		evars = evarsDecoded
 	}
 
@@ -211,6 +252,29 @@ func (p *SshCProject) LoadEnvVarsFile(file string, config *SshComposeConfig) err
 	return nil
 }
 
+// renderVarsContent renders the content of a vars file if there are
+// render files configured.
+func renderVarsContent(content []byte, config *SshComposeConfig,
+	valuesFiles []string) ([]byte, error) {
+
+	if len(valuesFiles) == 0 && config.RenderDefaultFile == "" {
+		return content, nil
+	}
+
+	renderOut, err := helpers_render.RenderContentWithValues(string(content),
+		valuesFiles,
+		config.RenderDefaultFile,
+		"-",
+		config.RenderEnvsVars,
+		config.RenderTemplatesDirs,
+	)
+	if err != nil {
+		return content, err
+	}
+
+	return []byte(renderOut), nil
+}
+
 func (p *SshCProject) AddHooks(h *SshCHooks) {
 	if len(h.Hooks) > 0 {
 		p.Hooks = append(p.Hooks, h.Hooks...)
@@ -54,6 +54,8 @@ type Remote struct {
 	CiscoPrompt    string `json:"cisco_prompt,omitempty" yaml:"cisco_prompt,omitempty"`
 	CiscoEnaPrompt string `json:"cisco_enaprompt,omitempty" yaml:"cisco_enaprompt,omitempty"`
 	CiscoEnaPass   string `json:"cisco_enapass,omitempty" yaml:"cisco_enapass,omitempty"`
+	// Password used by the privilege escalation method (become)
+	BecomePass string `json:"become_pass,omitempty" yaml:"become_pass,omitempty"`
 
 	Labels  []string          `json:"labels,omitempty" yaml:"labels,omitempty"`
 	Options map[string]string `json:"options,omitempty" yaml:"options,omitempty"`
@@ -63,6 +65,20 @@ type Remote struct {
 	TunLocalPort int    `json:"tun_local_port,omitempty" yaml:"tun_local_port,omitempty"`
 	TunLocalAddr string `json:"tun_local_addr,omitempty" yaml:"tun_local_addr,omitempty"`
 	TunLocalBind bool   `json:"tun_local_bind,omitempty" yaml:"tun_local_bind,omitempty"`
+
+	// Transfer method of the files: sftp|scp|exec. If not defined
+	// it's used sftp with the fallback to exec when the sftp
+	// subsystem is not available.
+	Transfer string `json:"transfer,omitempty" yaml:"transfer,omitempty"`
+
+	// SFTP client options
+	SftpMaxPacket             int   `json:"sftp_max_packet,omitempty" yaml:"sftp_max_packet,omitempty"`
+	SftpMaxConcurrentRequests int   `json:"sftp_max_concurrent_requests,omitempty" yaml:"sftp_max_concurrent_requests,omitempty"`
+	SftpConcurrentWrites      *bool `json:"sftp_concurrent_writes,omitempty" yaml:"sftp_concurrent_writes,omitempty"`
+	SftpConcurrentReads       *bool `json:"sftp_concurrent_reads,omitempty" yaml:"sftp_concurrent_reads,omitempty"`
+	SftpUseFstat              bool  `json:"sftp_use_fstat,omitempty" yaml:"sftp_use_fstat,omitempty"`
+	// Number of files transferred in parallel by the sync resources.
+	SftpWorkers int `json:"sftp_workers,omitempty" yaml:"sftp_workers,omitempty"`
 }
 
 func NewRemote(host, protocol, authMethod string, port int) *Remote {
@@ -106,8 +122,17 @@ func (r *Remote) GetCiscoPrompt() string    { return r.CiscoPrompt }
 func (r *Remote) GetCiscoEnaPrompt() string { return r.CiscoEnaPrompt }
 func (r *Remote) GetCiscoEnaPass() string   { return r.CiscoEnaPass }
 func (r *Remote) GetCiscoDevice() bool      { return r.CiscoDevice }
+func (r *Remote) GetBecomePass() string     { return r.BecomePass }
 func (r *Remote) GetChain() []Remote        { return r.Chain }
 
+func (r *Remote) GetTransfer() string               { return r.Transfer }
+func (r *Remote) GetSftpMaxPacket() int             { return r.SftpMaxPacket }
+func (r *Remote) GetSftpMaxConcurrentRequests() int { return r.SftpMaxConcurrentRequests }
+func (r *Remote) GetSftpConcurrentWrites() *bool    { return r.SftpConcurrentWrites }
+func (r *Remote) GetSftpConcurrentReads() *bool     { return r.SftpConcurrentReads }
+func (r *Remote) GetSftpUseFstat() bool             { return r.SftpUseFstat }
+func (r *Remote) GetSftpWorkers() int               { return r.SftpWorkers }
+
 func (r *Remote) HasChain() bool { return len(r.Chain) > 0 }
 
 func (r *Remote) GetOption(o string) string {
@@ -282,7 +307,7 @@ func (rc *RemotesConfig) Write(config *SshComposeConfig) error {
 
 		keyBytes, err := base64.StdEncoding.DecodeString(config.GetSecurity().Key)
 		if err != nil {
-			fmt.Println("error on decode key: %s", err.Error())
+			fmt.Println("error on decode key:", err.Error())
 			os.Exit(1)
 		}
 
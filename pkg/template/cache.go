@@ -0,0 +1,239 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package template
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/MottainaiCI/ssh-compose/pkg/helpers"
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+)
+
+// Name of the compile cache file stored in the environment directory.
+const CompileCacheFile = ".ssh-compose-cache.json"
+
+// CompileCache stores the hash of the inputs (template source,
+// variables, engine options and the files and the lookups read by
+// the template) of the compiled files. The files with unchanged
+// inputs and output are not compiled again.
+type CompileCache struct {
+	File    string                        `json:"-"`
+	Entries map[string]*CompileCacheEntry `json:"entries"`
+
+	// Compiled files of the current run and their changed flag.
+	changed map[string]bool
+	// Node or group of the compiled files. The same destination
+	// could be compiled with different inputs for every node.
+	scope string
+}
+
+type CompileCacheEntry struct {
+	Key    string `json:"key"`
+	Output string `json:"output"`
+	// Files and lookups read by the template.
+	Deps []string `json:"deps,omitempty"`
+}
+
+// dependencyTracker is implemented by the compilers that record
+// the files and the lookups read during the compilation.
+type dependencyTracker interface {
+	startTracking()
+	stopTracking() ([]string, bool)
+	readDependency(dep string) ([]byte, error)
+}
+
+func NewCompileCache(file string) *CompileCache {
+	return &CompileCache{
+		File:    file,
+		Entries: make(map[string]*CompileCacheEntry, 0),
+		changed: make(map[string]bool, 0),
+	}
+}
+
+// NewEnvCompileCache returns the compile cache of the environment
+// with the entries of the previous runs.
+func NewEnvCompileCache(env *specs.SshCEnvironment) (*CompileCache, error) {
+	envBaseAbs, err := filepath.Abs(filepath.Dir(env.File))
+	if err != nil {
+		return nil, err
+	}
+
+	ans := NewCompileCache(filepath.Join(envBaseAbs, CompileCacheFile))
+	return ans, ans.Load()
+}
+
+// WithScope returns the cache that stores the entries of the
+// node or of the group in input.
+func (c *CompileCache) WithScope(scope string) *CompileCache {
+	return &CompileCache{
+		File:    c.File,
+		Entries: c.Entries,
+		changed: c.changed,
+		scope:   scope,
+	}
+}
+
+func (c *CompileCache) getEntryKey(destFile string) string {
+	if c.scope == "" {
+		return destFile
+	}
+	return c.scope + ":" + destFile
+}
+
+// Load reads the entries from the cache file. A missing file
+// is not an error.
+func (c *CompileCache) Load() error {
+	data, err := os.ReadFile(c.File)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		// A corrupted cache is ignored and rewritten.
+		c.Entries = make(map[string]*CompileCacheEntry, 0)
+	}
+	if c.Entries == nil {
+		c.Entries = make(map[string]*CompileCacheEntry, 0)
+	}
+
+	return nil
+}
+
+func (c *CompileCache) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.File, data, 0644)
+}
+
+// IsChanged returns true if the file is been compiled in the current
+// run with a different content or if the file is not tracked.
+func (c *CompileCache) IsChanged(destFile string) bool {
+	changed, ok := c.changed[filepath.Clean(destFile)]
+	if !ok {
+		return true
+	}
+	return changed
+}
+
+// HasChanges returns true if one of the files is changed.
+func (c *CompileCache) HasChanges(files []string) bool {
+	for _, f := range files {
+		if c.IsChanged(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// getKey returns the hash of the inputs of the compilation.
+func (c *CompileCache) getKey(engineKey, sourceFile string, vars interface{},
+	tracker dependencyTracker, deps []string) (string, error) {
+	source, err := os.ReadFile(sourceFile)
+	if err != nil {
+		return "", err
+	}
+
+	varsData, err := json.Marshal(vars)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(engineKey))
+	h.Write([]byte{0})
+	h.Write(source)
+	h.Write([]byte{0})
+	h.Write(varsData)
+
+	for _, dep := range deps {
+		data, err := tracker.readDependency(dep)
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+		h.Write([]byte(dep))
+		h.Write([]byte{0})
+		h.Write(data)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// compile runs the compile function only if the inputs or the
+// output of the file are changed from the last run. Without the
+// tracker the file has no dependencies. The files of the compilers
+// that don't track the dependencies are always compiled.
+func (c *CompileCache) compile(engineKey, sourceFile, destFile string,
+	vars interface{}, tracker dependencyTracker, compileFn func() error) error {
+
+	destFile = filepath.Clean(destFile)
+	entryKey := c.getEntryKey(destFile)
+
+	if entry, ok := c.Entries[entryKey]; ok && entry.Key != "" {
+		key, err := c.getKey(engineKey, sourceFile, vars, tracker, entry.Deps)
+		if err == nil && entry.Key == key {
+			sum, err := helpers.FileSha256(destFile)
+			if err == nil && sum == entry.Output {
+				c.changed[destFile] = false
+				return nil
+			}
+		}
+	}
+
+	cacheable := true
+	deps := []string{}
+	if tracker != nil {
+		tracker.startTracking()
+	}
+	err := compileFn()
+	if tracker != nil {
+		deps, cacheable = tracker.stopTracking()
+	}
+	if err != nil {
+		delete(c.Entries, entryKey)
+		return err
+	}
+
+	sum, err := helpers.FileSha256(destFile)
+	if err != nil {
+		return err
+	}
+
+	key := ""
+	if cacheable {
+		key, err = c.getKey(engineKey, sourceFile, vars, tracker, deps)
+		if err != nil {
+			// The file is compiled again on the next run.
+			key = ""
+		}
+	}
+
+	prev, ok := c.Entries[entryKey]
+	c.changed[destFile] = !ok || prev.Output != sum
+	c.Entries[entryKey] = &CompileCacheEntry{Key: key, Output: sum, Deps: deps}
+
+	return nil
+}
+
+// getDependencyTracker returns the tracker of the compiler and
+// false if the compiler doesn't track the dependencies.
+func getDependencyTracker(compiler SshCTemplateCompiler) (dependencyTracker, bool) {
+	if registry, ok := compiler.(*TemplateCompilerRegistry); ok {
+		compiler = registry.SshCTemplateCompiler
+	}
+	tracker, ok := compiler.(dependencyTracker)
+	return tracker, ok
+}
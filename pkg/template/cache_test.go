@@ -0,0 +1,223 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package template_test
+
+import (
+	"os"
+	"path/filepath"
+
+	log "github.com/MottainaiCI/ssh-compose/pkg/logger"
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+	. "github.com/MottainaiCI/ssh-compose/pkg/template"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Compile cache", func() {
+
+	Context("Incremental compile", func() {
+
+		It("Skip unchanged templates", func() {
+			log.NewSshCLogger(specs.NewSshComposeConfig(nil)).SetAsDefault()
+
+			dir, err := os.MkdirTemp("", "sshc-cache")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			proj := &specs.SshCProject{
+				Name: "project1",
+				Environments: []specs.SshCEnvVars{
+					{
+						EnvVars: map[string]interface{}{
+							"key1": "value1",
+						},
+					},
+				},
+				ConfigTemplates: []specs.SshCConfigTemplate{
+					{Source: "a.tmpl", Destination: "a.conf"},
+				},
+			}
+			env := &specs.SshCEnvironment{
+				File:           filepath.Join(dir, "env.yml"),
+				TemplateEngine: specs.SshCTemplateEngine{Engine: "mottainai"},
+			}
+			src := filepath.Join(dir, "a.tmpl")
+			dst := filepath.Join(dir, "a.conf")
+			Expect(os.WriteFile(src, []byte("{{ .key1 }}"), 0644)).Should(BeNil())
+
+			compile := func() *CompileCache {
+				cache, err := NewEnvCompileCache(env)
+				Expect(err).Should(BeNil())
+				compiler, err := NewProjectTemplateCompiler(env, proj)
+				Expect(err).Should(BeNil())
+				err = CompileProjectFiles(proj, compiler, CompilerOpts{Cache: cache})
+				Expect(err).Should(BeNil())
+				Expect(cache.Save()).Should(BeNil())
+				return cache
+			}
+
+			cache := compile()
+			Expect(cache.IsChanged(dst)).To(BeTrue())
+
+			// Unchanged inputs
+			cache = compile()
+			Expect(cache.IsChanged(dst)).To(BeFalse())
+
+			// Changed variables
+			proj.Environments[0].EnvVars["key1"] = "value2"
+			cache = compile()
+			Expect(cache.IsChanged(dst)).To(BeTrue())
+			data, err := os.ReadFile(dst)
+			Expect(err).Should(BeNil())
+			Expect(string(data)).To(Equal("value2"))
+
+			// Output modified locally is compiled again
+			// without changes.
+			Expect(os.WriteFile(dst, []byte("foo"), 0644)).Should(BeNil())
+			cache = compile()
+			Expect(cache.IsChanged(dst)).To(BeFalse())
+			data, err = os.ReadFile(dst)
+			Expect(err).Should(BeNil())
+			Expect(string(data)).To(Equal("value2"))
+
+			// Changed source
+			Expect(os.WriteFile(src, []byte("v={{ .key1 }}"), 0644)).Should(BeNil())
+			cache = compile()
+			Expect(cache.IsChanged(dst)).To(BeTrue())
+		})
+
+		It("Compile again the templates with changed dependencies", func() {
+			log.NewSshCLogger(specs.NewSshComposeConfig(nil)).SetAsDefault()
+
+			dir, err := os.MkdirTemp("", "sshc-cache")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			proj := &specs.SshCProject{
+				Name: "project1",
+				Environments: []specs.SshCEnvVars{
+					{EnvVars: map[string]interface{}{}},
+				},
+				Groups: []specs.SshCGroup{
+					{
+						Name: "group1",
+						Nodes: []specs.SshCNode{
+							{Name: "node1", Endpoint: "10.0.0.1"},
+						},
+					},
+				},
+				ConfigTemplates: []specs.SshCConfigTemplate{
+					{Source: "a.tmpl", Destination: "a.conf"},
+					{Source: "b.j2", Destination: "b.conf", Engine: "jinja2-native"},
+					{Source: "c.tmpl", Destination: "c.conf"},
+				},
+			}
+			env := &specs.SshCEnvironment{
+				File:           filepath.Join(dir, "env.yml"),
+				TemplateEngine: specs.SshCTemplateEngine{Engine: "mottainai"},
+			}
+			files := map[string]string{
+				"a.tmpl":    `{{ include "part.tmpl" }}`,
+				"part.tmpl": "part1",
+				"b.j2":      `{% include "part.j2" %}`,
+				"part.j2":   "part1",
+				"c.tmpl":    `{{ (node "node1").Endpoint }}`,
+			}
+			for f, content := range files {
+				Expect(os.WriteFile(filepath.Join(dir, f), []byte(content), 0644)).Should(BeNil())
+			}
+
+			compile := func() *CompileCache {
+				cache, err := NewEnvCompileCache(env)
+				Expect(err).Should(BeNil())
+				compiler, err := NewProjectTemplateCompiler(env, proj)
+				Expect(err).Should(BeNil())
+				err = CompileProjectFiles(proj, compiler, CompilerOpts{Cache: cache})
+				Expect(err).Should(BeNil())
+				Expect(cache.Save()).Should(BeNil())
+				return cache
+			}
+
+			compile()
+			cache := compile()
+			for _, f := range []string{"a.conf", "b.conf", "c.conf"} {
+				Expect(cache.IsChanged(filepath.Join(dir, f))).To(BeFalse())
+			}
+
+			Expect(os.WriteFile(filepath.Join(dir, "part.tmpl"), []byte("part2"), 0644)).Should(BeNil())
+			Expect(os.WriteFile(filepath.Join(dir, "part.j2"), []byte("part2"), 0644)).Should(BeNil())
+			proj.Groups[0].Nodes[0].Endpoint = "10.0.0.2"
+
+			cache = compile()
+			expected := map[string]string{
+				"a.conf": "part2",
+				"b.conf": "part2",
+				"c.conf": "10.0.0.2",
+			}
+			for f, content := range expected {
+				Expect(cache.IsChanged(filepath.Join(dir, f))).To(BeTrue())
+				data, err := os.ReadFile(filepath.Join(dir, f))
+				Expect(err).Should(BeNil())
+				Expect(string(data)).To(Equal(content))
+			}
+		})
+
+		It("Track the files of every node with the same destination", func() {
+			log.NewSshCLogger(specs.NewSshComposeConfig(nil)).SetAsDefault()
+
+			dir, err := os.MkdirTemp("", "sshc-cache")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			templates := []specs.SshCConfigTemplate{
+				{Source: "n.tmpl", Destination: "n.conf"},
+			}
+			proj := &specs.SshCProject{
+				Name: "project1",
+				Environments: []specs.SshCEnvVars{
+					{EnvVars: map[string]interface{}{}},
+				},
+				Groups: []specs.SshCGroup{
+					{
+						Name: "group1",
+						Nodes: []specs.SshCNode{
+							{Name: "node1", ConfigTemplates: templates},
+							{Name: "node2", ConfigTemplates: templates},
+						},
+					},
+				},
+			}
+			env := &specs.SshCEnvironment{
+				File:           filepath.Join(dir, "env.yml"),
+				TemplateEngine: specs.SshCTemplateEngine{Engine: "mottainai"},
+			}
+			dst := filepath.Join(dir, "n.conf")
+			Expect(os.WriteFile(filepath.Join(dir, "n.tmpl"),
+				[]byte("{{ .node.Name }}"), 0644)).Should(BeNil())
+
+			compile := func(changed bool) {
+				cache, err := NewEnvCompileCache(env)
+				Expect(err).Should(BeNil())
+				compiler, err := NewProjectTemplateCompiler(env, proj)
+				Expect(err).Should(BeNil())
+
+				for _, node := range proj.Groups[0].Nodes {
+					err = CompileNodeFiles(node, compiler, CompilerOpts{Cache: cache})
+					Expect(err).Should(BeNil())
+					Expect(cache.IsChanged(dst)).To(Equal(changed))
+
+					data, err := os.ReadFile(dst)
+					Expect(err).Should(BeNil())
+					Expect(string(data)).To(Equal(node.Name))
+				}
+				Expect(cache.Save()).Should(BeNil())
+			}
+
+			compile(true)
+			compile(false)
+		})
+	})
+})
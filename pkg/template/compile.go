@@ -7,7 +7,6 @@ Based on the lxd-compose code
 package template
 
 import (
-	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -20,6 +19,20 @@ type CompilerOpts struct {
 	Sources        []string
 	GroupsEnabled  []string
 	GroupsDisabled []string
+
+	// Called with the path of every compiled file.
+	OnCompiled func(destFile string)
+	// Skip the compilation of the unchanged files.
+	Cache *CompileCache
+}
+
+func (o *CompilerOpts) notify(files []string) {
+	if o.OnCompiled == nil {
+		return
+	}
+	for _, f := range files {
+		o.OnCompiled(f)
+	}
 }
 
 func (o *CompilerOpts) IsGroupEnabled(g string) bool {
@@ -56,19 +69,12 @@ func (o *CompilerOpts) IsGroupEnabled(g string) bool {
 }
 
 func NewProjectTemplateCompiler(env *specs.SshCEnvironment, proj *specs.SshCProject) (SshCTemplateCompiler, error) {
-	var compiler SshCTemplateCompiler
-
-	switch env.TemplateEngine.Engine {
-	case "jinja2":
-		compiler = NewJinja2Compiler(proj)
-	case "mottainai":
-		compiler = NewMottainaiCompiler(proj)
-	default:
-		return compiler, errors.New("Invalid template engine " + env.TemplateEngine.Engine)
+	compiler, err := NewTemplateCompilerRegistry(env.TemplateEngine, proj)
+	if err != nil {
+		return nil, err
 	}
 
 	compiler.SetEnvBaseDir(filepath.Dir(env.File))
-	compiler.SetOpts(env.TemplateEngine.Opts)
 	compiler.InitVars()
 
 	return compiler, nil
@@ -137,6 +143,11 @@ func CompileGroupFiles(group *specs.SshCGroup, compiler SshCTemplateCompiler, op
 	// Set node key with current group
 	(*compiler.GetVars())["group"] = group
 
+	cache := opts.Cache
+	if cache != nil {
+		cache = cache.WithScope("group/" + group.Name)
+	}
+
 	for _, s := range targets {
 		sourceFile = filepath.Join(envBaseAbs, s.Source)
 		if filepath.IsAbs(s.Destination) {
@@ -145,10 +156,11 @@ func CompileGroupFiles(group *specs.SshCGroup, compiler SshCTemplateCompiler, op
 			destFile = filepath.Join(envBaseAbs, s.Destination)
 		}
 
-		err := compiler.Compile(sourceFile, destFile)
+		files, err := CompileConfigTemplate(compiler, &s, sourceFile, destFile, cache)
 		if err != nil {
 			return err
 		}
+		opts.notify(files)
 
 		log.GetDefaultLogger().Info(" " + sourceFile + " -> " + destFile + " OK")
 	}
@@ -189,10 +201,11 @@ func CompileProjectFiles(proj *specs.SshCProject, compiler SshCTemplateCompiler,
 			destFile = filepath.Join(envBaseAbs, s.Destination)
 		}
 
-		err := compiler.Compile(sourceFile, destFile)
+		files, err := CompileConfigTemplate(compiler, &s, sourceFile, destFile, opts.Cache)
 		if err != nil {
 			return err
 		}
+		opts.notify(files)
 
 		log.GetDefaultLogger().Info(" " + sourceFile + " -> " + destFile + " OK")
 	}
@@ -230,6 +243,11 @@ func CompileNodeFiles(node specs.SshCNode, compiler SshCTemplateCompiler, opts C
 	// Set node key with current node
 	(*compiler.GetVars())["node"] = node
 
+	cache := opts.Cache
+	if cache != nil {
+		cache = cache.WithScope("node/" + node.GetName())
+	}
+
 	if len(node.Labels) > 0 {
 		for k, v := range node.Labels {
 			(*compiler.GetVars())[k] = v
@@ -264,10 +282,11 @@ func CompileNodeFiles(node specs.SshCNode, compiler SshCTemplateCompiler, opts C
 					fmt.Sprintf(">>> [%s] Compiling %s -> %s :coffee:",
 						node.GetName(), sourceFile, destFile))))
 
-		err := compiler.Compile(sourceFile, destFile)
+		files, err := CompileConfigTemplate(compiler, &s, sourceFile, destFile, cache)
 		if err != nil {
 			return err
 		}
+		opts.notify(files)
 
 		logger.InfoC(
 			logger.Aurora.BrightCyan(
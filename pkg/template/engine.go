@@ -7,12 +7,26 @@ Based on the lxd-compose code
 package template
 
 import (
+	"encoding/json"
+	"os"
+	"strings"
+
 	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
 )
 
+// Kinds of the dependencies of a compiled file tracked by the
+// compile cache in addition to the source and the variables.
+const (
+	DependencyFile   = "file"
+	DependencyNode   = "node"
+	DependencyGroup  = "group"
+	DependencySecret = "secret"
+)
+
 type SshCTemplateCompiler interface {
 	InitVars()
 	SetOpts([]string)
+	SetStrict(*bool)
 	Compile(sourceFile, destFile string) error
 	CompileRaw(sourceContent string) (string, error)
 	GetEnvBaseDir() string
@@ -23,8 +37,13 @@ type SshCTemplateCompiler interface {
 type DefaultCompiler struct {
 	Project    *specs.SshCProject
 	Opts       []string
+	Strict     *bool
 	Vars       map[string]interface{}
 	EnvBaseDir string
+
+	// Dependencies of the current compilation. Nil if the
+	// tracking is disabled.
+	deps []string
 }
 
 func (r *DefaultCompiler) InitVars() {
@@ -43,6 +62,12 @@ func (r *DefaultCompiler) SetOpts(o []string) {
 	r.Opts = o
 }
 
+// SetStrict enables or disables the failure on the undefined
+// variables. With nil it's used the default of the engine.
+func (r *DefaultCompiler) SetStrict(s *bool) {
+	r.Strict = s
+}
+
 func (r *DefaultCompiler) GetEnvBaseDir() string {
 	return r.EnvBaseDir
 }
@@ -54,3 +79,52 @@ func (r *DefaultCompiler) SetEnvBaseDir(dir string) {
 func (r *DefaultCompiler) GetVars() *map[string]interface{} {
 	return &r.Vars
 }
+
+func (r *DefaultCompiler) startTracking() {
+	r.deps = []string{}
+}
+
+func (r *DefaultCompiler) stopTracking() ([]string, bool) {
+	ans := r.deps
+	r.deps = nil
+	return ans, true
+}
+
+func (r *DefaultCompiler) addDependency(dep string) {
+	if r.deps == nil {
+		return
+	}
+	for _, d := range r.deps {
+		if d == dep {
+			return
+		}
+	}
+	r.deps = append(r.deps, dep)
+}
+
+// readDependency returns the current content of a file or the
+// current data of a lookup of the templates.
+func (r *DefaultCompiler) readDependency(dep string) ([]byte, error) {
+	kind, name, _ := strings.Cut(dep, ":")
+
+	var data interface{}
+	switch kind {
+	case DependencyFile:
+		content, err := os.ReadFile(name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return []byte{}, nil
+			}
+			return nil, err
+		}
+		return content, nil
+	case DependencyNode:
+		_, data = r.Project.GetNodeByName(name)
+	case DependencyGroup:
+		data = r.Project.GetGroupByName(name)
+	case DependencySecret:
+		data, _ = r.Project.GetSecretVar(name)
+	}
+
+	return json.Marshal(data)
+}
@@ -0,0 +1,955 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package jinja
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Max depth of the nested include statements.
+	maxIncludeDepth = 32
+	// Max depth of the nested macro calls.
+	maxCallDepth = 256
+	// Max number of items of the sequences created by range()
+	// and by the repeat operator.
+	maxRangeSize = 100000
+	// Max size of the strings created by the repeat operator
+	// and by the padding of the filters.
+	maxStringSize = 16 * 1024 * 1024
+	// Max width of the indentation and of the padding of the filters.
+	maxFilterWidth = 4096
+)
+
+type scope struct {
+	vars   map[string]interface{}
+	parent *scope
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{
+		vars:   make(map[string]interface{}, 0),
+		parent: parent,
+	}
+}
+
+func (s *scope) lookup(name string) (interface{}, bool) {
+	for cur := s; cur != nil; cur = cur.parent {
+		if v, ok := cur.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// renderer renders the nodes of a template. The errors are
+// raised with panic and recovered by Template.Render.
+type renderer struct {
+	env   *Environment
+	name  string
+	out   *strings.Builder
+	root  *scope
+	line  int
+	depth int
+	// Depth of the macro calls shared with the included templates.
+	calls *int
+}
+
+func (r *renderer) fail(format string, args ...interface{}) {
+	panic(&Error{Name: r.name, Line: r.line, Msg: fmt.Sprintf(format, args...)})
+}
+
+// checkDefined fails with the undefined values in strict mode.
+func (r *renderer) checkDefined(v interface{}) {
+	if u, ok := v.(*Undefined); ok && r.env.Strict {
+		r.fail("%s", u.Error())
+	}
+}
+
+// mustDefined fails with the undefined values.
+func (r *renderer) mustDefined(v interface{}) {
+	if u, ok := v.(*Undefined); ok {
+		r.fail("%s", u.Error())
+	}
+}
+
+func (r *renderer) truth(v interface{}) bool {
+	r.checkDefined(v)
+	return truth(v)
+}
+
+func (r *renderer) renderNodes(nodes []node, s *scope) {
+	for _, n := range nodes {
+		r.renderNode(n, s)
+	}
+}
+
+// capture renders the nodes and returns the output.
+func (r *renderer) capture(nodes []node, s *scope) string {
+	var buf strings.Builder
+	out, line := r.out, r.line
+	r.out = &buf
+	r.renderNodes(nodes, s)
+	r.out, r.line = out, line
+	return buf.String()
+}
+
+func (r *renderer) renderNode(n node, s *scope) {
+	switch t := n.(type) {
+	case *textNode:
+		r.out.WriteString(t.text)
+
+	case *outputNode:
+		r.line = t.line
+		v := r.eval(t.expr, s)
+		r.checkDefined(v)
+		r.out.WriteString(toString(v))
+
+	case *ifNode:
+		for i, c := range t.conds {
+			r.line = t.line
+			if r.truth(r.eval(c, s)) {
+				r.renderNodes(t.bodies[i], s)
+				return
+			}
+		}
+		r.renderNodes(t.elseBody, s)
+
+	case *forNode:
+		r.renderFor(t, s)
+
+	case *setNode:
+		r.line = t.line
+		var v interface{}
+		if t.expr == nil {
+			v = r.capture(t.body, s)
+		} else {
+			v = r.eval(t.expr, s)
+		}
+
+		if t.attr != "" {
+			target, _ := s.lookup(t.targets[0])
+			ns, ok := target.(Namespace)
+			if !ok {
+				r.fail("cannot assign attribute on non-namespace object")
+			}
+			// The namespaces are the only mutable values: a value
+			// that contains the namespace creates a cycle.
+			if containsNamespace(v, ns) {
+				r.fail("cannot assign to a namespace a value that contains it")
+			}
+			ns[t.attr] = v
+			return
+		}
+		r.assign(s, t.targets, v)
+
+	case *macroNode:
+		s.vars[t.name] = r.macro(t, s)
+
+	case *includeNode:
+		r.renderInclude(t, s)
+
+	case *filterBlockNode:
+		r.line = t.line
+		content := r.capture(t.body, s)
+		r.line = t.line
+		r.out.WriteString(toString(r.applyFilter(t.filter, content, s)))
+	}
+}
+
+func (r *renderer) renderFor(n *forNode, s *scope) {
+	r.line = n.line
+	items := r.iterate(r.eval(n.iter, s))
+
+	if n.filter != nil {
+		filtered := []interface{}{}
+		for _, item := range items {
+			ls := newScope(s)
+			r.assign(ls, n.targets, item)
+			if r.truth(r.eval(n.filter, ls)) {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+
+	if len(items) == 0 {
+		r.renderNodes(n.elseBody, s)
+		return
+	}
+
+	for i, item := range items {
+		ls := newScope(s)
+		r.assign(ls, n.targets, item)
+
+		loop := map[string]interface{}{
+			"index":     i + 1,
+			"index0":    i,
+			"revindex":  len(items) - i,
+			"revindex0": len(items) - i - 1,
+			"first":     i == 0,
+			"last":      i == len(items)-1,
+			"length":    len(items),
+			"previtem":  &Undefined{Name: "previtem"},
+			"nextitem":  &Undefined{Name: "nextitem"},
+			"cycle": Function(func(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+				if len(args) == 0 {
+					return nil, fmt.Errorf("no items for cycling given")
+				}
+				return args[i%len(args)], nil
+			}),
+		}
+		if i > 0 {
+			loop["previtem"] = items[i-1]
+		}
+		if i < len(items)-1 {
+			loop["nextitem"] = items[i+1]
+		}
+		ls.vars["loop"] = loop
+
+		r.renderNodes(n.body, ls)
+	}
+}
+
+func (r *renderer) renderInclude(n *includeNode, s *scope) {
+	r.line = n.line
+	v := r.eval(n.template, s)
+	r.mustDefined(v)
+
+	names := []string{}
+	if isSequence(v) {
+		for _, i := range toList(v) {
+			names = append(names, toString(i))
+		}
+	} else {
+		names = append(names, toString(v))
+	}
+
+	if r.env.Loader == nil {
+		r.fail("no loader available for include %s", strings.Join(names, ", "))
+	}
+	if r.depth >= maxIncludeDepth {
+		r.fail("too many nested includes")
+	}
+
+	var source, name string
+	var err error
+	for _, name = range names {
+		source, err = r.env.Loader(name)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		if n.ignoreMissing {
+			return
+		}
+		r.fail("error on load template %s: %s", strings.Join(names, ", "), err.Error())
+	}
+
+	body, err := parse(name, source)
+	if err != nil {
+		panic(err)
+	}
+
+	sub := &renderer{
+		env:   r.env,
+		name:  name,
+		out:   r.out,
+		root:  r.root,
+		depth: r.depth + 1,
+		calls: r.calls,
+	}
+	sub.renderNodes(body, s)
+}
+
+func (r *renderer) macro(n *macroNode, defScope *scope) Function {
+	return func(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+		if len(args) > len(n.params) {
+			return nil, fmt.Errorf("macro %s takes not more than %d arguments",
+				n.name, len(n.params))
+		}
+
+		ms := newScope(defScope)
+		for i, p := range n.params {
+			if i < len(args) {
+				ms.vars[p] = args[i]
+			} else if v, ok := kwargs[p]; ok {
+				ms.vars[p] = v
+			} else if n.defaults[i] != nil {
+				ms.vars[p] = r.eval(n.defaults[i], ms)
+			} else {
+				ms.vars[p] = &Undefined{Name: p}
+			}
+		}
+		for k := range kwargs {
+			if _, ok := ms.vars[k]; !ok {
+				return nil, fmt.Errorf("macro %s takes no keyword argument %s", n.name, k)
+			}
+		}
+
+		if *r.calls >= maxCallDepth {
+			return nil, fmt.Errorf("maximum recursion depth exceeded in macro %s", n.name)
+		}
+		*r.calls++
+		defer func() { *r.calls-- }()
+
+		return r.capture(n.body, ms), nil
+	}
+}
+
+// iterate returns the items of an iterable value. The mappings
+// are iterated on the sorted keys.
+func (r *renderer) iterate(v interface{}) []interface{} {
+	switch {
+	case IsUndefined(v):
+		r.checkDefined(v)
+		return []interface{}{}
+	case isString(v):
+		ans := []interface{}{}
+		for _, c := range toString(v) {
+			ans = append(ans, string(c))
+		}
+		return ans
+	case isSequence(v):
+		return toList(v)
+	case isMapping(v):
+		return mapKeys(v)
+	}
+	r.fail("'%s' object is not iterable", typeName(v))
+	return nil
+}
+
+func (r *renderer) assign(s *scope, targets []string, v interface{}) {
+	if len(targets) == 1 {
+		s.vars[targets[0]] = v
+		return
+	}
+
+	r.mustDefined(v)
+	if !isSequence(v) {
+		r.fail("cannot unpack non-iterable %s object", typeName(v))
+	}
+	items := toList(v)
+	if len(items) != len(targets) {
+		r.fail("expected %d values to unpack, got %d", len(targets), len(items))
+	}
+	for i, t := range targets {
+		s.vars[t] = items[i]
+	}
+}
+
+func (r *renderer) eval(e expr, s *scope) interface{} {
+	switch t := e.(type) {
+	case *literalExpr:
+		return t.value
+
+	case *nameExpr:
+		if v, ok := s.lookup(t.name); ok {
+			return v
+		}
+		return &Undefined{Name: t.name}
+
+	case *listExpr:
+		ans := make([]interface{}, len(t.items))
+		for i, item := range t.items {
+			ans[i] = r.eval(item, s)
+		}
+		return ans
+
+	case *dictExpr:
+		ans := make(map[string]interface{}, len(t.keys))
+		for i := range t.keys {
+			k := r.eval(t.keys[i], s)
+			r.mustDefined(k)
+			ans[toString(k)] = r.eval(t.values[i], s)
+		}
+		return ans
+
+	case *attrExpr:
+		return r.getAttr(r.eval(t.target, s), t.name)
+
+	case *indexExpr:
+		return r.getItem(r.eval(t.target, s), r.eval(t.index, s))
+
+	case *sliceExpr:
+		return r.slice(t, s)
+
+	case *callExpr:
+		return r.call(t, s)
+
+	case *filterExpr:
+		return r.applyFilter(t, r.eval(t.target, s), s)
+
+	case *testExpr:
+		fn, ok := r.env.Tests[t.name]
+		if !ok {
+			r.fail("no test named '%s'", t.name)
+		}
+		args := make([]interface{}, len(t.args))
+		for i, a := range t.args {
+			args[i] = r.eval(a, s)
+		}
+		ans, err := fn(r.eval(t.target, s), args)
+		if err != nil {
+			r.fail("%s", err.Error())
+		}
+		return ans != t.negate
+
+	case *unaryExpr:
+		v := r.eval(t.operand, s)
+		if t.op == "not" {
+			return !r.truth(v)
+		}
+		r.mustDefined(v)
+		if !isNumber(v) {
+			r.fail("bad operand type for unary %s: '%s'", t.op, typeName(v))
+		}
+		if t.op == "+" {
+			return v
+		}
+		if isFloat(v) {
+			f, _ := toFloat(v)
+			return -f
+		}
+		i, _ := toInt(v)
+		if i == math.MinInt {
+			r.fail("integer overflow")
+		}
+		return -i
+
+	case *binaryExpr:
+		left := r.eval(t.left, s)
+		switch t.op {
+		case "and":
+			if !r.truth(left) {
+				return left
+			}
+			return r.eval(t.right, s)
+		case "or":
+			if r.truth(left) {
+				return left
+			}
+			return r.eval(t.right, s)
+		}
+		return r.binary(t.op, left, r.eval(t.right, s))
+
+	case *compareExpr:
+		left := r.eval(t.operands[0], s)
+		for i, op := range t.ops {
+			right := r.eval(t.operands[i+1], s)
+			if !r.binary(op, left, right).(bool) {
+				return false
+			}
+			left = right
+		}
+		return true
+
+	case *condExpr:
+		if r.truth(r.eval(t.cond, s)) {
+			return r.eval(t.then, s)
+		}
+		if t.els == nil {
+			return &Undefined{Name: "else"}
+		}
+		return r.eval(t.els, s)
+	}
+
+	r.fail("invalid expression")
+	return nil
+}
+
+func (r *renderer) getAttr(v interface{}, name string) interface{} {
+	r.mustDefined(v)
+
+	switch {
+	case v == nil:
+		r.fail("'None' has no attribute '%s'", name)
+	case isMapping(v):
+		if ans, ok := mapGet(v, name); ok {
+			return ans
+		}
+	case isSequence(v):
+		if i, err := parseIndex(name); err == nil {
+			return r.getItem(v, i)
+		}
+	default:
+		if ans, ok := structField(v, name); ok {
+			return ans
+		}
+	}
+
+	if m, ok := method(v, name); ok {
+		return m
+	}
+
+	return &Undefined{
+		Name: name,
+		Msg:  fmt.Sprintf("'%s object' has no attribute '%s'", typeName(v), name),
+	}
+}
+
+func parseIndex(s string) (int, error) {
+	var i int
+	_, err := fmt.Sscanf(s, "%d", &i)
+	return i, err
+}
+
+func (r *renderer) getItem(v, key interface{}) interface{} {
+	r.mustDefined(v)
+	r.mustDefined(key)
+
+	switch {
+	case isMapping(v):
+		if ans, ok := mapGet(v, key); ok {
+			return ans
+		}
+		return &Undefined{
+			Name: toString(key),
+			Msg:  fmt.Sprintf("'%s object' has no attribute '%s'", typeName(v), toString(key)),
+		}
+
+	case isSequence(v) || isString(v):
+		i, ok := toInt(key)
+		if !ok || isFloat(key) {
+			if isString(key) {
+				return r.getAttr(v, toString(key))
+			}
+			r.fail("indices must be integers, not %s", typeName(key))
+		}
+
+		var items []interface{}
+		if isString(v) {
+			items = r.iterate(v)
+		} else {
+			items = toList(v)
+		}
+		if i < 0 {
+			i += len(items)
+		}
+		if i < 0 || i >= len(items) {
+			return &Undefined{
+				Name: toString(key),
+				Msg:  fmt.Sprintf("%s index out of range", typeName(v)),
+			}
+		}
+		return items[i]
+	}
+
+	if isString(key) {
+		return r.getAttr(v, toString(key))
+	}
+
+	r.fail("'%s' object is not subscriptable", typeName(v))
+	return nil
+}
+
+func (r *renderer) slice(t *sliceExpr, s *scope) interface{} {
+	v := r.eval(t.target, s)
+	r.mustDefined(v)
+
+	var items []interface{}
+	switch {
+	case isString(v):
+		items = r.iterate(v)
+	case isSequence(v):
+		items = toList(v)
+	default:
+		r.fail("'%s' object is not subscriptable", typeName(v))
+	}
+
+	bound := func(e expr, def int) int {
+		if e == nil {
+			return def
+		}
+		b := r.eval(e, s)
+		if b == nil {
+			return def
+		}
+		i, ok := toInt(b)
+		if !ok {
+			r.fail("slice indices must be integers")
+		}
+		return i
+	}
+
+	n := len(items)
+	step := bound(t.step, 1)
+	if step == 0 {
+		r.fail("slice step cannot be zero")
+	}
+
+	var start, stop int
+	if step > 0 {
+		start = clampIndex(bound(t.start, 0), n, 0, n)
+		stop = clampIndex(bound(t.stop, n), n, 0, n)
+	} else {
+		start = clampIndex(bound(t.start, n-1), n, -1, n-1)
+		stop = clampIndex(bound(t.stop, -n-1), n, -1, n-1)
+	}
+
+	ans := []interface{}{}
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		ans = append(ans, items[i])
+	}
+
+	if isString(v) {
+		var sb strings.Builder
+		for _, c := range ans {
+			sb.WriteString(c.(string))
+		}
+		return sb.String()
+	}
+	return ans
+}
+
+func clampIndex(i, n, min, max int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < min {
+		return min
+	}
+	if i > max {
+		return max
+	}
+	return i
+}
+
+func (r *renderer) evalArgs(args []expr, kwargs []kwarg, s *scope) ([]interface{}, map[string]interface{}) {
+	a := make([]interface{}, len(args))
+	for i, arg := range args {
+		a[i] = r.eval(arg, s)
+	}
+	kw := make(map[string]interface{}, len(kwargs))
+	for _, k := range kwargs {
+		kw[k.name] = r.eval(k.value, s)
+	}
+	return a, kw
+}
+
+func (r *renderer) call(t *callExpr, s *scope) interface{} {
+	fn := r.eval(t.fn, s)
+	r.mustDefined(fn)
+
+	f, ok := fn.(Function)
+	if !ok {
+		r.fail("'%s' object is not callable", typeName(fn))
+	}
+
+	args, kwargs := r.evalArgs(t.args, t.kwargs, s)
+	line := r.line
+	ans, err := f(args, kwargs)
+	r.line = line
+	if err != nil {
+		r.fail("%s", err.Error())
+	}
+	return ans
+}
+
+func (r *renderer) applyFilter(t *filterExpr, v interface{}, s *scope) interface{} {
+	fn, ok := r.env.Filters[t.name]
+	if !ok {
+		r.fail("no filter named '%s'", t.name)
+	}
+
+	switch t.name {
+	case "default", "d", "mandatory":
+		// POST: the filters that manage the undefined values.
+	default:
+		r.checkDefined(v)
+	}
+
+	args, kwargs := r.evalArgs(t.args, t.kwargs, s)
+	ans, err := fn(v, args, kwargs)
+	if err != nil {
+		r.fail("%s", err.Error())
+	}
+	return ans
+}
+
+func (r *renderer) binary(op string, a, b interface{}) interface{} {
+	switch op {
+	case "==", "!=":
+		r.checkDefined(a)
+		r.checkDefined(b)
+		return equals(a, b) == (op == "==")
+
+	case "~":
+		r.checkDefined(a)
+		r.checkDefined(b)
+		return toString(a) + toString(b)
+
+	case "in", "not in":
+		r.mustDefined(a)
+		r.mustDefined(b)
+		ans, err := contains(b, a)
+		if err != nil {
+			r.fail("%s", err.Error())
+		}
+		return ans == (op == "in")
+
+	case "<", "<=", ">", ">=":
+		r.mustDefined(a)
+		r.mustDefined(b)
+		c, err := compare(a, b)
+		if err != nil {
+			r.fail("%s", strings.Replace(err.Error(), "'<'", "'"+op+"'", 1))
+		}
+		switch op {
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		}
+		return c >= 0
+	}
+
+	r.mustDefined(a)
+	r.mustDefined(b)
+
+	switch {
+	case op == "+" && isString(a) && isString(b):
+		return toString(a) + toString(b)
+	case op == "+" && isSequence(a) && isSequence(b):
+		return append(append([]interface{}{}, toList(a)...), toList(b)...)
+	case op == "*" && isString(a) && isInt(b):
+		n, _ := toInt(b)
+		ans, err := repeatString(toString(a), n)
+		if err != nil {
+			r.fail("%s", err.Error())
+		}
+		return ans
+	case op == "*" && isSequence(a) && isInt(b):
+		n, _ := toInt(b)
+		items := toList(a)
+		if n > 0 && len(items) > 0 && n > maxRangeSize/len(items) {
+			r.fail("sequence too large, max %d items", maxRangeSize)
+		}
+		ans := []interface{}{}
+		for i := 0; i < n; i++ {
+			ans = append(ans, items...)
+		}
+		return ans
+	case op == "%" && isString(a):
+		ans, err := pyFormat(toString(a), b)
+		if err != nil {
+			r.fail("%s", err.Error())
+		}
+		return ans
+	}
+
+	if !isNumber(a) || !isNumber(b) {
+		r.fail("unsupported operand type(s) for %s: '%s' and '%s'",
+			op, typeName(a), typeName(b))
+	}
+
+	if isInt(a) && isInt(b) && op != "/" {
+		x, _ := toInt(a)
+		y, _ := toInt(b)
+		switch op {
+		case "+", "-", "*":
+			ans, ok := intOp(op, x, y)
+			if !ok {
+				r.fail("integer overflow")
+			}
+			return ans
+		case "//", "%":
+			if y == 0 {
+				r.fail("integer division or modulo by zero")
+			}
+			if x == math.MinInt && y == -1 {
+				r.fail("integer overflow")
+			}
+			q := x / y
+			if (x%y != 0) && ((x < 0) != (y < 0)) {
+				q--
+			}
+			if op == "//" {
+				return q
+			}
+			return x - q*y
+		case "**":
+			if y >= 0 {
+				ans, ok := intPow(x, y)
+				if !ok {
+					r.fail("integer overflow")
+				}
+				return ans
+			}
+		}
+	}
+
+	x, _ := toFloat(a)
+	y, _ := toFloat(b)
+	switch op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/", "//", "%":
+		if y == 0 {
+			r.fail("division by zero")
+		}
+		switch op {
+		case "/":
+			return x / y
+		case "//":
+			return math.Floor(x / y)
+		}
+		return x - math.Floor(x/y)*y
+	case "**":
+		return math.Pow(x, y)
+	}
+
+	r.fail("invalid operator %s", op)
+	return nil
+}
+
+// intOp applies the +, - and * operators to two integers. It returns
+// false on the overflow of int.
+func intOp(op string, x, y int) (int, bool) {
+	switch op {
+	case "+":
+		ans := x + y
+		return ans, (ans > x) == (y > 0)
+	case "-":
+		ans := x - y
+		return ans, (ans < x) == (y > 0)
+	case "*":
+		if x == 0 || y == 0 {
+			return 0, true
+		}
+		ans := x * y
+		if ans/y != x || (x == -1 && y == math.MinInt) || (y == -1 && x == math.MinInt) {
+			return 0, false
+		}
+		return ans, true
+	}
+	return 0, false
+}
+
+// intPow returns x**y with the exponentiation by squaring. It returns
+// false on the overflow of int.
+func intPow(x, y int) (int, bool) {
+	ans := 1
+	for y > 0 {
+		var ok bool
+		if y&1 == 1 {
+			if ans, ok = intOp("*", ans, x); !ok {
+				return 0, false
+			}
+		}
+		y >>= 1
+		if y > 0 {
+			if x, ok = intOp("*", x, x); !ok {
+				return 0, false
+			}
+		}
+	}
+	return ans, true
+}
+
+// repeatString returns the string repeated n times. It fails
+// if the result is bigger than maxStringSize.
+func repeatString(s string, n int) (string, error) {
+	if n <= 0 || s == "" {
+		return "", nil
+	}
+	if n > maxStringSize/len(s) {
+		return "", fmt.Errorf("string too large, max %d bytes", maxStringSize)
+	}
+	return strings.Repeat(s, n), nil
+}
+
+// pyFormat implements the printf-style formatting of the strings.
+func pyFormat(format string, v interface{}) (string, error) {
+	args := []interface{}{v}
+	if isSequence(v) {
+		args = toList(v)
+	}
+
+	var sb strings.Builder
+	argIdx := 0
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			sb.WriteByte(c)
+			continue
+		}
+
+		j := i + 1
+		for j < len(format) && strings.IndexByte("-+ 0#.0123456789", format[j]) >= 0 {
+			j++
+		}
+		if j >= len(format) {
+			return "", fmt.Errorf("incomplete format")
+		}
+		spec := format[i+1 : j]
+		conv := format[j]
+		i = j
+
+		for _, w := range strings.FieldsFunc(spec, func(c rune) bool {
+			return c < '0' || c > '9'
+		}) {
+			if n, err := strconv.Atoi(w); err != nil || n > maxFilterWidth {
+				return "", fmt.Errorf("format width or precision too large, max %d", maxFilterWidth)
+			}
+		}
+
+		if conv == '%' {
+			sb.WriteByte('%')
+			continue
+		}
+		if argIdx >= len(args) {
+			return "", fmt.Errorf("not enough arguments for format string")
+		}
+		arg := args[argIdx]
+		argIdx++
+
+		switch conv {
+		case 's':
+			sb.WriteString(fmt.Sprintf("%"+spec+"s", toString(arg)))
+		case 'r':
+			sb.WriteString(fmt.Sprintf("%"+spec+"s", repr(arg)))
+		case 'd', 'i':
+			f, ok := toFloat(arg)
+			if !ok {
+				return "", fmt.Errorf("%%%c format: a number is required, not %s",
+					conv, typeName(arg))
+			}
+			sb.WriteString(fmt.Sprintf("%"+spec+"d", int(f)))
+		case 'f', 'F', 'e', 'E', 'g', 'G':
+			f, ok := toFloat(arg)
+			if !ok {
+				return "", fmt.Errorf("%%%c format: a number is required, not %s",
+					conv, typeName(arg))
+			}
+			sb.WriteString(fmt.Sprintf("%"+spec+string(conv), f))
+		case 'x', 'X', 'o':
+			n, ok := toInt(arg)
+			if !ok {
+				return "", fmt.Errorf("%%%c format: an integer is required, not %s",
+					conv, typeName(arg))
+			}
+			sb.WriteString(fmt.Sprintf("%"+spec+string(conv), n))
+		default:
+			return "", fmt.Errorf("unsupported format character '%c'", conv)
+		}
+	}
+
+	if argIdx < len(args) {
+		return "", fmt.Errorf("not all arguments converted during string formatting")
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,1271 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package jinja
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/MottainaiCI/ssh-compose/pkg/helpers"
+
+	"gopkg.in/yaml.v3"
+)
+
+var builtinFilters = map[string]FilterFunc{
+	"default":       filterDefault,
+	"d":             filterDefault,
+	"mandatory":     filterMandatory,
+	"to_yaml":       filterToYaml,
+	"to_nice_yaml":  filterToNiceYaml,
+	"to_json":       filterToJson,
+	"tojson":        filterToJson,
+	"to_nice_json":  filterToNiceJson,
+	"from_json":     filterFromJson,
+	"from_yaml":     filterFromYaml,
+	"upper":         stringFilter(strings.ToUpper),
+	"lower":         stringFilter(strings.ToLower),
+	"capitalize":    stringFilter(capitalize),
+	"title":         stringFilter(title),
+	"trim":          filterTrim,
+	"replace":       filterReplace,
+	"length":        filterLength,
+	"count":         filterLength,
+	"join":          filterJoin,
+	"first":         filterFirst,
+	"last":          filterLast,
+	"list":          filterList,
+	"sort":          filterSort,
+	"reverse":       filterReverse,
+	"unique":        filterUnique,
+	"min":           filterMin,
+	"max":           filterMax,
+	"sum":           filterSum,
+	"string":        filterString,
+	"int":           filterInt,
+	"float":         filterFloat,
+	"bool":          filterBool,
+	"abs":           filterAbs,
+	"round":         filterRound,
+	"indent":        filterIndent,
+	"format":        filterFormat,
+	"quote":         filterQuote,
+	"b64encode":     filterB64Encode,
+	"b64decode":     filterB64Decode,
+	"dictsort":      filterDictSort,
+	"dict2items":    filterDict2Items,
+	"items2dict":    filterItems2Dict,
+	"combine":       filterCombine,
+	"ternary":       filterTernary,
+	"regex_replace": filterRegexReplace,
+	"regex_search":  filterRegexSearch,
+	"basename":      stringFilter(path.Base),
+	"dirname":       stringFilter(path.Dir),
+	"split":         filterSplit,
+	"truncate":      filterTruncate,
+	"center":        filterCenter,
+	"wordwrap":      filterWordwrap,
+	"batch":         filterBatch,
+	"slice":         filterSlice,
+	"random":        filterRandom,
+}
+
+func init() {
+	// The filters that use the other filters and the tests.
+	builtinFilters["map"] = filterMap
+	builtinFilters["select"] = selectFilter(false, false)
+	builtinFilters["reject"] = selectFilter(true, false)
+	builtinFilters["selectattr"] = selectFilter(false, true)
+	builtinFilters["rejectattr"] = selectFilter(true, true)
+}
+
+// arg returns the positional or the keyword argument of a filter.
+func arg(args []interface{}, kwargs map[string]interface{}, idx int, name string,
+	def interface{}) interface{} {
+	if idx >= 0 && idx < len(args) {
+		return args[idx]
+	}
+	if v, ok := kwargs[name]; ok {
+		return v
+	}
+	return def
+}
+
+// intArg returns the integer argument of a filter clamped between
+// min and max. The arguments that are not integers are replaced
+// by the default.
+func intArg(v interface{}, def, min, max int) int {
+	i, ok := toInt(v)
+	if !ok {
+		i = def
+	}
+	if i < min {
+		return min
+	}
+	if i > max {
+		return max
+	}
+	return i
+}
+
+func stringFilter(fn func(string) string) FilterFunc {
+	return func(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+		return fn(toString(v)), nil
+	}
+}
+
+func filterDefault(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	def := arg(args, kwargs, 0, "default_value", "")
+	boolean := truth(arg(args, kwargs, 1, "boolean", false))
+
+	if IsUndefined(v) || (boolean && !truth(v)) {
+		return def, nil
+	}
+	return v, nil
+}
+
+func filterMandatory(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if u, ok := v.(*Undefined); ok {
+		msg := arg(args, kwargs, 0, "msg", nil)
+		if msg != nil {
+			return nil, fmt.Errorf("%s", toString(msg))
+		}
+		return nil, fmt.Errorf("mandatory variable %s not defined", u.Name)
+	}
+	return v, nil
+}
+
+func toYaml(v interface{}, indent int) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(indent)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func filterToYaml(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	indent := intArg(arg(nil, kwargs, -1, "indent", 2), 2, 0, maxFilterWidth)
+	return toYaml(v, indent)
+}
+
+func filterToNiceYaml(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	indent := intArg(arg(nil, kwargs, -1, "indent", 4), 4, 0, maxFilterWidth)
+	return toYaml(v, indent)
+}
+
+// toJson encodes the value as the json module of Python with
+// the keys sorted.
+func toJson(v interface{}, indent int, level int, sb *strings.Builder) error {
+	newline := func(l int) {
+		if indent > 0 {
+			sb.WriteString("\n" + strings.Repeat(" ", indent*l))
+		}
+	}
+	sep := ", "
+	if indent > 0 {
+		sep = ","
+	}
+
+	switch {
+	case v == nil:
+		sb.WriteString("null")
+	case IsUndefined(v):
+		return fmt.Errorf("%s", v.(*Undefined).Error())
+	case isString(v):
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(toString(v)); err != nil {
+			return err
+		}
+		sb.WriteString(strings.TrimSuffix(buf.String(), "\n"))
+	case isNumber(v):
+		f, _ := toFloat(v)
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return fmt.Errorf("out of range float values are not JSON compliant")
+		}
+		sb.WriteString(toString(v))
+	case isSequence(v):
+		items := toList(v)
+		if len(items) == 0 {
+			sb.WriteString("[]")
+			return nil
+		}
+		sb.WriteString("[")
+		for i, item := range items {
+			if i > 0 {
+				sb.WriteString(sep)
+			}
+			newline(level + 1)
+			if err := toJson(item, indent, level+1, sb); err != nil {
+				return err
+			}
+		}
+		newline(level)
+		sb.WriteString("]")
+	case isMapping(v):
+		keys := mapKeys(v)
+		if len(keys) == 0 {
+			sb.WriteString("{}")
+			return nil
+		}
+		sb.WriteString("{")
+		for i, k := range keys {
+			if i > 0 {
+				sb.WriteString(sep)
+			}
+			newline(level + 1)
+			if err := toJson(toString(k), 0, 0, sb); err != nil {
+				return err
+			}
+			sb.WriteString(": ")
+			item, _ := mapGet(v, k)
+			if err := toJson(item, indent, level+1, sb); err != nil {
+				return err
+			}
+		}
+		newline(level)
+		sb.WriteString("}")
+	default:
+		if b, ok := v.(bool); ok {
+			if b {
+				sb.WriteString("true")
+			} else {
+				sb.WriteString("false")
+			}
+			return nil
+		}
+		// Structs and the other types are encoded with their
+		// json tags.
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var generic interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return err
+		}
+		return toJson(generic, indent, level, sb)
+	}
+
+	return nil
+}
+
+func filterToJson(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	indent := intArg(arg(nil, kwargs, -1, "indent", 0), 0, 0, maxFilterWidth)
+	var sb strings.Builder
+	if err := toJson(v, indent, 0, &sb); err != nil {
+		return nil, err
+	}
+	return sb.String(), nil
+}
+
+func filterToNiceJson(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	indent := intArg(arg(nil, kwargs, -1, "indent", 4), 4, 0, maxFilterWidth)
+	var sb strings.Builder
+	if err := toJson(v, indent, 0, &sb); err != nil {
+		return nil, err
+	}
+	return sb.String(), nil
+}
+
+// fromJsonNumbers converts the json numbers to int or float64.
+func fromJsonNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := strconv.Atoi(t.String()); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case []interface{}:
+		for i := range t {
+			t[i] = fromJsonNumbers(t[i])
+		}
+	case map[string]interface{}:
+		for k := range t {
+			t[k] = fromJsonNumbers(t[k])
+		}
+	}
+	return v
+}
+
+func filterFromJson(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	var ans interface{}
+	dec := json.NewDecoder(strings.NewReader(toString(v)))
+	dec.UseNumber()
+	if err := dec.Decode(&ans); err != nil {
+		return nil, fmt.Errorf("error on parse json: %s", err.Error())
+	}
+	return fromJsonNumbers(ans), nil
+}
+
+func filterFromYaml(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	var ans interface{}
+	if err := yaml.Unmarshal([]byte(toString(v)), &ans); err != nil {
+		return nil, fmt.Errorf("error on parse yaml: %s", err.Error())
+	}
+	return ans, nil
+}
+
+func filterTrim(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	chars := arg(args, kwargs, 0, "chars", nil)
+	return trim(toString(v), "strip", []interface{}{chars}), nil
+}
+
+func filterReplace(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("replace expected at least 2 arguments")
+	}
+	n := -1
+	if c := arg(args, kwargs, 2, "count", nil); c != nil {
+		n, _ = toInt(c)
+	}
+	return strings.Replace(toString(v), toString(args[0]), toString(args[1]), n), nil
+}
+
+func filterLength(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if IsUndefined(v) {
+		return 0, nil
+	}
+	return length(v)
+}
+
+// iterable returns the items of a value used by the filters
+// on the collections.
+func iterable(v interface{}) ([]interface{}, error) {
+	switch {
+	case IsUndefined(v):
+		return []interface{}{}, nil
+	case isString(v):
+		ans := []interface{}{}
+		for _, c := range toString(v) {
+			ans = append(ans, string(c))
+		}
+		return ans, nil
+	case isSequence(v):
+		return toList(v), nil
+	case isMapping(v):
+		return mapKeys(v), nil
+	}
+	return nil, fmt.Errorf("'%s' object is not iterable", typeName(v))
+}
+
+// attribute returns the attribute of an item. The attribute supports
+// the dotted notation (ex. address.city).
+func attribute(item interface{}, attr string) interface{} {
+	for _, a := range strings.Split(attr, ".") {
+		if isMapping(item) {
+			v, ok := mapGet(item, a)
+			if !ok {
+				return &Undefined{Name: a}
+			}
+			item = v
+		} else if isSequence(item) {
+			i, err := parseIndex(a)
+			l := toList(item)
+			if err != nil || i < 0 || i >= len(l) {
+				return &Undefined{Name: a}
+			}
+			item = l[i]
+		} else {
+			v, ok := structField(item, a)
+			if !ok {
+				return &Undefined{Name: a}
+			}
+			item = v
+		}
+	}
+	return item
+}
+
+func filterJoin(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	items, err := iterable(v)
+	if err != nil {
+		return nil, err
+	}
+	sep := toString(arg(args, kwargs, 0, "d", ""))
+	attr := arg(args, kwargs, 1, "attribute", nil)
+
+	parts := make([]string, len(items))
+	for i, item := range items {
+		if attr != nil {
+			item = attribute(item, toString(attr))
+		}
+		parts[i] = toString(item)
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func filterFirst(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	items, err := iterable(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return &Undefined{Name: "first", Msg: "No first item, sequence was empty."}, nil
+	}
+	return items[0], nil
+}
+
+func filterLast(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	items, err := iterable(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return &Undefined{Name: "last", Msg: "No last item, sequence was empty."}, nil
+	}
+	return items[len(items)-1], nil
+}
+
+func filterList(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	items, err := iterable(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]interface{}{}, items...), nil
+}
+
+// sortKey returns the value used to sort an item.
+func sortKey(item interface{}, attr interface{}, caseSensitive bool) interface{} {
+	if attr != nil {
+		item = attribute(item, toString(attr))
+	}
+	if !caseSensitive && isString(item) {
+		return strings.ToLower(toString(item))
+	}
+	return item
+}
+
+func filterSort(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	items, err := iterable(v)
+	if err != nil {
+		return nil, err
+	}
+	reverse := truth(arg(args, kwargs, 0, "reverse", false))
+	caseSensitive := truth(arg(args, kwargs, 1, "case_sensitive", false))
+	attr := arg(args, kwargs, 2, "attribute", nil)
+
+	ans := append([]interface{}{}, items...)
+	var sortErr error
+	sort.SliceStable(ans, func(i, j int) bool {
+		a := sortKey(ans[i], attr, caseSensitive)
+		b := sortKey(ans[j], attr, caseSensitive)
+		if reverse {
+			a, b = b, a
+		}
+		c, err := compare(a, b)
+		if err != nil && sortErr == nil {
+			sortErr = err
+		}
+		return c < 0
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	return ans, nil
+}
+
+func filterReverse(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if isString(v) {
+		r := []rune(toString(v))
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		return string(r), nil
+	}
+	items, err := iterable(v)
+	if err != nil {
+		return nil, err
+	}
+	ans := make([]interface{}, len(items))
+	for i := range items {
+		ans[len(items)-1-i] = items[i]
+	}
+	return ans, nil
+}
+
+func filterUnique(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	items, err := iterable(v)
+	if err != nil {
+		return nil, err
+	}
+	ans := []interface{}{}
+	for _, item := range items {
+		found := false
+		for _, a := range ans {
+			if equals(a, item) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ans = append(ans, item)
+		}
+	}
+	return ans, nil
+}
+
+func minMax(v interface{}, args []interface{}, kwargs map[string]interface{}, max bool) (interface{}, error) {
+	items, err := iterable(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return &Undefined{Name: "min", Msg: "No aggregated item, sequence was empty."}, nil
+	}
+	caseSensitive := truth(arg(args, kwargs, 0, "case_sensitive", false))
+	attr := arg(args, kwargs, 1, "attribute", nil)
+
+	ans := items[0]
+	for _, item := range items[1:] {
+		c, err := compare(sortKey(item, attr, caseSensitive), sortKey(ans, attr, caseSensitive))
+		if err != nil {
+			return nil, err
+		}
+		if (max && c > 0) || (!max && c < 0) {
+			ans = item
+		}
+	}
+	return ans, nil
+}
+
+func filterMin(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	return minMax(v, args, kwargs, false)
+}
+
+func filterMax(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	return minMax(v, args, kwargs, true)
+}
+
+func filterSum(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	items, err := iterable(v)
+	if err != nil {
+		return nil, err
+	}
+	attr := arg(args, kwargs, 0, "attribute", nil)
+	ans := arg(args, kwargs, 1, "start", 0)
+
+	for _, item := range items {
+		if attr != nil {
+			item = attribute(item, toString(attr))
+		}
+		if !isNumber(item) || !isNumber(ans) {
+			return nil, fmt.Errorf("unsupported operand type(s) for +: '%s' and '%s'",
+				typeName(ans), typeName(item))
+		}
+		if isInt(item) && isInt(ans) {
+			a, _ := toInt(ans)
+			b, _ := toInt(item)
+			sum, ok := intOp("+", a, b)
+			if !ok {
+				return nil, fmt.Errorf("integer overflow")
+			}
+			ans = sum
+		} else {
+			a, _ := toFloat(ans)
+			b, _ := toFloat(item)
+			ans = a + b
+		}
+	}
+	return ans, nil
+}
+
+func filterString(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	return toString(v), nil
+}
+
+func filterInt(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	def := arg(args, kwargs, 0, "default", 0)
+	base, _ := toInt(arg(args, kwargs, 1, "base", 10))
+
+	switch {
+	case isInt(v):
+		i, _ := toInt(v)
+		return i, nil
+	case isFloat(v):
+		f, _ := toFloat(v)
+		return int(f), nil
+	case isString(v):
+		s := strings.TrimSpace(toString(v))
+		if base == 16 {
+			s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+		}
+		if i, err := strconv.ParseInt(s, base, 64); err == nil {
+			return int(i), nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return int(f), nil
+		}
+	}
+	if b, ok := v.(bool); ok {
+		if b {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return def, nil
+}
+
+func filterFloat(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	def := arg(args, kwargs, 0, "default", 0.0)
+
+	if isNumber(v) {
+		f, _ := toFloat(v)
+		return f, nil
+	}
+	if isString(v) {
+		if f, err := strconv.ParseFloat(strings.TrimSpace(toString(v)), 64); err == nil {
+			return f, nil
+		}
+	}
+	return def, nil
+}
+
+func filterBool(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(toString(v))) {
+	case "yes", "on", "1", "true", "y", "t":
+		return true, nil
+	}
+	return false, nil
+}
+
+func filterAbs(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	switch {
+	case isInt(v):
+		i, _ := toInt(v)
+		if i == math.MinInt {
+			return nil, fmt.Errorf("integer overflow")
+		}
+		if i < 0 {
+			return -i, nil
+		}
+		return i, nil
+	case isFloat(v):
+		f, _ := toFloat(v)
+		return math.Abs(f), nil
+	}
+	return nil, fmt.Errorf("bad operand type for abs(): '%s'", typeName(v))
+}
+
+func filterRound(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	f, ok := toFloat(v)
+	if !ok {
+		return nil, fmt.Errorf("round expected a number, got %s", typeName(v))
+	}
+	precision := intArg(arg(args, kwargs, 0, "precision", 0), 0, -308, 308)
+	m := toString(arg(args, kwargs, 1, "method", "common"))
+
+	var fn func(float64) float64
+	switch m {
+	case "common":
+		fn = math.Round
+	case "ceil":
+		fn = math.Ceil
+	case "floor":
+		fn = math.Floor
+	default:
+		return nil, fmt.Errorf("method must be common, ceil or floor")
+	}
+
+	p := math.Pow(10, float64(precision))
+	ans := fn(f*p) / p
+	if math.IsInf(ans, 0) || math.IsNaN(ans) {
+		// POST: the precision is over the float range.
+		return f, nil
+	}
+	return ans, nil
+}
+
+func filterIndent(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	width := arg(args, kwargs, 0, "width", 4)
+	first := truth(arg(args, kwargs, 1, "first", false))
+	blank := truth(arg(args, kwargs, 2, "blank", false))
+
+	prefix := ""
+	if isString(width) {
+		prefix = toString(width)
+	} else {
+		prefix = strings.Repeat(" ", intArg(width, 4, 0, maxFilterWidth))
+	}
+
+	lines := strings.Split(toString(v), "\n")
+	size := 0
+	for i, l := range lines {
+		size += len(l) + 1
+		if i == 0 && !first {
+			continue
+		}
+		if l == "" && !blank {
+			continue
+		}
+		size += len(prefix)
+		if size > maxStringSize {
+			return nil, fmt.Errorf("string too large, max %d bytes", maxStringSize)
+		}
+		lines[i] = prefix + l
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func filterFormat(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	return pyFormat(toString(v), args)
+}
+
+func filterQuote(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	return helpers.ShellQuote(toString(v)), nil
+}
+
+func filterB64Encode(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	return base64.StdEncoding.EncodeToString([]byte(toString(v))), nil
+}
+
+func filterB64Decode(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	data, err := base64.StdEncoding.DecodeString(toString(v))
+	if err != nil {
+		return nil, fmt.Errorf("error on decode base64: %s", err.Error())
+	}
+	return string(data), nil
+}
+
+func filterDictSort(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if !isMapping(v) {
+		return nil, fmt.Errorf("dictsort expected a dict, got %s", typeName(v))
+	}
+	caseSensitive := truth(arg(args, kwargs, 0, "case_sensitive", false))
+	by := toString(arg(args, kwargs, 1, "by", "key"))
+	reverse := truth(arg(args, kwargs, 2, "reverse", false))
+
+	ans := []interface{}{}
+	for _, k := range mapKeys(v) {
+		val, _ := mapGet(v, k)
+		ans = append(ans, []interface{}{k, val})
+	}
+
+	idx := 0
+	if by == "value" {
+		idx = 1
+	}
+	sort.SliceStable(ans, func(i, j int) bool {
+		a := sortKey(ans[i].([]interface{})[idx], nil, caseSensitive)
+		b := sortKey(ans[j].([]interface{})[idx], nil, caseSensitive)
+		if reverse {
+			a, b = b, a
+		}
+		return lessValue(a, b)
+	})
+	return ans, nil
+}
+
+func filterDict2Items(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if !isMapping(v) {
+		return nil, fmt.Errorf("dict2items requires a dictionary, got %s", typeName(v))
+	}
+	keyName := toString(arg(args, kwargs, -1, "key_name", "key"))
+	valueName := toString(arg(args, kwargs, -1, "value_name", "value"))
+
+	ans := []interface{}{}
+	for _, k := range mapKeys(v) {
+		val, _ := mapGet(v, k)
+		ans = append(ans, map[string]interface{}{keyName: k, valueName: val})
+	}
+	return ans, nil
+}
+
+func filterItems2Dict(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	items, err := iterable(v)
+	if err != nil {
+		return nil, err
+	}
+	keyName := toString(arg(args, kwargs, -1, "key_name", "key"))
+	valueName := toString(arg(args, kwargs, -1, "value_name", "value"))
+
+	ans := map[string]interface{}{}
+	for _, item := range items {
+		k, ok := mapGet(item, keyName)
+		if !isMapping(item) || !ok {
+			return nil, fmt.Errorf("items2dict requires a list of dictionaries with the %s key", keyName)
+		}
+		val, _ := mapGet(item, valueName)
+		ans[toString(k)] = val
+	}
+	return ans, nil
+}
+
+// combine merges the dictionaries. With recursive the nested
+// dictionaries are merged too.
+func combine(a, b interface{}, recursive bool) map[string]interface{} {
+	ans := map[string]interface{}{}
+	for _, k := range mapKeys(a) {
+		v, _ := mapGet(a, k)
+		ans[toString(k)] = v
+	}
+	for _, k := range mapKeys(b) {
+		v, _ := mapGet(b, k)
+		key := toString(k)
+		if old, ok := ans[key]; ok && recursive && isMapping(old) && isMapping(v) {
+			ans[key] = combine(old, v, true)
+			continue
+		}
+		ans[key] = v
+	}
+	return ans
+}
+
+func filterCombine(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if !isMapping(v) {
+		return nil, fmt.Errorf("combine expects dictionaries, got %s", typeName(v))
+	}
+	recursive := truth(arg(nil, kwargs, -1, "recursive", false))
+
+	ans := combine(v, map[string]interface{}{}, false)
+	for _, a := range args {
+		if isSequence(a) {
+			for _, d := range toList(a) {
+				if !isMapping(d) {
+					return nil, fmt.Errorf("combine expects dictionaries, got %s", typeName(d))
+				}
+				ans = combine(ans, d, recursive)
+			}
+			continue
+		}
+		if !isMapping(a) {
+			return nil, fmt.Errorf("combine expects dictionaries, got %s", typeName(a))
+		}
+		ans = combine(ans, a, recursive)
+	}
+	return ans, nil
+}
+
+func filterTernary(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	trueVal := arg(args, kwargs, 0, "true_val", nil)
+	falseVal := arg(args, kwargs, 1, "false_val", nil)
+	if len(args) > 2 && v == nil {
+		return args[2], nil
+	}
+	if truth(v) {
+		return trueVal, nil
+	}
+	return falseVal, nil
+}
+
+func pyRegexp(pattern string, ignoreCase bool) (*regexp.Regexp, error) {
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// pyReplacement converts the Python back references (\1, \g<name>)
+// of a replacement to the Go syntax.
+func pyReplacement(s string) string {
+	re := regexp.MustCompile(`\\(\d+)|\\g<(\w+)>`)
+	s = strings.ReplaceAll(s, "$", "$$")
+	return re.ReplaceAllStringFunc(s, func(m string) string {
+		sub := re.FindStringSubmatch(m)
+		if sub[1] != "" {
+			return "${" + sub[1] + "}"
+		}
+		return "${" + sub[2] + "}"
+	})
+}
+
+func filterRegexReplace(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	pattern := toString(arg(args, kwargs, 0, "pattern", ""))
+	replacement := toString(arg(args, kwargs, 1, "replacement", ""))
+	ignoreCase := truth(arg(args, kwargs, 2, "ignorecase", false))
+
+	re, err := pyRegexp(pattern, ignoreCase)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %s: %s", pattern, err.Error())
+	}
+	return re.ReplaceAllString(toString(v), pyReplacement(replacement)), nil
+}
+
+func filterRegexSearch(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	pattern := toString(arg(args, kwargs, 0, "pattern", ""))
+	ignoreCase := truth(arg(nil, kwargs, -1, "ignorecase", false))
+
+	re, err := pyRegexp(pattern, ignoreCase)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %s: %s", pattern, err.Error())
+	}
+	m := re.FindStringSubmatch(toString(v))
+	if m == nil {
+		return nil, nil
+	}
+	if len(args) > 1 {
+		// Return the groups requested (\1 or \g<name>).
+		ans := []interface{}{}
+		for _, g := range args[1:] {
+			name := toString(g)
+			if strings.HasPrefix(name, "\\g<") {
+				idx := re.SubexpIndex(strings.TrimSuffix(strings.TrimPrefix(name, "\\g<"), ">"))
+				if idx >= 0 {
+					ans = append(ans, m[idx])
+				}
+			} else if i, err := strconv.Atoi(strings.TrimPrefix(name, "\\")); err == nil && i < len(m) {
+				ans = append(ans, m[i])
+			}
+		}
+		return ans, nil
+	}
+	return m[0], nil
+}
+
+func filterSplit(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	sep := arg(args, kwargs, 0, "sep", nil)
+	n := -1
+	if m := arg(args, kwargs, 1, "maxsplit", nil); m != nil {
+		if i, ok := toInt(m); ok && i >= 0 {
+			n = i + 1
+		}
+	}
+	return split(toString(v), []interface{}{sep}, n), nil
+}
+
+func filterMap(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	items, err := iterable(v)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]interface{}, len(items))
+
+	if attr, ok := kwargs["attribute"]; ok {
+		def, hasDefault := kwargs["default"]
+		for i, item := range items {
+			ans[i] = attribute(item, toString(attr))
+			if IsUndefined(ans[i]) && hasDefault {
+				ans[i] = def
+			}
+		}
+		return ans, nil
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("map requires a filter or an attribute")
+	}
+	name := toString(args[0])
+	fn, ok := builtinFilters[name]
+	if !ok {
+		return nil, fmt.Errorf("no filter named '%s'", name)
+	}
+	for i, item := range items {
+		ans[i], err = fn(item, args[1:], kwargs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ans, nil
+}
+
+// selectFilter returns the select, reject, selectattr and
+// rejectattr filters.
+func selectFilter(reject, byAttr bool) FilterFunc {
+	return func(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+		items, err := iterable(v)
+		if err != nil {
+			return nil, err
+		}
+
+		attr := ""
+		if byAttr {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("missing attribute")
+			}
+			attr = toString(args[0])
+			args = args[1:]
+		}
+
+		var test TestFunc
+		testArgs := []interface{}{}
+		if len(args) > 0 {
+			name := toString(args[0])
+			t, ok := builtinTests[name]
+			if !ok {
+				return nil, fmt.Errorf("no test named '%s'", name)
+			}
+			test = t
+			testArgs = args[1:]
+		}
+
+		ans := []interface{}{}
+		for _, item := range items {
+			value := item
+			if byAttr {
+				value = attribute(item, attr)
+			}
+
+			var ok bool
+			if test == nil {
+				ok = truth(value)
+			} else {
+				ok, err = test(value, testArgs)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			if ok != reject {
+				ans = append(ans, item)
+			}
+		}
+		return ans, nil
+	}
+}
+
+func filterTruncate(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	s := []rune(toString(v))
+	n := intArg(arg(args, kwargs, 0, "length", 255), 255, 0, math.MaxInt32)
+	killwords := truth(arg(args, kwargs, 1, "killwords", false))
+	end := []rune(toString(arg(args, kwargs, 2, "end", "...")))
+	leeway := intArg(arg(args, kwargs, 3, "leeway", 5), 5, 0, math.MaxInt32)
+
+	if n < len(end) {
+		return nil, fmt.Errorf("expected length >= %d, got %d", len(end), n)
+	}
+	if len(s) <= n+leeway {
+		return string(s), nil
+	}
+	if killwords {
+		return string(s[:n-len(end)]) + string(end), nil
+	}
+	ans := string(s[:n-len(end)])
+	if idx := strings.LastIndex(ans, " "); idx >= 0 {
+		ans = ans[:idx]
+	}
+	return ans + string(end), nil
+}
+
+func filterCenter(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	s := toString(v)
+	width := intArg(arg(args, kwargs, 0, "width", 80), 80, 0, maxFilterWidth)
+
+	// Same padding of the str.center method of Python.
+	pad := width - len([]rune(s))
+	if pad <= 0 {
+		return s, nil
+	}
+	left := pad/2 + (pad & width & 1)
+	return strings.Repeat(" ", left) + s + strings.Repeat(" ", pad-left), nil
+}
+
+// wrapChunks splits a line in the chunks of the words and of the
+// whitespaces as the textwrap module of Python. With hyphens the
+// hyphenated words are split after the hyphens.
+func wrapChunks(line string, hyphens bool) []string {
+	ans := []string{}
+	cur := []rune{}
+	space := false
+	flush := func() {
+		if len(cur) > 0 {
+			ans = append(ans, string(cur))
+			cur = []rune{}
+		}
+	}
+
+	runes := []rune(line)
+	for i, c := range runes {
+		isSpace := c == ' ' || c == '\t'
+		if isSpace != space {
+			flush()
+			space = isSpace
+		}
+		cur = append(cur, c)
+		if hyphens && c == '-' && i > 0 && i+1 < len(runes) &&
+			unicode.IsLetter(runes[i-1]) && unicode.IsLetter(runes[i+1]) {
+			flush()
+		}
+	}
+	flush()
+	return ans
+}
+
+// wrapLine wraps a line as the textwrap.wrap function of Python
+// without the expansion and the replacement of the whitespaces.
+func wrapLine(line string, width int, breakLong, hyphens bool) []string {
+	chunks := wrapChunks(line, hyphens)
+	lines := []string{}
+	isBlank := func(c string) bool { return strings.TrimSpace(c) == "" }
+
+	for len(chunks) > 0 {
+		cur := []string{}
+		curLen := 0
+
+		// Drop the whitespaces at the beginning of the lines
+		// except the first one.
+		if len(lines) > 0 && isBlank(chunks[0]) {
+			chunks = chunks[1:]
+		}
+
+		for len(chunks) > 0 {
+			l := len([]rune(chunks[0]))
+			if curLen+l > width {
+				break
+			}
+			cur = append(cur, chunks[0])
+			curLen += l
+			chunks = chunks[1:]
+		}
+
+		if len(chunks) > 0 && len([]rune(chunks[0])) > width {
+			if breakLong {
+				left := width - curLen
+				if left < 1 {
+					left = 1
+				}
+				r := []rune(chunks[0])
+				cur = append(cur, string(r[:left]))
+				chunks[0] = string(r[left:])
+			} else if len(cur) == 0 {
+				cur = append(cur, chunks[0])
+				chunks = chunks[1:]
+			}
+		}
+
+		if len(cur) > 0 && isBlank(cur[len(cur)-1]) {
+			cur = cur[:len(cur)-1]
+		}
+		if len(cur) > 0 {
+			lines = append(lines, strings.Join(cur, ""))
+		}
+	}
+
+	return lines
+}
+
+func filterWordwrap(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	width := intArg(arg(args, kwargs, 0, "width", 79), 79, 1, math.MaxInt32)
+	breakLong := truth(arg(args, kwargs, 1, "break_long_words", true))
+	wrapstring := "\n"
+	if w := arg(args, kwargs, 2, "wrapstring", nil); w != nil {
+		wrapstring = toString(w)
+	}
+	hyphens := truth(arg(args, kwargs, 3, "break_on_hyphens", true))
+
+	s := strings.ReplaceAll(toString(v), "\r\n", "\n")
+	parts := []string{}
+	for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		parts = append(parts, strings.Join(wrapLine(line, width, breakLong, hyphens), wrapstring))
+	}
+	return strings.Join(parts, wrapstring), nil
+}
+
+func filterBatch(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	items, err := iterable(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 && kwargs["linecount"] == nil {
+		return nil, fmt.Errorf("batch requires the linecount argument")
+	}
+	n := intArg(arg(args, kwargs, 0, "linecount", 1), 1, 1, math.MaxInt32)
+	fill := arg(args, kwargs, 1, "fill_with", nil)
+
+	ans := []interface{}{}
+	for i := 0; i < len(items); i += n {
+		end := i + n
+		if end > len(items) {
+			end = len(items)
+		}
+		batch := append([]interface{}{}, items[i:end]...)
+		for fill != nil && len(batch) < n {
+			batch = append(batch, fill)
+		}
+		ans = append(ans, batch)
+	}
+	return ans, nil
+}
+
+func filterSlice(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	items, err := iterable(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 && kwargs["slices"] == nil {
+		return nil, fmt.Errorf("slice requires the slices argument")
+	}
+	n := intArg(arg(args, kwargs, 0, "slices", 1), 1, 1, maxRangeSize)
+	fill := arg(args, kwargs, 1, "fill_with", nil)
+
+	perSlice := len(items) / n
+	withExtra := len(items) % n
+	offset := 0
+	ans := []interface{}{}
+	for i := 0; i < n; i++ {
+		start := offset + i*perSlice
+		if i < withExtra {
+			offset++
+		}
+		end := offset + (i+1)*perSlice
+		slice := append([]interface{}{}, items[start:end]...)
+		if fill != nil && i >= withExtra {
+			slice = append(slice, fill)
+		}
+		ans = append(ans, slice)
+	}
+	return ans, nil
+}
+
+// filterRandom returns a random item of a sequence or, as the
+// Ansible filter, a random number of range(start, end, step).
+// With seed the result is always the same.
+func filterRandom(v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	intn := rand.Int63n
+	if seed := arg(nil, kwargs, -1, "seed", nil); seed != nil {
+		h := fnv.New64a()
+		h.Write([]byte(toString(seed)))
+		intn = rand.New(rand.NewSource(int64(h.Sum64()))).Int63n
+	}
+
+	if isInt(v) {
+		end, _ := toInt(v)
+		start, ok := toInt(arg(args, kwargs, 0, "start", 0))
+		if !ok {
+			return nil, fmt.Errorf("random start must be an integer")
+		}
+		step, ok := toInt(arg(args, kwargs, 1, "step", 1))
+		if !ok || step <= 0 {
+			return nil, fmt.Errorf("random step must be a positive integer")
+		}
+		if end <= start {
+			return nil, fmt.Errorf("empty range for random: %d-%d", start, end)
+		}
+		n := (uint64(end)-uint64(start)-1)/uint64(step) + 1
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("range too large for random")
+		}
+		return start + int(intn(int64(n)))*step, nil
+	}
+
+	items, err := iterable(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return &Undefined{Name: "random", Msg: "No random item, sequence was empty."}, nil
+	}
+	return items[intn(int64(len(items)))], nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package jinja_test
+
+import (
+	. "github.com/MottainaiCI/ssh-compose/pkg/template/jinja"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Jinja filters", func() {
+
+	vars := map[string]interface{}{
+		"users": []interface{}{
+			map[string]interface{}{"name": "bob", "age": 30, "admin": true},
+			map[string]interface{}{"name": "Alice", "age": 25, "admin": false},
+			map[string]interface{}{"name": "carl", "age": 35},
+		},
+		"text": "the quick brown fox jumps",
+		"conf": map[string]interface{}{"b": 2, "a": 1},
+	}
+
+	render := func(source string) (string, error) {
+		return NewEnvironment().Render("test", source, vars)
+	}
+
+	DescribeTable("Render",
+		func(source, expected string) {
+			out, err := render(source)
+			Expect(err).Should(BeNil())
+			Expect(out).To(Equal(expected))
+		},
+		// truncate
+		Entry("Truncate on the words", "{{ 'foo bar baz qux'|truncate(9) }}", "foo..."),
+		Entry("Truncate killing the words", "{{ 'foo bar baz qux'|truncate(9, true) }}", "foo ba..."),
+		Entry("Truncate within the leeway", "{{ 'foo bar baz'|truncate(9) }}", "foo bar baz"),
+		Entry("Truncate with end and leeway", "{{ 'hello world'|truncate(8, end='!', leeway=0) }}", "hello!"),
+		Entry("Truncate with the default length", "{{ text|truncate }}", "the quick brown fox jumps"),
+		// center
+		Entry("Center", "[{{ 'ab'|center(7) }}] [{{ 'abc'|center(8) }}] [{{ 'a'|center(4) }}]", "[   ab  ] [  abc   ] [ a  ]"),
+		Entry("Center shorter than the string", "[{{ 'abcd'|center(3) }}]", "[abcd]"),
+		Entry("Center with negative width", "[{{ 'ab'|center(-3) }}]", "[ab]"),
+		// wordwrap
+		Entry("Wordwrap", "{{ text|wordwrap(10) }}", "the quick\nbrown fox\njumps"),
+		Entry("Wordwrap on the hyphens", "{{ 'a well-known text-wrapping test'|wordwrap(12) }}", "a well-known\ntext-\nwrapping\ntest"),
+		Entry("Wordwrap of the long words", "{{ 'abcdefghijkl mn'|wordwrap(5) }}", "abcde\nfghij\nkl mn"),
+		Entry("Wordwrap without break of the long words", "{{ 'abcdefghijkl mn'|wordwrap(5, false) }}", "abcdefghijkl\nmn"),
+		Entry("Wordwrap of the lines", "{{ 'one two\nthree four five'|wordwrap(9) }}", "one two\nthree\nfour five"),
+		Entry("Wordwrap keeps the leading whitespaces", "{{ '  lead space here'|wordwrap(8) }}", "  lead\nspace\nhere"),
+		Entry("Wordwrap with wrapstring", "{{ 'one two three'|wordwrap(7, wrapstring='<br>') }}", "one two<br>three"),
+		// batch
+		Entry("Batch", "{{ [1, 2, 3, 4, 5]|batch(2)|list }}", "[[1, 2], [3, 4], [5]]"),
+		Entry("Batch with fill", "{{ [1, 2, 3, 4, 5]|batch(2, 'x')|list }}", "[[1, 2], [3, 4], [5, 'x']]"),
+		Entry("Batch of an empty list", "{{ []|batch(3)|list }}", "[]"),
+		// slice
+		Entry("Slice", "{{ [1, 2, 3, 4, 5, 6, 7]|slice(3)|list }}", "[[1, 2, 3], [4, 5], [6, 7]]"),
+		Entry("Slice with fill", "{{ [1, 2, 3, 4, 5, 6, 7]|slice(3, 'x')|list }}", "[[1, 2, 3], [4, 5, 'x'], [6, 7, 'x']]"),
+		Entry("Slice with more slices than items", "{{ [1, 2]|slice(3)|list }}", "[[1], [2], []]"),
+		// random
+		Entry("Random of a single item", "{{ [5]|random }}", "5"),
+		Entry("Random of a range", "{{ 10|random(start=5, step=5) }}", "5"),
+		Entry("Random with seed", "{% set l = range(100)|list %}{{ l|random(seed='a') == l|random(seed='a') }}", "True"),
+		Entry("Random of an empty list", "{{ []|random is undefined }}", "True"),
+		// String filters
+		Entry("Capitalize and title", "{{ 'hELLO world'|capitalize }} {{ 'hello big-world'|title }}", "Hello world Hello Big-World"),
+		Entry("Replace with count", "{{ 'aaa'|replace('a', 'b', 2) }}", "bba"),
+		Entry("Trim with chars", "{{ 'xxaxx'|trim('x') }}", "a"),
+		Entry("Reverse string", "{{ 'abc'|reverse }}", "cba"),
+		Entry("Split with maxsplit", "{{ 'a,b,c'|split(',', 1) }}", "['a', 'b,c']"),
+		Entry("Quote", "{{ \"it's\"|quote }}", `'it'"'"'s'`),
+		Entry("Base64", "{{ 'hello'|b64encode }} {{ 'aGVsbG8='|b64decode }}", "aGVsbG8= hello"),
+		Entry("Path", "{{ '/etc/nginx/nginx.conf'|basename }} {{ '/etc/nginx/nginx.conf'|dirname }}", "nginx.conf /etc/nginx"),
+		Entry("Indent first and blank", "{{ 'a\n\nb'|indent(2, true, true) }}", "  a\n  \n  b"),
+		Entry("Indent with a string", "{{ 'a\nb'|indent('> ') }}", "a\n> b"),
+		Entry("Regex search with groups", "{{ 'port=8080'|regex_search('(\\\\w+)=(\\\\d+)', '\\\\2', '\\\\1') }}", "['8080', 'port']"),
+		Entry("Regex search without match", "{{ 'abc'|regex_search('x') }}", "None"),
+		Entry("Regex replace ignoring the case", "{{ 'ABC'|regex_replace('b', 'x', ignorecase=true) }}", "AxC"),
+		// Numbers
+		Entry("Int", "{{ '42'|int }} {{ '0x1F'|int(base=16) }} {{ 'x'|int(7) }} {{ 3.9|int }} {{ '3.9'|int }}", "42 31 7 3 3"),
+		Entry("Float", "{{ '1.5'|float }} {{ 'x'|float }} {{ 2|float }}", "1.5 0.0 2.0"),
+		Entry("Bool", "{{ 'yes'|bool }} {{ 'off'|bool }} {{ 1|bool }}", "True False True"),
+		Entry("Abs", "{{ -3|abs }} {{ (-1.5)|abs }}", "3 1.5"),
+		Entry("Round", "{{ 2.567|round(2) }} {{ 2.1|round(method='ceil') }} {{ 2.9|round(0, 'floor') }}", "2.57 3.0 2.0"),
+		// Collections
+		Entry("Join with attribute", "{{ users|join(',', attribute='name') }}", "bob,Alice,carl"),
+		Entry("Sort case insensitive by attribute", "{{ users|sort(attribute='name')|map(attribute='name')|list }}", "['Alice', 'bob', 'carl']"),
+		Entry("Sort reverse", "{{ [3, 1, 2]|sort(reverse=true) }}", "[3, 2, 1]"),
+		Entry("Sort case sensitive", "{{ ['b', 'A', 'a']|sort(case_sensitive=true) }}", "['A', 'a', 'b']"),
+		Entry("Min and max by attribute", "{{ (users|min(attribute='age')).name }} {{ (users|max(attribute='age')).name }}", "Alice carl"),
+		Entry("Sum with attribute and start", "{{ users|sum(attribute='age', start=10) }} {{ [1.5, 2]|sum }}", "100 3.5"),
+		Entry("Unique", "{{ [1, 2, 1, '1']|unique }}", "[1, 2, '1']"),
+		Entry("First and last of empty lists", "{{ []|first is undefined }} {{ []|last is undefined }}", "True True"),
+		Entry("Length of a mapping", "{{ conf|length }} {{ 'añb'|length }}", "2 3"),
+		Entry("Dictsort by value reverse", "{{ conf|dictsort(by='value', reverse=true) }}", "[['b', 2], ['a', 1]]"),
+		Entry("Dict2items and items2dict", "{{ conf|dict2items }} {{ conf|dict2items|items2dict }}",
+			"[{'key': 'a', 'value': 1}, {'key': 'b', 'value': 2}] {'a': 1, 'b': 2}"),
+		Entry("Dict2items with names", "{{ {'a': 1}|dict2items(key_name='k', value_name='v') }}", "[{'k': 'a', 'v': 1}]"),
+		Entry("Combine a list of dictionaries", "{{ {'a': 1}|combine([{'b': 2}, {'a': 3}]) }}", "{'a': 3, 'b': 2}"),
+		Entry("Map with a filter", "{{ ['a', 'b']|map('upper')|list }} {{ ['1', '2']|map('int')|sum }}", "['A', 'B'] 3"),
+		Entry("Map with a default", "{{ users|map(attribute='admin', default=false)|list }}", "[True, False, False]"),
+		Entry("Select and reject", "{{ [1, 2, 3, 4]|select('odd')|list }} {{ [1, 2, 3, 4]|reject('gt', 2)|list }}", "[1, 3] [1, 2]"),
+		Entry("Selectattr and rejectattr", "{{ users|selectattr('age', 'ge', 30)|map(attribute='name')|list }} {{ users|rejectattr('admin')|length }}",
+			"['bob', 'carl'] 2"),
+		Entry("Ternary", "{{ true|ternary('y', 'n') }} {{ none|ternary('y', 'n', 'null') }}", "y null"),
+		// Serialization
+		Entry("To json of the special values", "{{ [none, true, 1.5, 'a\"b']|to_json }}", `[null, true, 1.5, "a\"b"]`),
+		Entry("To nice yaml", "{{ conf|to_nice_yaml }}", "a: 1\nb: 2\n"),
+		Entry("From yaml", "{{ ('a: [1, 2]'|from_yaml).a[1] }}", "2"),
+	)
+
+	DescribeTable("Errors",
+		func(source, msg string) {
+			_, err := render(source)
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).To(Equal(msg))
+		},
+		Entry("Truncate shorter than the end", "{{ 'abcdef'|truncate(2) }}", "test:1: expected length >= 3, got 2"),
+		Entry("Batch without linecount", "{{ [1]|batch }}", "test:1: batch requires the linecount argument"),
+		Entry("Slice without slices", "{{ [1]|slice }}", "test:1: slice requires the slices argument"),
+		Entry("Random of an empty range", "{{ 0|random }}", "test:1: empty range for random: 0-0"),
+		Entry("Mandatory", "{{ missing|mandatory }}", "test:1: mandatory variable missing not defined"),
+		Entry("Mandatory with message", "{{ missing|mandatory('set it') }}", "test:1: set it"),
+		Entry("Replace without arguments", "{{ 'a'|replace }}", "test:1: replace expected at least 2 arguments"),
+		Entry("Invalid regex", "{{ 'a'|regex_replace('(', 'x') }}", "test:1: invalid regex (: error parsing regexp: missing closing ): `(`"),
+		Entry("Invalid json", "{{ '{'|from_json }}", "test:1: error on parse json: unexpected EOF"),
+		Entry("Invalid base64", "{{ '!'|b64decode }}", "test:1: error on decode base64: illegal base64 data at input byte 0"),
+		Entry("Round with an invalid method", "{{ 1.5|round(0, 'x') }}", "test:1: method must be common, ceil or floor"),
+		Entry("Abs of a string", "{{ 'a'|abs }}", "test:1: bad operand type for abs(): 'str'"),
+		Entry("Combine of a list", "{{ [1]|combine({}) }}", "test:1: combine expects dictionaries, got list"),
+		Entry("Items2dict without keys", "{{ [{'a': 1}]|items2dict }}", "test:1: items2dict requires a list of dictionaries with the key key"),
+		Entry("Sort of mixed types", "{{ [1, 'a']|sort }}", "test:1: '<' not supported between str and int"),
+		Entry("Map without filter", "{{ [1]|map }}", "test:1: map requires a filter or an attribute"),
+		Entry("Select with an unknown test", "{{ [1]|select('foo') }}", "test:1: no test named 'foo'"),
+		Entry("Join of a number", "{{ 1|join }}", "test:1: 'int' object is not iterable"),
+		Entry("Unknown filter", "\n{{ 1|foo }}", "test:2: no filter named 'foo'"),
+	)
+})
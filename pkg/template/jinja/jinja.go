@@ -0,0 +1,155 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+
+// Package jinja implements a template engine compatible with the
+// syntax of Jinja2 and with the common Ansible filters, so that the
+// Jinja2 templates are rendered without the external j2 tool.
+package jinja
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Error is the error of the parsing or of the rendering
+// of a template.
+type Error struct {
+	Name string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.Name, e.Line, e.Msg)
+}
+
+// FilterFunc is a filter applied to a value with the pipe
+// operator. The arguments are the arguments of the filter call.
+type FilterFunc func(value interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error)
+
+// TestFunc is a test used with the is operator.
+type TestFunc func(value interface{}, args []interface{}) (bool, error)
+
+// Loader returns the content of a template included with
+// the include statement.
+type Loader func(name string) (string, error)
+
+type Environment struct {
+	// Fail on the use of the undefined variables. Without strict
+	// the undefined variables are rendered as empty strings.
+	Strict bool
+
+	Filters map[string]FilterFunc
+	Tests   map[string]TestFunc
+	Globals map[string]interface{}
+
+	Loader Loader
+}
+
+// Template is a parsed template.
+type Template struct {
+	Name string
+
+	env  *Environment
+	body []node
+}
+
+// NewEnvironment returns an environment with the builtin
+// filters, tests and global functions.
+func NewEnvironment() *Environment {
+	ans := &Environment{
+		Strict:  false,
+		Filters: make(map[string]FilterFunc, 0),
+		Tests:   make(map[string]TestFunc, 0),
+		Globals: make(map[string]interface{}, 0),
+		Loader:  nil,
+	}
+
+	for k, v := range builtinFilters {
+		ans.Filters[k] = v
+	}
+	for k, v := range builtinTests {
+		ans.Tests[k] = v
+	}
+	for k, v := range builtinGlobals {
+		ans.Globals[k] = v
+	}
+
+	return ans
+}
+
+// NewFileLoader returns a loader that reads the templates
+// relative to the directory.
+func NewFileLoader(dir string) Loader {
+	return func(name string) (string, error) {
+		p := name
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, name)
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
+
+// Parse parses the source of a template.
+func (e *Environment) Parse(name, source string) (*Template, error) {
+	body, err := parse(name, source)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{Name: name, env: e, body: body}, nil
+}
+
+// Render parses and renders the source of a template.
+func (e *Environment) Render(name, source string, vars map[string]interface{}) (string, error) {
+	t, err := e.Parse(name, source)
+	if err != nil {
+		return "", err
+	}
+	return t.Render(vars)
+}
+
+// Render renders the template with the variables.
+func (t *Template) Render(vars map[string]interface{}) (ans string, err error) {
+	var out strings.Builder
+
+	r := &renderer{
+		env:   t.env,
+		name:  t.Name,
+		out:   &out,
+		calls: new(int),
+	}
+	r.root = newScope(nil)
+	for k, v := range t.env.Globals {
+		r.root.vars[k] = v
+	}
+	for k, v := range vars {
+		r.root.vars[k] = v
+	}
+
+	defer func() {
+		if e := recover(); e != nil {
+			if rerr, ok := e.(*Error); ok {
+				err = rerr
+				return
+			}
+			// POST: an unexpected error of a filter or of a
+			// method must not crash the caller.
+			err = &Error{Name: t.Name, Line: r.line, Msg: fmt.Sprintf("%v", e)}
+		}
+	}()
+
+	r.renderNodes(t.body, r.root)
+
+	return out.String(), nil
+}
@@ -0,0 +1,17 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package jinja_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestJinja(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Jinja engine Suite")
+}
@@ -0,0 +1,160 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package jinja_test
+
+import (
+	"strings"
+
+	. "github.com/MottainaiCI/ssh-compose/pkg/template/jinja"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Jinja", func() {
+
+	vars := map[string]interface{}{
+		"name":  "node1",
+		"port":  8080,
+		"ratio": 0.5,
+		"tags":  []interface{}{"web", "db", "web"},
+		"conf": map[string]interface{}{
+			"listen": "0.0.0.0",
+			"users":  []interface{}{"alice", "bob"},
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"name": "s1", "enabled": true},
+			map[string]interface{}{"name": "s2", "enabled": false},
+		},
+		"json": `{"a": 1, "b": [true, null, 2.5]}`,
+	}
+
+	render := func(source string, strict bool) (string, error) {
+		env := NewEnvironment()
+		env.Strict = strict
+		return env.Render("test", source, vars)
+	}
+
+	DescribeTable("Render",
+		func(source, expected string) {
+			out, err := render(source, false)
+			Expect(err).Should(BeNil())
+			Expect(out).To(Equal(expected))
+		},
+		Entry("Variables", "{{ name }}:{{ port }}", "node1:8080"),
+		Entry("Attributes", "{{ conf.listen }} {{ conf['users'][1] }} {{ tags[-1] }}", "0.0.0.0 bob web"),
+		Entry("Arithmetic", "{{ port + 1 }} {{ 7 // 2 }} {{ 7 / 2 }} {{ -7 % 3 }} {{ 2 ** 3 }}", "8081 3 3.5 2 8"),
+		Entry("Concat", "{{ name ~ '-' ~ port }}", "node1-8080"),
+		Entry("Comparison", "{{ port > 80 and 'web' in tags and not ('x' in tags) }}", "True"),
+		Entry("Conditional expression", "{{ 'on' if port == 8080 else 'off' }}", "on"),
+		Entry("If", "{% if port < 80 %}low{% elif port < 10000 %}mid{% else %}high{% endif %}", "mid"),
+		Entry("For", "{% for t in tags %}{{ loop.index }}{{ t }}{% if not loop.last %},{% endif %}{% endfor %}", "1web,2db,3web"),
+		Entry("For with filter and else", "{% for s in servers if s.enabled %}{{ s.name }}{% else %}none{% endfor %}", "s1"),
+		Entry("For on items", "{% for k, v in conf.items() %}{{ k }}={{ v|join(',') if v is sequence and v is not string else v }};{% endfor %}",
+			"listen=0.0.0.0;users=alice,bob;"),
+		Entry("Set and namespace", "{% set ns = namespace(n=0) %}{% for s in servers %}{% set ns.n = ns.n + 1 %}{% endfor %}{{ ns.n }}", "2"),
+		Entry("Block set", "{% set x %}a{{ port }}{% endset %}{{ x }}", "a8080"),
+		Entry("Whitespace control", "a\n  {%- if true -%}\n  b\n  {%- endif -%}\n  c", "abc"),
+		Entry("Raw", "{% raw %}{{ name }}{% endraw %}", "{{ name }}"),
+		Entry("Comment", "a{# comment #}b", "ab"),
+		Entry("Macro", "{% macro srv(n, p=80) %}{{ n }}:{{ p }}{% endmacro %}{{ srv('a') }} {{ srv('b', p=443) }}", "a:80 b:443"),
+		Entry("Default", "{{ missing|default('x') }} {{ ''|default('y', true) }} {{ name|d('z') }}", "x y node1"),
+		Entry("To json", "{{ conf|to_json }}", `{"listen": "0.0.0.0", "users": ["alice", "bob"]}`),
+		Entry("To nice json", "{{ {'a': [1]}|to_nice_json(indent=2) }}", "{\n  \"a\": [\n    1\n  ]\n}"),
+		Entry("From json", "{{ (json|from_json).b[2] }} {{ (json|from_json).a + 1 }}", "2.5 2"),
+		Entry("To yaml", "{{ conf|to_yaml }}", "listen: 0.0.0.0\nusers:\n  - alice\n  - bob\n"),
+		Entry("String filters", "{{ name|upper }} {{ '  x '|trim }} {{ 'a-b'|replace('-', '_') }} {{ tags|length }}", "NODE1 x a_b 3"),
+		Entry("List filters", "{{ tags|unique|sort|join(',') }} {{ tags|first }} {{ [3, 1, 2]|max }}", "db,web web 3"),
+		Entry("Map and select", "{{ servers|selectattr('enabled')|map(attribute='name')|list }}", "['s1']"),
+		Entry("Indent", "{{ 'a\nb\n\nc'|indent(2) }}", "a\n  b\n\n  c"),
+		Entry("Format", "{{ '%s:%05d'|format(name, port) }} {{ '%s-%s' % ('a', 'b') }}", "node1:08080 a-b"),
+		Entry("Tests", "{{ missing is defined }} {{ port is divisibleby 8 }} {{ conf is mapping }} {{ none is none }}", "False True True True"),
+		Entry("Methods", "{{ name.upper() }} {{ 'a,b'.split(',')|last }} {{ conf.get('x', 'def') }}", "NODE1 b def"),
+		Entry("Range", "{% for i in range(1, 4) %}{{ i }}{% endfor %}", "123"),
+		Entry("Slice", "{{ tags[1:]|join(',') }} {{ name[::-1] }}", "db,web 1edon"),
+		Entry("Combine", "{{ {'a': 1, 'b': {'c': 1}}|combine({'b': {'d': 2}}, recursive=true)|to_json }}", `{"a": 1, "b": {"c": 1, "d": 2}}`),
+		Entry("Regex replace", "{{ 'host-01'|regex_replace('(\\\\w+)-(\\\\d+)', '\\\\2-\\\\1') }}", "01-host"),
+		Entry("Undefined not strict", "[{{ missing }}]", "[]"),
+		Entry("Python representation", "{{ [1, 'a', true, none] }} {{ 1.0 }}", "[1, 'a', True, None] 1.0"),
+	)
+
+	Context("Errors", func() {
+
+		It("Undefined variable in strict mode", func() {
+			_, err := render("line1\n{{ missing }}", true)
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).To(Equal("test:2: 'missing' is undefined"))
+		})
+
+		It("Default with strict mode", func() {
+			out, err := render("{{ missing|default('ok') }}{% if missing is undefined %}!{% endif %}", true)
+			Expect(err).Should(BeNil())
+			Expect(out).To(Equal("ok!"))
+		})
+
+		It("Unknown filter", func() {
+			_, err := render("{{ name|foo }}", false)
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Unclosed block", func() {
+			_, err := render("{% if true %}a", false)
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Attribute of undefined", func() {
+			_, err := render("{{ missing.attr }}", false)
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("Limits", func() {
+
+		DescribeTable("Render",
+			func(source, expected string) {
+				out, err := render(source, false)
+				Expect(err).Should(BeNil())
+				Expect(out).To(Equal(expected))
+			},
+			Entry("Negative indent", "{{ 'a\nb'|indent(-1) }}", "a\nb"),
+			Entry("Negative json indent", "{{ [1]|to_nice_json(indent=-1) }}", "[1]"),
+			Entry("Round over the float precision", "{{ 1.5|round(400) }}", "1.5"),
+			Entry("Recursive macro", "{% macro f(n) %}{{ n * f(n - 1)|int if n > 1 else 1 }}{% endmacro %}{{ f(10) }}", "3628800"),
+			Entry("Max integer", "{{ 2 ** 62 }} {{ -9223372036854775807 - 1 }}", "4611686018427387904 -9223372036854775808"),
+			Entry("Negative repeat", "[{{ 'a' * -1 }}]", "[]"),
+			Entry("Range with the int bounds", "{{ range(-9223372036854775807, 9223372036854775807, 9223372036854775807)|list }}",
+				"[-9223372036854775807, 0]"),
+		)
+
+		DescribeTable("Errors",
+			func(source, msg string) {
+				_, err := render(source, false)
+				Expect(err).ShouldNot(BeNil())
+				Expect(err.Error()).To(ContainSubstring(msg))
+			},
+			Entry("Infinite recursion of a macro", "{% macro f() %}{{ f() }}{% endmacro %}{{ f() }}", "maximum recursion depth exceeded in macro f"),
+			Entry("Range too large", "{{ range(10000000000)|length }}", "range too large"),
+			Entry("Power overflow", "{{ 2 ** 100000 }}", "integer overflow"),
+			Entry("Sum overflow", "{{ 9223372036854775807 + 1 }}", "integer overflow"),
+			Entry("Product overflow", "{{ 4611686018427387904 * 2 }}", "integer overflow"),
+			Entry("Negation overflow", "{{ -(-9223372036854775807 - 1) }}", "integer overflow"),
+			Entry("Sum filter overflow", "{{ [9223372036854775807, 1]|sum }}", "integer overflow"),
+			Entry("String too large", "{{ 'a' * 100000000 }}", "string too large"),
+			Entry("Sequence too large", "{{ [1, 2] * 100000 }}", "sequence too large"),
+			Entry("Format width too large", "{{ '%999999999d'|format(1) }}", "too large"),
+			Entry("Negative format index", "{{ '{-1}'.format(1) }}", "out of range"),
+			Entry("Namespace cycle", "{% set ns = namespace() %}{% set ns.x = [ns] %}{{ ns }}", "cannot assign"),
+			Entry("Nested expressions", "{{ "+strings.Repeat("(", 1000)+"1"+strings.Repeat(")", 1000)+" }}", "expression too deeply nested"),
+			Entry("Nested blocks", strings.Repeat("{% if true %}", 1000)+strings.Repeat("{% endif %}", 1000), "too many nested blocks"),
+		)
+
+		It("Range with 3 arguments", func() {
+			out, err := render("{{ range(10, 0, -3)|list }} {{ range(0, 10, 4)|list }}", false)
+			Expect(err).Should(BeNil())
+			Expect(out).To(Equal("[10, 7, 4, 1] [0, 4, 8]"))
+		})
+	})
+
+})
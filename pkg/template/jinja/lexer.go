@@ -0,0 +1,355 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package jinja
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	tagText = iota
+	tagOutput
+	tagStatement
+)
+
+// tag is a piece of the template: a text block, an output
+// expression ({{ }}) or a statement ({% %}).
+type tag struct {
+	kind    int
+	content string
+	line    int
+}
+
+// splitTags splits the template source in text blocks and tags,
+// applying the whitespace control of the tags ({%- -%}) and
+// keeping the content of the raw blocks untouched.
+func splitTags(name, source string) ([]tag, error) {
+	ans := []tag{}
+	line := 1
+	pos := 0
+	trimNext := false
+
+	addText := func(text string) {
+		lines := strings.Count(text, "\n")
+		if trimNext {
+			text = strings.TrimLeftFunc(text, unicode.IsSpace)
+			trimNext = false
+		}
+		if text != "" {
+			ans = append(ans, tag{kind: tagText, content: text, line: line})
+		}
+		line += lines
+	}
+
+	trimPrev := func() {
+		if len(ans) > 0 && ans[len(ans)-1].kind == tagText {
+			ans[len(ans)-1].content = strings.TrimRightFunc(
+				ans[len(ans)-1].content, unicode.IsSpace)
+		}
+	}
+
+	for pos < len(source) {
+		idx := indexTagStart(source[pos:])
+		if idx < 0 {
+			addText(source[pos:])
+			break
+		}
+
+		addText(source[pos : pos+idx])
+		pos += idx
+		opener := source[pos : pos+2]
+		pos += 2
+
+		if pos < len(source) && source[pos] == '-' {
+			trimPrev()
+			pos++
+		} else if pos < len(source) && source[pos] == '+' {
+			pos++
+		}
+
+		var closer string
+		switch opener {
+		case "{{":
+			closer = "}}"
+		case "{%":
+			closer = "%}"
+		default:
+			closer = "#}"
+		}
+
+		end := indexTagEnd(source[pos:], closer, opener == "{#")
+		if end < 0 {
+			return nil, &Error{Name: name, Line: line,
+				Msg: fmt.Sprintf("missing closing %s", closer)}
+		}
+
+		content := source[pos : pos+end]
+		startLine := line
+		line += strings.Count(content, "\n")
+		pos += end + 2
+
+		if strings.HasSuffix(content, "-") {
+			content = content[:len(content)-1]
+			trimNext = true
+		} else if strings.HasSuffix(content, "+") && opener == "{%" {
+			content = content[:len(content)-1]
+		}
+
+		switch opener {
+		case "{{":
+			ans = append(ans, tag{kind: tagOutput, content: content, line: startLine})
+		case "{%":
+			stmt := strings.TrimSpace(content)
+			if stmt != "raw" {
+				ans = append(ans, tag{kind: tagStatement, content: content, line: startLine})
+				continue
+			}
+
+			// The content of the raw block is not parsed.
+			rawEnd, rawLen, trimBefore, trimAfter := indexEndRaw(source[pos:])
+			if rawEnd < 0 {
+				return nil, &Error{Name: name, Line: startLine,
+					Msg: "missing endraw"}
+			}
+			raw := source[pos : pos+rawEnd]
+			if trimNext {
+				raw = strings.TrimLeftFunc(raw, unicode.IsSpace)
+				trimNext = false
+			}
+			if trimBefore {
+				raw = strings.TrimRightFunc(raw, unicode.IsSpace)
+			}
+			if raw != "" {
+				ans = append(ans, tag{kind: tagText, content: raw, line: line})
+			}
+			line += strings.Count(source[pos:pos+rawEnd+rawLen], "\n")
+			pos += rawEnd + rawLen
+			trimNext = trimAfter
+		}
+		// POST: the comments are dropped.
+	}
+
+	return ans, nil
+}
+
+func indexTagStart(s string) int {
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] == '{' && (s[i+1] == '{' || s[i+1] == '%' || s[i+1] == '#') {
+			return i
+		}
+	}
+	return -1
+}
+
+// indexTagEnd returns the index of the closer of a tag ignoring
+// the closers inside the string literals and inside the brackets.
+func indexTagEnd(s, closer string, comment bool) int {
+	if comment {
+		return strings.Index(s, closer)
+	}
+
+	var quote byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"':
+			quote = c
+			continue
+		case '(', '[', '{':
+			depth++
+			continue
+		case ')', ']':
+			depth--
+			continue
+		case '}':
+			if depth > 0 {
+				depth--
+				continue
+			}
+		}
+		if depth <= 0 && strings.HasPrefix(s[i:], closer) {
+			return i
+		}
+	}
+	return -1
+}
+
+// indexEndRaw returns the position and the length of the endraw
+// tag and its whitespace control flags.
+func indexEndRaw(s string) (int, int, bool, bool) {
+	pos := 0
+	for {
+		idx := strings.Index(s[pos:], "{%")
+		if idx < 0 {
+			return -1, 0, false, false
+		}
+		start := pos + idx
+		i := start + 2
+		trimBefore := false
+		if i < len(s) && (s[i] == '-' || s[i] == '+') {
+			trimBefore = s[i] == '-'
+			i++
+		}
+		end := strings.Index(s[i:], "%}")
+		if end < 0 {
+			return -1, 0, false, false
+		}
+		content := s[i : i+end]
+		trimAfter := false
+		if strings.HasSuffix(content, "-") {
+			trimAfter = true
+			content = content[:len(content)-1]
+		}
+		if strings.TrimSpace(content) == "endraw" {
+			return start, i + end + 2 - start, trimBefore, trimAfter
+		}
+		pos = start + 2
+	}
+}
+
+const (
+	tokName = iota
+	tokString
+	tokInt
+	tokFloat
+	tokOperator
+	tokEOF
+)
+
+type token struct {
+	kind  int
+	value string
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return t.value
+}
+
+var operators = []string{
+	"**", "//", "==", "!=", "<=", ">=",
+	"+", "-", "*", "/", "%", "~", "<", ">", "=",
+	"(", ")", "[", "]", "{", "}", ",", ":", ".", "|",
+}
+
+// tokenize splits the content of a tag in tokens.
+func tokenize(s string) ([]token, error) {
+	ans := []token{}
+	i := 0
+
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			var sb strings.Builder
+			j := i + 1
+			closed := false
+			for j < len(s) {
+				if s[j] == '\\' && j+1 < len(s) {
+					switch s[j+1] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 't':
+						sb.WriteByte('\t')
+					case 'r':
+						sb.WriteByte('\r')
+					case '0':
+						sb.WriteByte(0)
+					default:
+						sb.WriteByte(s[j+1])
+					}
+					j += 2
+					continue
+				}
+				if s[j] == c {
+					closed = true
+					break
+				}
+				sb.WriteByte(s[j])
+				j++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			ans = append(ans, token{kind: tokString, value: sb.String()})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			isFloat := false
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '_') {
+				j++
+			}
+			if j+1 < len(s) && s[j] == '.' && s[j+1] >= '0' && s[j+1] <= '9' {
+				isFloat = true
+				j++
+				for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+					j++
+				}
+			}
+			if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+				k := j + 1
+				if k < len(s) && (s[k] == '+' || s[k] == '-') {
+					k++
+				}
+				if k < len(s) && s[k] >= '0' && s[k] <= '9' {
+					isFloat = true
+					j = k
+					for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+						j++
+					}
+				}
+			}
+			v := strings.ReplaceAll(s[i:j], "_", "")
+			if isFloat {
+				ans = append(ans, token{kind: tokFloat, value: v})
+			} else {
+				ans = append(ans, token{kind: tokInt, value: v})
+			}
+			i = j
+		case isNameStart(c):
+			j := i
+			for j < len(s) && (isNameStart(s[j]) || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			ans = append(ans, token{kind: tokName, value: s[i:j]})
+			i = j
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(s[i:], op) {
+					ans = append(ans, token{kind: tokOperator, value: op})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unexpected char %q", c)
+			}
+		}
+	}
+
+	ans = append(ans, token{kind: tokEOF})
+	return ans, nil
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
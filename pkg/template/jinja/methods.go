@@ -0,0 +1,342 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package jinja
+
+import (
+	"fmt"
+	"strings"
+)
+
+// method returns the Python method of a string, a list or a dict
+// used by the templates.
+func method(v interface{}, name string) (Function, bool) {
+	switch {
+	case isString(v):
+		return stringMethod(toString(v), name)
+	case isMapping(v):
+		return mapMethod(v, name)
+	case isSequence(v):
+		return listMethod(toList(v), name)
+	}
+	return nil, false
+}
+
+func argString(args []interface{}, idx int, def string) string {
+	if idx < len(args) && args[idx] != nil {
+		return toString(args[idx])
+	}
+	return def
+}
+
+func stringMethod(s, name string) (Function, bool) {
+	var fn func(args []interface{}) (interface{}, error)
+
+	switch name {
+	case "upper":
+		fn = func(args []interface{}) (interface{}, error) { return strings.ToUpper(s), nil }
+	case "lower":
+		fn = func(args []interface{}) (interface{}, error) { return strings.ToLower(s), nil }
+	case "title":
+		fn = func(args []interface{}) (interface{}, error) { return title(s), nil }
+	case "capitalize":
+		fn = func(args []interface{}) (interface{}, error) { return capitalize(s), nil }
+	case "strip", "lstrip", "rstrip":
+		fn = func(args []interface{}) (interface{}, error) {
+			return trim(s, name, args), nil
+		}
+	case "split":
+		fn = func(args []interface{}) (interface{}, error) {
+			n := -1
+			if len(args) > 1 {
+				if i, ok := toInt(args[1]); ok && i >= 0 {
+					n = i + 1
+				}
+			}
+			return split(s, args, n), nil
+		}
+	case "splitlines":
+		fn = func(args []interface{}) (interface{}, error) {
+			ans := []interface{}{}
+			for _, l := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+				ans = append(ans, strings.TrimSuffix(l, "\r"))
+			}
+			if s == "" {
+				return []interface{}{}, nil
+			}
+			return ans, nil
+		}
+	case "startswith":
+		fn = func(args []interface{}) (interface{}, error) {
+			return strings.HasPrefix(s, argString(args, 0, "")), nil
+		}
+	case "endswith":
+		fn = func(args []interface{}) (interface{}, error) {
+			return strings.HasSuffix(s, argString(args, 0, "")), nil
+		}
+	case "replace":
+		fn = func(args []interface{}) (interface{}, error) {
+			if len(args) < 2 {
+				return nil, fmt.Errorf("replace expected at least 2 arguments")
+			}
+			n := -1
+			if len(args) > 2 {
+				n, _ = toInt(args[2])
+			}
+			return strings.Replace(s, toString(args[0]), toString(args[1]), n), nil
+		}
+	case "join":
+		fn = func(args []interface{}) (interface{}, error) {
+			if len(args) < 1 || !isSequence(args[0]) {
+				return nil, fmt.Errorf("join expected a list")
+			}
+			items := []string{}
+			for _, i := range toList(args[0]) {
+				items = append(items, toString(i))
+			}
+			return strings.Join(items, s), nil
+		}
+	case "count":
+		fn = func(args []interface{}) (interface{}, error) {
+			return strings.Count(s, argString(args, 0, "")), nil
+		}
+	case "find":
+		fn = func(args []interface{}) (interface{}, error) {
+			return strings.Index(s, argString(args, 0, "")), nil
+		}
+	case "isdigit":
+		fn = func(args []interface{}) (interface{}, error) {
+			if s == "" {
+				return false, nil
+			}
+			for _, c := range s {
+				if c < '0' || c > '9' {
+					return false, nil
+				}
+			}
+			return true, nil
+		}
+	case "format":
+		return func(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+			return strFormat(s, args, kwargs)
+		}, true
+	default:
+		return nil, false
+	}
+
+	return func(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+		return fn(args)
+	}, true
+}
+
+func mapMethod(m interface{}, name string) (Function, bool) {
+	var fn func(args []interface{}) (interface{}, error)
+
+	switch name {
+	case "items":
+		fn = func(args []interface{}) (interface{}, error) {
+			ans := []interface{}{}
+			for _, k := range mapKeys(m) {
+				v, _ := mapGet(m, k)
+				ans = append(ans, []interface{}{k, v})
+			}
+			return ans, nil
+		}
+	case "keys":
+		fn = func(args []interface{}) (interface{}, error) {
+			return mapKeys(m), nil
+		}
+	case "values":
+		fn = func(args []interface{}) (interface{}, error) {
+			ans := []interface{}{}
+			for _, k := range mapKeys(m) {
+				v, _ := mapGet(m, k)
+				ans = append(ans, v)
+			}
+			return ans, nil
+		}
+	case "get":
+		fn = func(args []interface{}) (interface{}, error) {
+			if len(args) < 1 {
+				return nil, fmt.Errorf("get expected at least 1 argument")
+			}
+			if v, ok := mapGet(m, args[0]); ok {
+				return v, nil
+			}
+			if len(args) > 1 {
+				return args[1], nil
+			}
+			return nil, nil
+		}
+	default:
+		return nil, false
+	}
+
+	return func(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+		return fn(args)
+	}, true
+}
+
+func listMethod(l []interface{}, name string) (Function, bool) {
+	switch name {
+	case "index":
+		return func(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+			if len(args) < 1 {
+				return nil, fmt.Errorf("index expected 1 argument")
+			}
+			for i, item := range l {
+				if equals(item, args[0]) {
+					return i, nil
+				}
+			}
+			return nil, fmt.Errorf("%s is not in list", repr(args[0]))
+		}, true
+	case "count":
+		return func(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+			if len(args) < 1 {
+				return nil, fmt.Errorf("count expected 1 argument")
+			}
+			n := 0
+			for _, item := range l {
+				if equals(item, args[0]) {
+					n++
+				}
+			}
+			return n, nil
+		}, true
+	}
+	return nil, false
+}
+
+func title(s string) string {
+	var sb strings.Builder
+	prevLetter := false
+	for _, c := range s {
+		isLetter := strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ", c)
+		if isLetter && !prevLetter {
+			sb.WriteString(strings.ToUpper(string(c)))
+		} else {
+			sb.WriteString(strings.ToLower(string(c)))
+		}
+		prevLetter = isLetter
+	}
+	return sb.String()
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	return strings.ToUpper(string(r[0])) + strings.ToLower(string(r[1:]))
+}
+
+func trim(s, name string, args []interface{}) string {
+	chars := ""
+	if len(args) > 0 && args[0] != nil {
+		chars = toString(args[0])
+	}
+
+	switch name {
+	case "lstrip":
+		if chars == "" {
+			return strings.TrimLeft(s, " \t\n\r\v\f")
+		}
+		return strings.TrimLeft(s, chars)
+	case "rstrip":
+		if chars == "" {
+			return strings.TrimRight(s, " \t\n\r\v\f")
+		}
+		return strings.TrimRight(s, chars)
+	}
+	if chars == "" {
+		return strings.TrimSpace(s)
+	}
+	return strings.Trim(s, chars)
+}
+
+func split(s string, args []interface{}, n int) []interface{} {
+	const spaces = " \t\n\r\v\f"
+	parts := []string{}
+
+	if len(args) == 0 || args[0] == nil {
+		rest := strings.TrimLeft(s, spaces)
+		for rest != "" {
+			if n > 0 && len(parts) == n-1 {
+				parts = append(parts, rest)
+				break
+			}
+			idx := strings.IndexAny(rest, spaces)
+			if idx < 0 {
+				parts = append(parts, rest)
+				break
+			}
+			parts = append(parts, rest[:idx])
+			rest = strings.TrimLeft(rest[idx:], spaces)
+		}
+	} else {
+		parts = strings.SplitN(s, toString(args[0]), n)
+	}
+
+	ans := make([]interface{}, len(parts))
+	for i := range parts {
+		ans[i] = parts[i]
+	}
+	return ans
+}
+
+// strFormat implements the str.format method with the positional
+// ({} and {0}) and the named fields.
+func strFormat(s string, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	var sb strings.Builder
+	auto := 0
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '{' {
+			if i+1 < len(s) && s[i+1] == '{' {
+				sb.WriteByte('{')
+				i++
+				continue
+			}
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("single '{' encountered in format string")
+			}
+			field := s[i+1 : i+end]
+			i += end
+
+			var v interface{}
+			switch {
+			case field == "":
+				if auto >= len(args) {
+					return nil, fmt.Errorf("replacement index %d out of range", auto)
+				}
+				v = args[auto]
+				auto++
+			default:
+				if idx, err := parseIndex(field); err == nil && fmt.Sprint(idx) == field {
+					if idx < 0 || idx >= len(args) {
+						return nil, fmt.Errorf("replacement index %d out of range", idx)
+					}
+					v = args[idx]
+				} else {
+					kv, ok := kwargs[field]
+					if !ok {
+						return nil, fmt.Errorf("missing format field %s", field)
+					}
+					v = kv
+				}
+			}
+			sb.WriteString(toString(v))
+			continue
+		}
+		if c == '}' && i+1 < len(s) && s[i+1] == '}' {
+			i++
+		}
+		sb.WriteByte(c)
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,1067 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package jinja
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expressions
+
+type expr interface{}
+
+type literalExpr struct{ value interface{} }
+
+type nameExpr struct{ name string }
+
+type listExpr struct{ items []expr }
+
+type dictExpr struct{ keys, values []expr }
+
+type attrExpr struct {
+	target expr
+	name   string
+}
+
+type indexExpr struct{ target, index expr }
+
+type sliceExpr struct{ target, start, stop, step expr }
+
+type kwarg struct {
+	name  string
+	value expr
+}
+
+type callExpr struct {
+	fn     expr
+	args   []expr
+	kwargs []kwarg
+}
+
+type filterExpr struct {
+	target expr
+	name   string
+	args   []expr
+	kwargs []kwarg
+}
+
+type testExpr struct {
+	target expr
+	name   string
+	args   []expr
+	negate bool
+}
+
+type unaryExpr struct {
+	op      string
+	operand expr
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+type condExpr struct{ cond, then, els expr }
+
+// compareExpr is a chain of comparisons (ex. 1 < x < 3) evaluated
+// as the comparisons of the adjacent operands joined with and.
+type compareExpr struct {
+	operands []expr
+	ops      []string
+}
+
+// Statements
+
+type node interface{}
+
+type textNode struct{ text string }
+
+type outputNode struct {
+	expr expr
+	line int
+}
+
+type ifNode struct {
+	conds    []expr
+	bodies   [][]node
+	elseBody []node
+	line     int
+}
+
+type forNode struct {
+	targets  []string
+	iter     expr
+	filter   expr
+	body     []node
+	elseBody []node
+	line     int
+}
+
+type setNode struct {
+	targets []string
+	attr    string
+	expr    expr
+	body    []node
+	line    int
+}
+
+type macroNode struct {
+	name     string
+	params   []string
+	defaults []expr
+	body     []node
+	line     int
+}
+
+type includeNode struct {
+	template      expr
+	ignoreMissing bool
+	line          int
+}
+
+type filterBlockNode struct {
+	filter *filterExpr
+	body   []node
+	line   int
+}
+
+// Max depth of the nested blocks and of the nested expressions.
+const maxNestingDepth = 256
+
+// parser builds the tree of the statements of a template.
+type parser struct {
+	name  string
+	tags  []tag
+	pos   int
+	depth int
+}
+
+func parse(name, source string) ([]node, error) {
+	tags, err := splitTags(name, source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{name: name, tags: tags}
+	body, end, _, err := p.parseBody()
+	if err != nil {
+		return nil, err
+	}
+	if end != "" {
+		return nil, p.errorf(p.tags[p.pos-1].line, "unexpected %s", end)
+	}
+
+	return body, nil
+}
+
+func (p *parser) errorf(line int, format string, args ...interface{}) error {
+	return &Error{Name: p.name, Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseBody parses the tags until one of the end keywords and returns
+// the parsed nodes, the end keyword found and its expression parser.
+func (p *parser) parseBody(endKeywords ...string) ([]node, string, *exprParser, error) {
+	ans := []node{}
+
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxNestingDepth {
+		return nil, "", nil, p.errorf(p.tags[p.pos-1].line, "too many nested blocks")
+	}
+
+	for p.pos < len(p.tags) {
+		t := p.tags[p.pos]
+		p.pos++
+
+		switch t.kind {
+		case tagText:
+			ans = append(ans, &textNode{text: t.content})
+
+		case tagOutput:
+			ep, err := newExprParser(t.content)
+			if err != nil {
+				return nil, "", nil, p.errorf(t.line, "%s", err.Error())
+			}
+			e, err := ep.parseTuple()
+			if err == nil {
+				err = ep.expectEnd()
+			}
+			if err != nil {
+				return nil, "", nil, p.errorf(t.line, "%s", err.Error())
+			}
+			ans = append(ans, &outputNode{expr: e, line: t.line})
+
+		case tagStatement:
+			ep, err := newExprParser(t.content)
+			if err != nil {
+				return nil, "", nil, p.errorf(t.line, "%s", err.Error())
+			}
+			keyword := ep.next()
+			if keyword.kind != tokName {
+				return nil, "", nil, p.errorf(t.line, "invalid statement %s",
+					strings.TrimSpace(t.content))
+			}
+
+			for _, end := range endKeywords {
+				if keyword.value == end {
+					return ans, end, ep, nil
+				}
+			}
+
+			n, err := p.parseStatement(keyword.value, ep, t.line)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			if n != nil {
+				ans = append(ans, n)
+			}
+		}
+	}
+
+	if len(endKeywords) > 0 {
+		return nil, "", nil, p.errorf(p.tags[len(p.tags)-1].line,
+			"unexpected end of template, expected %s",
+			strings.Join(endKeywords, " or "))
+	}
+
+	return ans, "", nil, nil
+}
+
+func (p *parser) parseStatement(keyword string, ep *exprParser, line int) (node, error) {
+	wrap := func(err error) error {
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(*Error); ok {
+			return err
+		}
+		return p.errorf(line, "%s", err.Error())
+	}
+
+	switch keyword {
+	case "if":
+		n := &ifNode{line: line}
+		cond, err := ep.parseExpr()
+		if err == nil {
+			err = ep.expectEnd()
+		}
+		if err != nil {
+			return nil, wrap(err)
+		}
+
+		for {
+			body, end, endParser, err := p.parseBody("elif", "else", "endif")
+			if err != nil {
+				return nil, err
+			}
+			n.conds = append(n.conds, cond)
+			n.bodies = append(n.bodies, body)
+
+			switch end {
+			case "elif":
+				cond, err = endParser.parseExpr()
+				if err == nil {
+					err = endParser.expectEnd()
+				}
+				if err != nil {
+					return nil, wrap(err)
+				}
+				continue
+			case "else":
+				n.elseBody, _, _, err = p.parseBody("endif")
+				if err != nil {
+					return nil, err
+				}
+			}
+			break
+		}
+		return n, nil
+
+	case "for":
+		n := &forNode{line: line}
+		for {
+			t := ep.next()
+			if t.kind != tokName {
+				return nil, p.errorf(line, "invalid loop variable %s", t)
+			}
+			n.targets = append(n.targets, t.value)
+			if !ep.skipOperator(",") {
+				break
+			}
+		}
+		if !ep.skipName("in") {
+			return nil, p.errorf(line, "expected in")
+		}
+		iter, err := ep.parseOr()
+		if err != nil {
+			return nil, wrap(err)
+		}
+		n.iter = iter
+		if ep.skipName("if") {
+			n.filter, err = ep.parseExpr()
+			if err != nil {
+				return nil, wrap(err)
+			}
+		}
+		if err := ep.expectEnd(); err != nil {
+			return nil, wrap(err)
+		}
+
+		body, end, _, err := p.parseBody("else", "endfor")
+		if err != nil {
+			return nil, err
+		}
+		n.body = body
+		if end == "else" {
+			n.elseBody, _, _, err = p.parseBody("endfor")
+			if err != nil {
+				return nil, err
+			}
+		}
+		return n, nil
+
+	case "set":
+		n := &setNode{line: line}
+		for {
+			t := ep.next()
+			if t.kind != tokName {
+				return nil, p.errorf(line, "invalid variable %s", t)
+			}
+			n.targets = append(n.targets, t.value)
+			if len(n.targets) == 1 && ep.skipOperator(".") {
+				a := ep.next()
+				if a.kind != tokName {
+					return nil, p.errorf(line, "invalid attribute %s", a)
+				}
+				n.attr = a.value
+				break
+			}
+			if !ep.skipOperator(",") {
+				break
+			}
+		}
+
+		if ep.peek().kind == tokEOF {
+			// POST: block assignment.
+			if len(n.targets) != 1 || n.attr != "" {
+				return nil, p.errorf(line, "invalid block assignment")
+			}
+			body, _, _, err := p.parseBody("endset")
+			if err != nil {
+				return nil, err
+			}
+			n.body = body
+			return n, nil
+		}
+
+		if !ep.skipOperator("=") {
+			return nil, p.errorf(line, "expected =")
+		}
+		e, err := ep.parseTuple()
+		if err == nil {
+			err = ep.expectEnd()
+		}
+		if err != nil {
+			return nil, wrap(err)
+		}
+		n.expr = e
+		return n, nil
+
+	case "macro":
+		n := &macroNode{line: line}
+		t := ep.next()
+		if t.kind != tokName {
+			return nil, p.errorf(line, "invalid macro name %s", t)
+		}
+		n.name = t.value
+		if !ep.skipOperator("(") {
+			return nil, p.errorf(line, "expected (")
+		}
+		for !ep.skipOperator(")") {
+			a := ep.next()
+			if a.kind != tokName {
+				return nil, p.errorf(line, "invalid macro parameter %s", a)
+			}
+			n.params = append(n.params, a.value)
+			var def expr
+			if ep.skipOperator("=") {
+				d, err := ep.parseExpr()
+				if err != nil {
+					return nil, wrap(err)
+				}
+				def = d
+			}
+			n.defaults = append(n.defaults, def)
+			if !ep.skipOperator(",") {
+				if !ep.skipOperator(")") {
+					return nil, p.errorf(line, "expected )")
+				}
+				break
+			}
+		}
+		if err := ep.expectEnd(); err != nil {
+			return nil, wrap(err)
+		}
+		body, _, _, err := p.parseBody("endmacro")
+		if err != nil {
+			return nil, err
+		}
+		n.body = body
+		return n, nil
+
+	case "include":
+		n := &includeNode{line: line}
+		e, err := ep.parseExpr()
+		if err != nil {
+			return nil, wrap(err)
+		}
+		n.template = e
+		if ep.skipName("ignore") {
+			if !ep.skipName("missing") {
+				return nil, p.errorf(line, "expected missing")
+			}
+			n.ignoreMissing = true
+		}
+		// The context is always passed to the included template.
+		if ep.skipName("with") || ep.skipName("without") {
+			if !ep.skipName("context") {
+				return nil, p.errorf(line, "expected context")
+			}
+		}
+		if err := ep.expectEnd(); err != nil {
+			return nil, wrap(err)
+		}
+		return n, nil
+
+	case "filter":
+		n := &filterBlockNode{line: line}
+		f, err := ep.parseFilter(&literalExpr{})
+		if err == nil {
+			err = ep.expectEnd()
+		}
+		if err != nil {
+			return nil, wrap(err)
+		}
+		n.filter = f
+		n.body, _, _, err = p.parseBody("endfilter")
+		if err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+
+	return nil, p.errorf(line, "unknown statement %s", keyword)
+}
+
+// exprParser parses the expressions with the precedence of the
+// Jinja2 operators.
+type exprParser struct {
+	toks  []token
+	pos   int
+	depth int
+}
+
+func newExprParser(s string) (*exprParser, error) {
+	toks, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	return &exprParser{toks: toks}, nil
+}
+
+// enter increments the depth of the nested expressions and fails
+// over maxNestingDepth. The caller must call leave.
+func (ep *exprParser) enter() error {
+	ep.depth++
+	if ep.depth > maxNestingDepth {
+		return fmt.Errorf("expression too deeply nested")
+	}
+	return nil
+}
+
+func (ep *exprParser) leave() { ep.depth-- }
+
+func (ep *exprParser) peek() token { return ep.toks[ep.pos] }
+
+func (ep *exprParser) next() token {
+	t := ep.toks[ep.pos]
+	if t.kind != tokEOF {
+		ep.pos++
+	}
+	return t
+}
+
+func (ep *exprParser) isOperator(op string) bool {
+	t := ep.peek()
+	return t.kind == tokOperator && t.value == op
+}
+
+func (ep *exprParser) isName(name string) bool {
+	t := ep.peek()
+	return t.kind == tokName && t.value == name
+}
+
+func (ep *exprParser) skipOperator(op string) bool {
+	if ep.isOperator(op) {
+		ep.pos++
+		return true
+	}
+	return false
+}
+
+func (ep *exprParser) skipName(name string) bool {
+	if ep.isName(name) {
+		ep.pos++
+		return true
+	}
+	return false
+}
+
+func (ep *exprParser) expectOperator(op string) error {
+	if !ep.skipOperator(op) {
+		return fmt.Errorf("expected %s, got %s", op, ep.peek())
+	}
+	return nil
+}
+
+func (ep *exprParser) expectEnd() error {
+	if ep.peek().kind != tokEOF {
+		return fmt.Errorf("unexpected %s", ep.peek())
+	}
+	return nil
+}
+
+// parseTuple parses a comma separated list of expressions
+// without brackets.
+func (ep *exprParser) parseTuple() (expr, error) {
+	e, err := ep.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if !ep.isOperator(",") {
+		return e, nil
+	}
+
+	items := []expr{e}
+	for ep.skipOperator(",") {
+		if ep.peek().kind == tokEOF {
+			break
+		}
+		e, err := ep.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, e)
+	}
+	return &listExpr{items: items}, nil
+}
+
+func (ep *exprParser) parseExpr() (expr, error) {
+	defer ep.leave()
+	if err := ep.enter(); err != nil {
+		return nil, err
+	}
+
+	e, err := ep.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if ep.skipName("if") {
+		cond, err := ep.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		var els expr
+		if ep.skipName("else") {
+			els, err = ep.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &condExpr{cond: cond, then: e, els: els}, nil
+	}
+
+	return e, nil
+}
+
+func (ep *exprParser) parseOr() (expr, error) {
+	left, err := ep.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for ep.skipName("or") {
+		right, err := ep.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (ep *exprParser) parseAnd() (expr, error) {
+	left, err := ep.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for ep.skipName("and") {
+		right, err := ep.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (ep *exprParser) parseNot() (expr, error) {
+	defer ep.leave()
+	if err := ep.enter(); err != nil {
+		return nil, err
+	}
+
+	if ep.skipName("not") {
+		e, err := ep.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "not", operand: e}, nil
+	}
+	return ep.parseCompare()
+}
+
+func (ep *exprParser) parseCompare() (expr, error) {
+	left, err := ep.parseMath1()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []expr{left}
+	ops := []string{}
+	for {
+		var op string
+		t := ep.peek()
+		switch {
+		case t.kind == tokOperator && (t.value == "==" || t.value == "!=" ||
+			t.value == "<" || t.value == "<=" || t.value == ">" || t.value == ">="):
+			op = t.value
+			ep.pos++
+		case ep.isName("in"):
+			op = "in"
+			ep.pos++
+		case ep.isName("not") && ep.toks[ep.pos+1].kind == tokName &&
+			ep.toks[ep.pos+1].value == "in":
+			op = "not in"
+			ep.pos += 2
+		default:
+			switch len(ops) {
+			case 0:
+				return left, nil
+			case 1:
+				return &binaryExpr{op: ops[0], left: operands[0], right: operands[1]}, nil
+			}
+			return &compareExpr{operands: operands, ops: ops}, nil
+		}
+
+		right, err := ep.parseMath1()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, right)
+		ops = append(ops, op)
+	}
+}
+
+func (ep *exprParser) parseMath1() (expr, error) {
+	left, err := ep.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+	for ep.isOperator("+") || ep.isOperator("-") {
+		op := ep.next().value
+		right, err := ep.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (ep *exprParser) parseConcat() (expr, error) {
+	left, err := ep.parseMath2()
+	if err != nil {
+		return nil, err
+	}
+	for ep.skipOperator("~") {
+		right, err := ep.parseMath2()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "~", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (ep *exprParser) parseMath2() (expr, error) {
+	left, err := ep.parsePow()
+	if err != nil {
+		return nil, err
+	}
+	for ep.isOperator("*") || ep.isOperator("/") ||
+		ep.isOperator("//") || ep.isOperator("%") {
+		op := ep.next().value
+		right, err := ep.parsePow()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (ep *exprParser) parsePow() (expr, error) {
+	left, err := ep.parseUnary(true)
+	if err != nil {
+		return nil, err
+	}
+	for ep.skipOperator("**") {
+		right, err := ep.parseUnary(true)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "**", left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseUnary parses an unary expression. As Jinja2 the filters
+// are applied to the result of the unary operators (-1|abs is 1).
+func (ep *exprParser) parseUnary(withFilter bool) (expr, error) {
+	defer ep.leave()
+	if err := ep.enter(); err != nil {
+		return nil, err
+	}
+
+	var e expr
+	var err error
+	if ep.isOperator("-") || ep.isOperator("+") {
+		op := ep.next().value
+		e, err = ep.parseUnary(false)
+		if err != nil {
+			return nil, err
+		}
+		e = &unaryExpr{op: op, operand: e}
+	} else {
+		e, err = ep.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		e, err = ep.parsePostfix(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !withFilter {
+		return e, nil
+	}
+	return ep.parseFilterExpr(e)
+}
+
+func (ep *exprParser) parsePrimary() (expr, error) {
+	t := ep.next()
+
+	switch t.kind {
+	case tokName:
+		switch t.value {
+		case "true", "True":
+			return &literalExpr{value: true}, nil
+		case "false", "False":
+			return &literalExpr{value: false}, nil
+		case "none", "None":
+			return &literalExpr{value: nil}, nil
+		}
+		return &nameExpr{name: t.value}, nil
+
+	case tokString:
+		s := t.value
+		// Adjacent strings are concatenated.
+		for ep.peek().kind == tokString {
+			s += ep.next().value
+		}
+		return &literalExpr{value: s}, nil
+
+	case tokInt:
+		v, err := strconv.Atoi(t.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %s", t.value)
+		}
+		return &literalExpr{value: v}, nil
+
+	case tokFloat:
+		v, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %s", t.value)
+		}
+		return &literalExpr{value: v}, nil
+
+	case tokOperator:
+		switch t.value {
+		case "(":
+			if ep.skipOperator(")") {
+				return &listExpr{}, nil
+			}
+			e, err := ep.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if ep.isOperator(",") {
+				items := []expr{e}
+				for ep.skipOperator(",") {
+					if ep.isOperator(")") {
+						break
+					}
+					e, err := ep.parseExpr()
+					if err != nil {
+						return nil, err
+					}
+					items = append(items, e)
+				}
+				e = &listExpr{items: items}
+			}
+			if err := ep.expectOperator(")"); err != nil {
+				return nil, err
+			}
+			return e, nil
+
+		case "[":
+			l := &listExpr{}
+			for !ep.skipOperator("]") {
+				e, err := ep.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				l.items = append(l.items, e)
+				if !ep.skipOperator(",") {
+					if err := ep.expectOperator("]"); err != nil {
+						return nil, err
+					}
+					break
+				}
+			}
+			return l, nil
+
+		case "{":
+			d := &dictExpr{}
+			for !ep.skipOperator("}") {
+				k, err := ep.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				if err := ep.expectOperator(":"); err != nil {
+					return nil, err
+				}
+				v, err := ep.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				d.keys = append(d.keys, k)
+				d.values = append(d.values, v)
+				if !ep.skipOperator(",") {
+					if err := ep.expectOperator("}"); err != nil {
+						return nil, err
+					}
+					break
+				}
+			}
+			return d, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected %s", t)
+}
+
+func (ep *exprParser) parsePostfix(e expr) (expr, error) {
+	for {
+		switch {
+		case ep.skipOperator("."):
+			t := ep.next()
+			if t.kind != tokName && t.kind != tokInt {
+				return nil, fmt.Errorf("invalid attribute %s", t)
+			}
+			e = &attrExpr{target: e, name: t.value}
+
+		case ep.skipOperator("["):
+			var start, stop, step expr
+			var err error
+			isSlice := false
+
+			if !ep.isOperator(":") {
+				start, err = ep.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+			}
+			if ep.skipOperator(":") {
+				isSlice = true
+				if !ep.isOperator("]") && !ep.isOperator(":") {
+					stop, err = ep.parseExpr()
+					if err != nil {
+						return nil, err
+					}
+				}
+				if ep.skipOperator(":") && !ep.isOperator("]") {
+					step, err = ep.parseExpr()
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+			if err := ep.expectOperator("]"); err != nil {
+				return nil, err
+			}
+			if isSlice {
+				e = &sliceExpr{target: e, start: start, stop: stop, step: step}
+			} else {
+				e = &indexExpr{target: e, index: start}
+			}
+
+		case ep.isOperator("("):
+			args, kwargs, err := ep.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			e = &callExpr{fn: e, args: args, kwargs: kwargs}
+
+		default:
+			return e, nil
+		}
+	}
+}
+
+// parseArgs parses the arguments of a call between brackets.
+func (ep *exprParser) parseArgs() ([]expr, []kwarg, error) {
+	args := []expr{}
+	kwargs := []kwarg{}
+
+	if err := ep.expectOperator("("); err != nil {
+		return nil, nil, err
+	}
+
+	for !ep.skipOperator(")") {
+		t := ep.peek()
+		if t.kind == tokName && ep.toks[ep.pos+1].kind == tokOperator &&
+			ep.toks[ep.pos+1].value == "=" {
+			ep.pos += 2
+			v, err := ep.parseExpr()
+			if err != nil {
+				return nil, nil, err
+			}
+			kwargs = append(kwargs, kwarg{name: t.value, value: v})
+		} else {
+			v, err := ep.parseExpr()
+			if err != nil {
+				return nil, nil, err
+			}
+			args = append(args, v)
+		}
+
+		if !ep.skipOperator(",") {
+			if err := ep.expectOperator(")"); err != nil {
+				return nil, nil, err
+			}
+			break
+		}
+	}
+
+	return args, kwargs, nil
+}
+
+func (ep *exprParser) parseFilter(e expr) (*filterExpr, error) {
+	t := ep.next()
+	if t.kind != tokName {
+		return nil, fmt.Errorf("invalid filter %s", t)
+	}
+	name := t.value
+	// Filters with namespace (ex. ansible.builtin.to_yaml).
+	for ep.isOperator(".") && ep.toks[ep.pos+1].kind == tokName {
+		ep.pos++
+		name = ep.next().value
+	}
+
+	f := &filterExpr{target: e, name: name}
+	if ep.isOperator("(") {
+		args, kwargs, err := ep.parseArgs()
+		if err != nil {
+			return nil, err
+		}
+		f.args = args
+		f.kwargs = kwargs
+	}
+	return f, nil
+}
+
+func (ep *exprParser) parseFilterExpr(e expr) (expr, error) {
+	for {
+		switch {
+		case ep.skipOperator("|"):
+			f, err := ep.parseFilter(e)
+			if err != nil {
+				return nil, err
+			}
+			e, err = ep.parsePostfix(f)
+			if err != nil {
+				return nil, err
+			}
+
+		case ep.skipName("is"):
+			negate := ep.skipName("not")
+			t := ep.next()
+			if t.kind != tokName {
+				return nil, fmt.Errorf("invalid test %s", t)
+			}
+			te := &testExpr{target: e, name: t.value, negate: negate}
+			switch {
+			case ep.isOperator("("):
+				args, _, err := ep.parseArgs()
+				if err != nil {
+					return nil, err
+				}
+				te.args = args
+			case ep.peek().kind == tokString || ep.peek().kind == tokInt ||
+				ep.peek().kind == tokFloat ||
+				ep.peek().kind == tokName && !isKeyword(ep.peek().value):
+				// Test with a single argument without brackets
+				// (ex. divisibleby 3).
+				arg, err := ep.parsePrimary()
+				if err != nil {
+					return nil, err
+				}
+				arg, err = ep.parsePostfix(arg)
+				if err != nil {
+					return nil, err
+				}
+				te.args = []expr{arg}
+			}
+			e = te
+
+		default:
+			return e, nil
+		}
+	}
+}
+
+func isKeyword(s string) bool {
+	switch s {
+	case "and", "or", "not", "in", "is", "if", "else":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package jinja_test
+
+import (
+	. "github.com/MottainaiCI/ssh-compose/pkg/template/jinja"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Jinja parser", func() {
+
+	render := func(source string) (string, error) {
+		return NewEnvironment().Render("test", source, map[string]interface{}{
+			"x":     2,
+			"items": []interface{}{1, 2, 3},
+		})
+	}
+
+	DescribeTable("Lexer",
+		func(source, expected string) {
+			out, err := render(source)
+			Expect(err).Should(BeNil())
+			Expect(out).To(Equal(expected))
+		},
+		Entry("Single quoted string with escapes", `{{ 'a\'b\\c' }}`, `a'b\c`),
+		Entry("Double quoted string with escapes", `{{ "a\tb\n\"c\"" }}`, "a\tb\n\"c\""),
+		Entry("Closers inside the strings", `{{ '}}' ~ "%}" }}`, "}}%}"),
+		Entry("Closers inside the brackets", `{{ {'a': {'b': 1}}.a.b }}`, "1"),
+		Entry("Adjacent strings", `{{ 'a' "b" 'c' }}`, "abc"),
+		Entry("Integer with underscores", "{{ 1_000_000 }}", "1000000"),
+		Entry("Floats", "{{ 1.5 }} {{ 1e3 }} {{ 2.5e-1 }} {{ 10.0 }}", "1.5 1000.0 0.25 10.0"),
+		Entry("Multiline tag", "{{\n  x\n  + 1\n}}", "3"),
+		Entry("Comment with tags", "a{# {{ x }} {% if %} #}b", "ab"),
+		Entry("Text with a single brace", "{ x } {x}", "{ x } {x}"),
+		Entry("Trim the previous whitespaces", "a  \n  {{- x }}", "a2"),
+		Entry("Trim the next whitespaces", "{{ x -}}  \n  b", "2b"),
+		Entry("Trim around a comment", "a {#- c -#} b", "ab"),
+		Entry("Disable the trim with plus", "a  {%+ if true %}b{% endif %}", "a  b"),
+		Entry("Raw with tags", "{% raw %}{% if %}{{ x }}{# c #}{% endraw %}", "{% if %}{{ x }}{# c #}"),
+		Entry("Raw with whitespace control", "a {%- raw -%} b {%- endraw -%} c", "abc"),
+		Entry("Newlines are kept", "a\n{% if true %}\nb\n{% endif %}\n", "a\n\nb\n\n"),
+	)
+
+	DescribeTable("Operators",
+		func(source, expected string) {
+			out, err := render(source)
+			Expect(err).Should(BeNil())
+			Expect(out).To(Equal(expected))
+		},
+		Entry("Precedence of the math operators", "{{ 2 + 3 * 4 }} {{ (2 + 3) * 4 }} {{ 10 - 4 - 3 }}", "14 20 3"),
+		Entry("Power is left associative", "{{ 2 ** 3 ** 2 }}", "64"),
+		Entry("Unary minus and power", "{{ -2 ** 2 }}", "4"),
+		Entry("Filters after the unary operators", "{{ -1|abs }} {{ -x|abs }}", "1 2"),
+		Entry("Filters before the math operators", "{{ 1 + items|length }}", "4"),
+		Entry("Concat before the math operators", "{{ 'a' ~ 2 * 3 }} {{ (1 + x) ~ 3 }}", "a6 33"),
+		Entry("Integer and float division", "{{ 7 / 7 }} {{ -7 // 2 }} {{ 7.5 // 2 }} {{ 7 % -3 }}", "1.0 -4 3.0 -2"),
+		Entry("Mixed int and float", "{{ 1 + 0.5 }} {{ 2 * 1.5 }} {{ 2 ** -1 }}", "1.5 3.0 0.5"),
+		Entry("Chained comparisons", "{{ 1 < x < 3 }} {{ 3 > x > 2 }} {{ 1 == 1 != 2 }}", "True False True"),
+		Entry("Boolean operators", "{{ not 1 == 2 }} {{ 0 or 'b' }} {{ 1 and 0 }} {{ not x in items }}", "True b 0 False"),
+		Entry("Not in", "{{ 4 not in items }} {{ 'b' in 'abc' }} {{ 'a' in {'a': 1} }}", "True True True"),
+		Entry("Tests", "{{ x is number }} {{ x is not string }} {{ items is iterable }} {{ x is odd }}", "True True True False"),
+		Entry("Test with an argument without brackets", "{{ x is divisibleby 2 }} {{ x is sameas 2 }}", "True True"),
+		Entry("Conditional without else", "[{{ 1 if false }}]", "[]"),
+		Entry("Nested conditional", "{{ 'a' if false else 'b' if true else 'c' }}", "b"),
+		Entry("List and dict literals with trailing comma", "{{ [1, 2,] }} {{ {'a': 1,} }} {{ (1,) }}", "[1, 2] {'a': 1} [1]"),
+		Entry("Tuple in for", "{% for a, b in [(1, 2), (3, 4)] %}{{ a + b }}{% endfor %}", "37"),
+		Entry("Call with keyword arguments", "{{ dict(a=1, b='x')|dictsort }}", "[['a', 1], ['b', 'x']]"),
+		Entry("Slices", "{{ items[::2] }} {{ items[-2:] }} {{ 'hello'[1:3] }}", "[1, 3] [2, 3] el"),
+	)
+
+	DescribeTable("Statements",
+		func(source, expected string) {
+			out, err := render(source)
+			Expect(err).Should(BeNil())
+			Expect(out).To(Equal(expected))
+		},
+		Entry("Loop variables", "{% for i in items %}{{ loop.index0 }}{{ loop.revindex }}{{ loop.cycle('a', 'b') }};{% endfor %}", "03a;12b;21a;"),
+		Entry("Loop prev and next items", "{% for i in items %}{{ loop.previtem|d('-') }}{{ loop.nextitem|d('-') }};{% endfor %}", "-2;13;2-;"),
+		Entry("Nested loops", "{% for a in [1, 2] %}{% for b in [3, 4] %}{{ a * b }},{% endfor %}{% endfor %}", "3,4,6,8,"),
+		Entry("For on a mapping", "{% for k in {'b': 1, 'a': 2} %}{{ k }}{% endfor %}", "ab"),
+		Entry("For on a string", "{% for c in 'abc' %}{{ c }}.{% endfor %}", "a.b.c."),
+		Entry("Set inside a loop is local", "{% set y = 1 %}{% for i in items %}{% set y = i %}{% endfor %}{{ y }}", "1"),
+		Entry("Set with multiple targets", "{% set a, b = 1, 2 %}{{ a }}{{ b }}", "12"),
+		Entry("Macro with defaults from the other arguments", "{% macro m(a, b=a * 2) %}{{ a }}-{{ b }}{% endmacro %}{{ m(2) }}", "2-4"),
+		Entry("Macro that uses the outer variables", "{% macro m() %}{{ x }}{% endmacro %}{{ m() }}", "2"),
+		Entry("Filter block", "{% filter upper %}a{{ x }}b{% endfilter %}", "A2B"),
+		Entry("Elif chain", "{% if x == 1 %}a{% elif x == 2 %}b{% elif x == 2 %}c{% else %}d{% endif %}", "b"),
+	)
+
+	DescribeTable("Errors",
+		func(source, msg string) {
+			_, err := render(source)
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).To(Equal(msg))
+		},
+		Entry("Unterminated string", "a\n{{ 'abc }}", "test:2: missing closing }}"),
+		Entry("Unterminated string in a statement", "{% set a = 'b %}", "test:1: missing closing %}"),
+		Entry("Missing closing tag", "\n\n{{ x ", "test:3: missing closing }}"),
+		Entry("Missing closing comment", "{# x ", "test:1: missing closing #}"),
+		Entry("Missing endraw", "\n{% raw %}x", "test:2: missing endraw"),
+		Entry("Unexpected char", "{{ x $ 1 }}", "test:1: unexpected char '$'"),
+		Entry("Incomplete expression", "a\n\n{{ 1 + }}", "test:3: unexpected end of expression"),
+		Entry("Unexpected token", "{{ x x }}", "test:1: unexpected x"),
+		Entry("Missing bracket", "{{ [1, 2 }}", "test:1: missing closing }}"),
+		Entry("Unknown statement", "\n{% foo %}", "test:2: unknown statement foo"),
+		Entry("Unexpected end of block", "{% endfor %}", "test:1: unknown statement endfor"),
+		Entry("Unclosed for", "{% for i in items %}\n{{ i }}\n", "test:2: unexpected end of template, expected else or endfor"),
+		Entry("Wrong end of block", "{% if true %}{% endfor %}", "test:1: unknown statement endfor"),
+		Entry("Line of a multiline tag", "a\n{{ x\n+ }}\n", "test:2: unexpected end of expression"),
+		Entry("Unknown test", "{{ x is foo }}", "test:1: no test named 'foo'"),
+		Entry("Call of a not callable", "{{ x() }}", "test:1: 'int' object is not callable"),
+		Entry("Division by zero", "\n{{ 1 / 0 }}", "test:2: division by zero"),
+		Entry("Integer division by zero", "{{ 1 // 0 }}", "test:1: integer division or modulo by zero"),
+		Entry("Unsupported operands", "{{ 'a' - 1 }}", "test:1: unsupported operand type(s) for -: 'str' and 'int'"),
+		Entry("Comparison of different types", "{{ 'a' < 1 }}", "test:1: '<' not supported between str and int"),
+		Entry("Unpack of a wrong number of values", "{% set a, b = [1] %}", "test:1: expected 2 values to unpack, got 1"),
+		Entry("Macro with too many arguments", "{% macro m(a) %}{% endmacro %}{{ m(1, 2) }}", "test:1: macro m takes not more than 1 arguments"),
+		Entry("Macro with an unknown keyword", "{% macro m(a) %}{% endmacro %}{{ m(b=1) }}", "test:1: macro m takes no keyword argument b"),
+		Entry("Set attribute of a non namespace", "{% set x.a = 1 %}", "test:1: cannot assign attribute on non-namespace object"),
+		Entry("Include without loader", "{% include 'a.j2' %}", "test:1: no loader available for include a.j2"),
+		Entry("Error inside a macro", "{% macro m() %}\n{{ 1 / 0 }}{% endmacro %}\n\n{{ m() }}", "test:2: division by zero"),
+	)
+})
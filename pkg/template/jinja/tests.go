@@ -0,0 +1,204 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package jinja
+
+import (
+	"fmt"
+	"strings"
+)
+
+var builtinTests = map[string]TestFunc{
+	"defined": func(v interface{}, args []interface{}) (bool, error) {
+		return !IsUndefined(v), nil
+	},
+	"undefined": func(v interface{}, args []interface{}) (bool, error) {
+		return IsUndefined(v), nil
+	},
+	"none": func(v interface{}, args []interface{}) (bool, error) {
+		return v == nil, nil
+	},
+	"boolean": func(v interface{}, args []interface{}) (bool, error) {
+		_, ok := v.(bool)
+		return ok, nil
+	},
+	"true": func(v interface{}, args []interface{}) (bool, error) {
+		b, ok := v.(bool)
+		return ok && b, nil
+	},
+	"false": func(v interface{}, args []interface{}) (bool, error) {
+		b, ok := v.(bool)
+		return ok && !b, nil
+	},
+	"string": func(v interface{}, args []interface{}) (bool, error) {
+		return isString(v), nil
+	},
+	"number": func(v interface{}, args []interface{}) (bool, error) {
+		return isNumber(v), nil
+	},
+	"integer": func(v interface{}, args []interface{}) (bool, error) {
+		return isInt(v), nil
+	},
+	"float": func(v interface{}, args []interface{}) (bool, error) {
+		return isFloat(v), nil
+	},
+	"mapping": func(v interface{}, args []interface{}) (bool, error) {
+		return isMapping(v), nil
+	},
+	"sequence": func(v interface{}, args []interface{}) (bool, error) {
+		return isSequence(v) || isString(v) || isMapping(v), nil
+	},
+	"iterable": func(v interface{}, args []interface{}) (bool, error) {
+		return isSequence(v) || isString(v) || isMapping(v), nil
+	},
+	"callable": func(v interface{}, args []interface{}) (bool, error) {
+		_, ok := v.(Function)
+		return ok, nil
+	},
+	"lower": func(v interface{}, args []interface{}) (bool, error) {
+		s := toString(v)
+		return isString(v) && strings.ToLower(s) == s, nil
+	},
+	"upper": func(v interface{}, args []interface{}) (bool, error) {
+		s := toString(v)
+		return isString(v) && strings.ToUpper(s) == s, nil
+	},
+	"even": func(v interface{}, args []interface{}) (bool, error) {
+		i, ok := toInt(v)
+		if !ok {
+			return false, fmt.Errorf("even expected an integer, got %s", typeName(v))
+		}
+		return i%2 == 0, nil
+	},
+	"odd": func(v interface{}, args []interface{}) (bool, error) {
+		i, ok := toInt(v)
+		if !ok {
+			return false, fmt.Errorf("odd expected an integer, got %s", typeName(v))
+		}
+		return i%2 != 0, nil
+	},
+	"divisibleby": func(v interface{}, args []interface{}) (bool, error) {
+		i, ok := toInt(v)
+		if !ok || len(args) < 1 {
+			return false, fmt.Errorf("divisibleby expected integers")
+		}
+		n, ok := toInt(args[0])
+		if !ok || n == 0 {
+			return false, fmt.Errorf("divisibleby expected a not zero integer")
+		}
+		return i%n == 0, nil
+	},
+	"eq":      compareTest("=="),
+	"equalto": compareTest("=="),
+	"==":      compareTest("=="),
+	"ne":      compareTest("!="),
+	"!=":      compareTest("!="),
+	"lt":      compareTest("<"),
+	"<":       compareTest("<"),
+	"le":      compareTest("<="),
+	"<=":      compareTest("<="),
+	"gt":      compareTest(">"),
+	">":       compareTest(">"),
+	"ge":      compareTest(">="),
+	">=":      compareTest(">="),
+	"in": func(v interface{}, args []interface{}) (bool, error) {
+		if len(args) < 1 {
+			return false, fmt.Errorf("in expected 1 argument")
+		}
+		return contains(args[0], v)
+	},
+	"sameas": func(v interface{}, args []interface{}) (bool, error) {
+		if len(args) < 1 {
+			return false, fmt.Errorf("sameas expected 1 argument")
+		}
+		return v == args[0], nil
+	},
+}
+
+func compareTest(op string) TestFunc {
+	return func(v interface{}, args []interface{}) (bool, error) {
+		if len(args) < 1 {
+			return false, fmt.Errorf("test %s expected 1 argument", op)
+		}
+		switch op {
+		case "==":
+			return equals(v, args[0]), nil
+		case "!=":
+			return !equals(v, args[0]), nil
+		}
+		c, err := compare(v, args[0])
+		if err != nil {
+			return false, err
+		}
+		switch op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	}
+}
+
+var builtinGlobals = map[string]interface{}{
+	"range": Function(func(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+		ints := []int{}
+		for _, a := range args {
+			i, ok := toInt(a)
+			if !ok {
+				return nil, fmt.Errorf("range expected integers, got %s", typeName(a))
+			}
+			ints = append(ints, i)
+		}
+
+		start, stop, step := 0, 0, 1
+		switch len(ints) {
+		case 1:
+			stop = ints[0]
+		case 2:
+			start, stop = ints[0], ints[1]
+		case 3:
+			start, stop, step = ints[0], ints[1], ints[2]
+		default:
+			return nil, fmt.Errorf("range expected 1 to 3 arguments, got %d", len(ints))
+		}
+		if step == 0 {
+			return nil, fmt.Errorf("range arg 3 must not be zero")
+		}
+
+		// Count the items with uint64 to avoid the overflow
+		// of the difference of the bounds.
+		var n uint64
+		if step > 0 && start < stop {
+			n = (uint64(stop)-uint64(start)-1)/uint64(step) + 1
+		} else if step < 0 && start > stop {
+			n = (uint64(start)-uint64(stop)-1)/(-uint64(step)) + 1
+		}
+		if n > maxRangeSize {
+			return nil, fmt.Errorf("range too large, max %d items", maxRangeSize)
+		}
+
+		ans := make([]interface{}, n)
+		for i := range ans {
+			ans[i] = start + i*step
+		}
+		return ans, nil
+	}),
+	"dict": Function(func(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+		ans := map[string]interface{}{}
+		for k, v := range kwargs {
+			ans[k] = v
+		}
+		return ans, nil
+	}),
+	"namespace": Function(func(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+		ans := Namespace{}
+		for k, v := range kwargs {
+			ans[k] = v
+		}
+		return ans, nil
+	}),
+}
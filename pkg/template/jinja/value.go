@@ -0,0 +1,506 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package jinja
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Undefined is the value of the variables and of the attributes
+// not defined.
+type Undefined struct {
+	Name string
+	// Message of the error of the missing attributes.
+	Msg string
+}
+
+func (u *Undefined) Error() string {
+	if u.Msg != "" {
+		return u.Msg
+	}
+	return fmt.Sprintf("'%s' is undefined", u.Name)
+}
+
+// Function is a callable value: a global function, a macro or a
+// method of a value.
+type Function func(args []interface{}, kwargs map[string]interface{}) (interface{}, error)
+
+// Namespace is the object created by namespace() used to
+// change values inside loops.
+type Namespace map[string]interface{}
+
+func IsUndefined(v interface{}) bool {
+	_, ok := v.(*Undefined)
+	return ok
+}
+
+// toInt converts an integer value of any type to int.
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int8:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case uint:
+		return int(n), true
+	case uint8:
+		return int(n), true
+	case uint16:
+		return int(n), true
+	case uint32:
+		return int(n), true
+	case uint64:
+		return int(n), true
+	case bool:
+		if n {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	}
+	if i, ok := toInt(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+func isInt(v interface{}) bool {
+	if _, ok := v.(bool); ok {
+		return false
+	}
+	_, ok := toInt(v)
+	return ok
+}
+
+func isFloat(v interface{}) bool {
+	switch v.(type) {
+	case float32, float64:
+		return true
+	}
+	return false
+}
+
+func isNumber(v interface{}) bool { return isInt(v) || isFloat(v) }
+
+func isString(v interface{}) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+func isMapping(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, map[interface{}]interface{}, Namespace:
+		return true
+	case nil, *Undefined:
+		return false
+	}
+	return reflect.ValueOf(v).Kind() == reflect.Map
+}
+
+func isSequence(v interface{}) bool {
+	switch v.(type) {
+	case []interface{}:
+		return true
+	case nil, string, *Undefined:
+		return false
+	}
+	k := reflect.ValueOf(v).Kind()
+	return k == reflect.Slice || k == reflect.Array
+}
+
+// truth returns the boolean value of a value with the Python rules.
+func truth(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case *Undefined:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != ""
+	case float64:
+		return t != 0
+	}
+	if i, ok := toInt(v); ok {
+		return i != 0
+	}
+	if isMapping(v) || isSequence(v) {
+		return reflect.ValueOf(v).Len() > 0
+	}
+	return true
+}
+
+// toString returns the string representation of a value
+// with the Python rules.
+func toString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "None"
+	case *Undefined:
+		return ""
+	case string:
+		return t
+	case bool:
+		if t {
+			return "True"
+		}
+		return "False"
+	case float64:
+		return formatFloat(t)
+	case float32:
+		return formatFloat(float64(t))
+	}
+	if i, ok := toInt(v); ok {
+		return strconv.Itoa(i)
+	}
+	if isMapping(v) || isSequence(v) {
+		return repr(v)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	case f == math.Trunc(f) && math.Abs(f) < 1e16:
+		return strconv.FormatFloat(f, 'f', 1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// repr returns the Python representation of a value.
+func repr(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(strings.ReplaceAll(t, "\\", "\\\\"), "'", "\\'") + "'"
+	}
+
+	if isSequence(v) {
+		items := []string{}
+		for _, i := range toList(v) {
+			items = append(items, repr(i))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+
+	if isMapping(v) {
+		items := []string{}
+		for _, k := range mapKeys(v) {
+			val, _ := mapGet(v, k)
+			items = append(items, repr(k)+": "+repr(val))
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	}
+
+	return toString(v)
+}
+
+// containsNamespace returns true if the value is or contains
+// the namespace.
+func containsNamespace(v interface{}, ns Namespace) bool {
+	switch {
+	case isMapping(v):
+		if n, ok := v.(Namespace); ok &&
+			reflect.ValueOf(n).Pointer() == reflect.ValueOf(ns).Pointer() {
+			return true
+		}
+		for _, k := range mapKeys(v) {
+			item, _ := mapGet(v, k)
+			if containsNamespace(item, ns) {
+				return true
+			}
+		}
+	case isSequence(v):
+		for _, item := range toList(v) {
+			if containsNamespace(item, ns) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// toList returns the items of a sequence.
+func toList(v interface{}) []interface{} {
+	switch t := v.(type) {
+	case []interface{}:
+		return t
+	case []string:
+		ans := make([]interface{}, len(t))
+		for i := range t {
+			ans[i] = t[i]
+		}
+		return ans
+	}
+
+	rv := reflect.ValueOf(v)
+	ans := make([]interface{}, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		ans[i] = rv.Index(i).Interface()
+	}
+	return ans
+}
+
+// mapKeys returns the sorted keys of a mapping.
+func mapKeys(v interface{}) []interface{} {
+	ans := []interface{}{}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k := range t {
+			ans = append(ans, k)
+		}
+	case Namespace:
+		for k := range t {
+			ans = append(ans, k)
+		}
+	default:
+		for _, k := range reflect.ValueOf(v).MapKeys() {
+			ans = append(ans, k.Interface())
+		}
+	}
+
+	sort.SliceStable(ans, func(i, j int) bool {
+		return lessValue(ans[i], ans[j])
+	})
+
+	return ans
+}
+
+// lessValue orders the values for the sort of the keys.
+func lessValue(a, b interface{}) bool {
+	if isNumber(a) && isNumber(b) {
+		fa, _ := toFloat(a)
+		fb, _ := toFloat(b)
+		return fa < fb
+	}
+	return toString(a) < toString(b)
+}
+
+func mapGet(v interface{}, key interface{}) (interface{}, bool) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		k, ok := key.(string)
+		if !ok {
+			return nil, false
+		}
+		ans, ok := t[k]
+		return ans, ok
+	case Namespace:
+		k, ok := key.(string)
+		if !ok {
+			return nil, false
+		}
+		ans, ok := t[k]
+		return ans, ok
+	case map[interface{}]interface{}:
+		ans, ok := t[key]
+		if !ok {
+			// Integer keys could be of different types.
+			if i, isI := toInt(key); isI {
+				ans, ok = t[i]
+			}
+		}
+		return ans, ok
+	}
+
+	rv := reflect.ValueOf(v)
+	kv := reflect.ValueOf(key)
+	if !kv.IsValid() || !kv.Type().AssignableTo(rv.Type().Key()) {
+		if kv.IsValid() && kv.Type().ConvertibleTo(rv.Type().Key()) &&
+			kv.Kind() == rv.Type().Key().Kind() {
+			kv = kv.Convert(rv.Type().Key())
+		} else {
+			return nil, false
+		}
+	}
+	ans := rv.MapIndex(kv)
+	if !ans.IsValid() {
+		return nil, false
+	}
+	return ans.Interface(), true
+}
+
+// structField returns the field of a struct by name or
+// by the yaml/json tag.
+func structField(v interface{}, name string) (interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tagName := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if tagName == "" {
+			tagName = strings.Split(f.Tag.Get("json"), ",")[0]
+		}
+		if f.Name == name || tagName == name {
+			return rv.Field(i).Interface(), true
+		}
+	}
+	return nil, false
+}
+
+// equals compares two values with the Python rules.
+func equals(a, b interface{}) bool {
+	if IsUndefined(a) || IsUndefined(b) {
+		return IsUndefined(a) && IsUndefined(b)
+	}
+	if isNumber(a) && isNumber(b) {
+		fa, _ := toFloat(a)
+		fb, _ := toFloat(b)
+		return fa == fb
+	}
+	if isString(a) && isString(b) {
+		return toString(a) == toString(b)
+	}
+	if isSequence(a) && isSequence(b) {
+		la, lb := toList(a), toList(b)
+		if len(la) != len(lb) {
+			return false
+		}
+		for i := range la {
+			if !equals(la[i], lb[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if isMapping(a) && isMapping(b) {
+		ka := mapKeys(a)
+		if len(ka) != len(mapKeys(b)) {
+			return false
+		}
+		for _, k := range ka {
+			va, _ := mapGet(a, k)
+			vb, ok := mapGet(b, k)
+			if !ok || !equals(va, vb) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare returns -1, 0 or 1 comparing numbers or strings.
+func compare(a, b interface{}) (int, error) {
+	if isNumber(a) && isNumber(b) {
+		fa, _ := toFloat(a)
+		fb, _ := toFloat(b)
+		switch {
+		case fa < fb:
+			return -1, nil
+		case fa > fb:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	if isString(a) && isString(b) {
+		return strings.Compare(toString(a), toString(b)), nil
+	}
+	if isSequence(a) && isSequence(b) {
+		la, lb := toList(a), toList(b)
+		for i := 0; i < len(la) && i < len(lb); i++ {
+			c, err := compare(la[i], lb[i])
+			if err != nil || c != 0 {
+				return c, err
+			}
+		}
+		return compare(len(la), len(lb))
+	}
+	return 0, fmt.Errorf("'<' not supported between %s and %s",
+		typeName(a), typeName(b))
+}
+
+// contains implements the in operator.
+func contains(container, item interface{}) (bool, error) {
+	switch {
+	case isString(container):
+		return strings.Contains(toString(container), toString(item)), nil
+	case isSequence(container):
+		for _, i := range toList(container) {
+			if equals(i, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case isMapping(container):
+		_, ok := mapGet(container, item)
+		return ok, nil
+	}
+	return false, fmt.Errorf("argument of type %s is not iterable", typeName(container))
+}
+
+// length returns the length of a string or a collection.
+func length(v interface{}) (int, error) {
+	switch {
+	case isString(v):
+		return len([]rune(toString(v))), nil
+	case isSequence(v) || isMapping(v):
+		return reflect.ValueOf(v).Len(), nil
+	}
+	return 0, fmt.Errorf("object of type %s has no len()", typeName(v))
+}
+
+func typeName(v interface{}) string {
+	switch {
+	case v == nil:
+		return "NoneType"
+	case IsUndefined(v):
+		return "Undefined"
+	case isString(v):
+		return "str"
+	case isInt(v):
+		return "int"
+	case isFloat(v):
+		return "float"
+	case isMapping(v):
+		return "dict"
+	case isSequence(v):
+		return "list"
+	}
+	if _, ok := v.(bool); ok {
+		return "bool"
+	}
+	return reflect.TypeOf(v).String()
+}
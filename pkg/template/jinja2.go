@@ -42,6 +42,29 @@ func (r *Jinja2Compiler) hasNotYamlEnvs() bool {
 	return ans
 }
 
+// getOpts returns the j2 options. j2 fails on undefined
+// variables unless the --undefined option is used.
+func (r *Jinja2Compiler) getOpts() []string {
+	ans := []string{}
+	for _, o := range r.Opts {
+		if o == "--undefined" && r.Strict != nil {
+			continue
+		}
+		ans = append(ans, o)
+	}
+	if r.Strict != nil && !*r.Strict {
+		ans = append(ans, "--undefined")
+	}
+	return ans
+}
+
+// stopTracking returns false because the files read by j2
+// are unknown and so the compiled files are not cached.
+func (r *Jinja2Compiler) stopTracking() ([]string, bool) {
+	r.DefaultCompiler.stopTracking()
+	return nil, false
+}
+
 func (r *Jinja2Compiler) Compile(sourceFile, destFile string) error {
 	var dataFile string
 
@@ -93,9 +116,7 @@ func (r *Jinja2Compiler) Compile(sourceFile, destFile string) error {
 		"-o", destFile,
 	}
 
-	if len(r.Opts) > 0 {
-		args = append(args, r.Opts...)
-	}
+	args = append(args, r.getOpts()...)
 	j2Command := exec.Command("j2", args...)
 
 	j2Command.Stdout = os.Stdout
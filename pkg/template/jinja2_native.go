@@ -0,0 +1,121 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package template
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+	"github.com/MottainaiCI/ssh-compose/pkg/template/jinja"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NativeJinja2Compiler compiles the Jinja2 templates with the
+// builtin engine without the external j2 tool.
+type NativeJinja2Compiler struct {
+	*DefaultCompiler
+}
+
+func NewNativeJinja2Compiler(proj *specs.SshCProject) *NativeJinja2Compiler {
+	return &NativeJinja2Compiler{
+		DefaultCompiler: &DefaultCompiler{
+			Project: proj,
+		},
+	}
+}
+
+// newEnvironment returns the environment configured with the
+// options of the engine. As j2cli the undefined variables are
+// not allowed without the --undefined option.
+func (r *NativeJinja2Compiler) newEnvironment(baseDir string) (*jinja.Environment, error) {
+	env := jinja.NewEnvironment()
+	env.Strict = true
+	loader := jinja.NewFileLoader(baseDir)
+	env.Loader = func(name string) (string, error) {
+		p := name
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(baseDir, name)
+		}
+		r.addDependency(DependencyFile + ":" + p)
+		return loader(name)
+	}
+
+	for _, o := range r.Opts {
+		switch o {
+		case "--undefined":
+			env.Strict = false
+		default:
+			return nil, fmt.Errorf("option %s not supported by the jinja2-native engine", o)
+		}
+	}
+
+	if r.Strict != nil {
+		env.Strict = *r.Strict
+	}
+
+	return env, nil
+}
+
+// getData returns the variables converted as they are
+// read by j2cli from the YAML data file.
+func (r *NativeJinja2Compiler) getData() (map[string]interface{}, error) {
+	d, err := yaml.Marshal(&r.Vars)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make(map[string]interface{}, 0)
+	err = yaml.Unmarshal(d, &ans)
+	if err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+func (r *NativeJinja2Compiler) render(name, sourceData, baseDir string) (string, error) {
+	env, err := r.newEnvironment(baseDir)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := r.getData()
+	if err != nil {
+		return "", err
+	}
+
+	return env.Render(name, sourceData, data)
+}
+
+func (r *NativeJinja2Compiler) Compile(sourceFile, destFile string) error {
+	sourceData, err := os.ReadFile(sourceFile)
+	if err != nil {
+		return err
+	}
+
+	dstData, err := r.render(sourceFile, string(sourceData), filepath.Dir(sourceFile))
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(destFile), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(destFile, []byte(dstData), 0644)
+}
+
+func (r *NativeJinja2Compiler) CompileRaw(sourceData string) (string, error) {
+	envBaseDirAbs, err := filepath.Abs(r.EnvBaseDir)
+	if err != nil {
+		return "", err
+	}
+
+	return r.render("", sourceData, envBaseDirAbs)
+}
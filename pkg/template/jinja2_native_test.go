@@ -0,0 +1,68 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package template_test
+
+import (
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+	. "github.com/MottainaiCI/ssh-compose/pkg/template"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("", func() {
+
+	Context("TemplateNativeJinja1", func() {
+
+		proj := &specs.SshCProject{
+			Name: "project1",
+			Environments: []specs.SshCEnvVars{
+				{
+					EnvVars: map[string]interface{}{
+						"key1": "value1",
+						"key2": "value2",
+						"key3": map[string]string{
+							"f1": "foo",
+							"f2": "foo2",
+						},
+					},
+				},
+			},
+		}
+
+		c := NewNativeJinja2Compiler(proj)
+		c.InitVars()
+
+		It("Compilation1", func() {
+
+			sourceData := `
+k1: "{{ key1 }}"
+k2: "{{ key2 }}"
+k3: {{ key3 | to_json }}
+`
+			out, err := c.CompileRaw(sourceData)
+
+			expectedOutput := `
+k1: "value1"
+k2: "value2"
+k3: {"f1": "foo", "f2": "foo2"}
+`
+			Expect(err).Should(BeNil())
+			Expect(out).To(Equal(expectedOutput))
+		})
+
+		It("Compilation2", func() {
+			_, err := c.CompileRaw(`k4: "{{ key4 }}"`)
+			Expect(err).ShouldNot(BeNil())
+
+			c.SetOpts([]string{"--undefined"})
+			out, err := c.CompileRaw(`k4: "{{ key4 | default('value4') }}{{ key5 }}"`)
+			Expect(err).Should(BeNil())
+			Expect(out).To(Equal(`k4: "value4"`))
+			c.SetOpts([]string{})
+		})
+
+	})
+})
@@ -54,6 +54,12 @@ func (r *MottainaiCompiler) Compile(sourceFile, destFile string) error {
 func (r *MottainaiCompiler) CompileRaw(sourceData string) (string, error) {
 	tmpl := NewTemplate()
 	tmpl.Values = r.Vars
+	tmpl.Project = r.Project
+	tmpl.BaseDir = r.EnvBaseDir
+	tmpl.OnDependency = r.addDependency
+	if r.Strict != nil {
+		tmpl.Strict = *r.Strict
+	}
 
 	destData, err := tmpl.Draw(sourceData)
 	if err != nil {
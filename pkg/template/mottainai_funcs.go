@@ -0,0 +1,124 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package template
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Max depth of the nested include calls.
+const maxIncludeDepth = 32
+
+// sshcFuncMap returns the ssh-compose functions available in the
+// templates in addition to the sprig functions.
+func (tem *Template) sshcFuncMap(depth int) template.FuncMap {
+	return template.FuncMap{
+		"node": func(name string) (*specs.SshCNode, error) {
+			if tem.Project == nil {
+				return nil, errors.New("node function without project")
+			}
+			tem.addDependency(DependencyNode, name)
+			_, node := tem.Project.GetNodeByName(name)
+			if node == nil {
+				return nil, fmt.Errorf("node %s not found", name)
+			}
+			return node, nil
+		},
+		"group": func(name string) (*specs.SshCGroup, error) {
+			if tem.Project == nil {
+				return nil, errors.New("group function without project")
+			}
+			tem.addDependency(DependencyGroup, name)
+			group := tem.Project.GetGroupByName(name)
+			if group == nil {
+				return nil, fmt.Errorf("group %s not found", name)
+			}
+			return group, nil
+		},
+		"secret": func(name string) (interface{}, error) {
+			if tem.Project == nil {
+				return nil, errors.New("secret function without project")
+			}
+			tem.addDependency(DependencySecret, name)
+			v, ok := tem.Project.GetSecretVar(name)
+			if !ok {
+				return nil, fmt.Errorf("secret %s not found", name)
+			}
+			return v, nil
+		},
+		"include": func(file string, data ...interface{}) (string, error) {
+			if depth >= maxIncludeDepth {
+				return "", fmt.Errorf("max include depth reached on file %s", file)
+			}
+			tem.addDependency(DependencyFile, tem.getPath(file))
+			content, err := os.ReadFile(tem.getPath(file))
+			if err != nil {
+				return "", err
+			}
+			var d interface{} = &tem.Values
+			if len(data) > 0 {
+				d = data[0]
+			}
+			return tem.draw(file, string(content), d, depth+1)
+		},
+		"readFile": func(file string) (string, error) {
+			tem.addDependency(DependencyFile, tem.getPath(file))
+			content, err := os.ReadFile(tem.getPath(file))
+			if err != nil {
+				return "", err
+			}
+			return string(content), nil
+		},
+		"toYaml": func(v interface{}) (string, error) {
+			data, err := yaml.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimSuffix(string(data), "\n"), nil
+		},
+		"fromYaml": func(s string) (interface{}, error) {
+			var ans interface{}
+			err := yaml.Unmarshal([]byte(s), &ans)
+			return ans, err
+		},
+		"fromJson": func(s string) (interface{}, error) {
+			var ans interface{}
+			err := json.Unmarshal([]byte(s), &ans)
+			return ans, err
+		},
+		"required": func(msg string, v interface{}) (interface{}, error) {
+			if v == nil {
+				return nil, errors.New(msg)
+			}
+			if s, ok := v.(string); ok && s == "" {
+				return nil, errors.New(msg)
+			}
+			return v, nil
+		},
+	}
+}
+
+func (tem *Template) getPath(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(tem.BaseDir, file)
+}
+
+func (tem *Template) addDependency(kind, name string) {
+	if tem.OnDependency != nil {
+		tem.OnDependency(kind + ":" + name)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package template_test
+
+import (
+	"os"
+	"path/filepath"
+
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+	. "github.com/MottainaiCI/ssh-compose/pkg/template"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Mottainai functions", func() {
+
+	Context("Project functions", func() {
+
+		proj := &specs.SshCProject{
+			Name: "project1",
+			Environments: []specs.SshCEnvVars{
+				{
+					EnvVars: map[string]interface{}{
+						"key1": "value1",
+						"json": `{"a": [1, 2]}`,
+					},
+				},
+				{
+					EnvVars: map[string]interface{}{
+						"db_password": "pass",
+					},
+					Encrypted: true,
+				},
+			},
+			Groups: []specs.SshCGroup{
+				{
+					Name: "group1",
+					Nodes: []specs.SshCNode{
+						{
+							Name:     "db1",
+							Endpoint: "10.0.0.1",
+							Labels:   map[string]string{"role": "db"},
+						},
+					},
+				},
+			},
+		}
+
+		It("Lookup entities and secrets", func() {
+			c := NewMottainaiCompiler(proj)
+			c.InitVars()
+
+			out, err := c.CompileRaw(
+				`{{ (node "db1").Endpoint }} {{ (node "db1").Labels.role }} ` +
+					`{{ len (group "group1").Nodes }} {{ secret "db_password" }}`)
+			Expect(err).Should(BeNil())
+			Expect(out).To(Equal("10.0.0.1 db 1 pass"))
+
+			_, err = c.CompileRaw(`{{ secret "key1" }}`)
+			Expect(err).ShouldNot(BeNil())
+
+			_, err = c.CompileRaw(`{{ node "db2" }}`)
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Files and conversions", func() {
+			dir, err := os.MkdirTemp("", "sshc-funcs")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			Expect(os.MkdirAll(filepath.Join(dir, "partials"), 0755)).Should(BeNil())
+			Expect(os.WriteFile(filepath.Join(dir, "partials", "header.tmpl"),
+				[]byte("# {{ .key1 }}"), 0644)).Should(BeNil())
+			Expect(os.WriteFile(filepath.Join(dir, "data.txt"),
+				[]byte("raw {{ data }}"), 0644)).Should(BeNil())
+
+			c := NewMottainaiCompiler(proj)
+			c.SetEnvBaseDir(dir)
+			c.InitVars()
+
+			out, err := c.CompileRaw(`{{ include "partials/header.tmpl" }}
+{{ readFile "data.txt" }}
+{{ index (fromJson .json).a 1 }}
+{{ (fromYaml "a: {b: c}").a | toYaml }}
+{{ required "key1 is required" .key1 }}`)
+			Expect(err).Should(BeNil())
+			Expect(out).To(Equal("# value1\nraw {{ data }}\n2\nb: c\nvalue1"))
+
+			_, err = c.CompileRaw(`{{ required "missing is required" .missing }}`)
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).To(ContainSubstring("missing is required"))
+		})
+	})
+})
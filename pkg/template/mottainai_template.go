@@ -16,12 +16,25 @@ import (
 	"strings"
 	"text/template"
 
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+
 	"github.com/Masterminds/sprig"
 	"gopkg.in/yaml.v3"
 )
 
 type Template struct {
 	Values map[string]interface{}
+	// Fail on the missing keys of the values.
+	Strict bool
+
+	// Project used by the node, group and secret functions and
+	// base directory of the files used by include and readFile.
+	Project *specs.SshCProject
+	BaseDir string
+
+	// Called with the files and the lookups read by the
+	// functions of the template.
+	OnDependency func(dep string)
 }
 
 func NewTemplate() *Template { return &Template{Values: map[string]interface{}{}} }
@@ -78,6 +91,10 @@ func (tem *Template) ReadValues(raw string) (map[string]map[string]interface{},
 }
 
 func (tem *Template) Draw(raw string) (string, error) {
+	return tem.draw("spec", raw, &tem.Values, 0)
+}
+
+func (tem *Template) draw(name, raw string, data interface{}, depth int) (string, error) {
 	tf := sprig.TxtFuncMap()
 	tf["isInt"] = func(i interface{}) bool {
 		v := reflect.ValueOf(i)
@@ -158,13 +175,19 @@ func (tem *Template) Draw(raw string) (string, error) {
 		}
 		return ans
 	}
-	t := template.New("spec").Funcs(tf)
+	for k, v := range tem.sshcFuncMap(depth) {
+		tf[k] = v
+	}
+	t := template.New(name).Funcs(tf)
+	if tem.Strict {
+		t = t.Option("missingkey=error")
+	}
 	tt, err := t.Parse(raw)
 	if err != nil {
 		return "", err
 	}
 	var doc bytes.Buffer
-	if err = tt.Execute(&doc, &tem.Values); err != nil {
+	if err = tt.Execute(&doc, data); err != nil {
 		return "", err
 	}
 	return doc.String(), nil
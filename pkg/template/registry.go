@@ -0,0 +1,133 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package template
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+)
+
+// TemplateCompilerRegistry is the compiler of a project. It uses the
+// template engine of the environment and it creates on demand the
+// compilers of the engines selected by the single templates.
+// All compilers share the variables of the registry.
+type TemplateCompilerRegistry struct {
+	SshCTemplateCompiler
+
+	Project   *specs.SshCProject
+	Engine    specs.SshCTemplateEngine
+	compilers map[string]SshCTemplateCompiler
+}
+
+func NewTemplateCompiler(engine string, proj *specs.SshCProject) (SshCTemplateCompiler, error) {
+	var compiler SshCTemplateCompiler
+
+	switch engine {
+	case "jinja2":
+		compiler = NewJinja2Compiler(proj)
+	case "jinja2-native":
+		compiler = NewNativeJinja2Compiler(proj)
+	case "mottainai":
+		compiler = NewMottainaiCompiler(proj)
+	default:
+		return compiler, errors.New("Invalid template engine " + engine)
+	}
+
+	return compiler, nil
+}
+
+func NewTemplateCompilerRegistry(engine specs.SshCTemplateEngine,
+	proj *specs.SshCProject) (*TemplateCompilerRegistry, error) {
+	compiler, err := NewTemplateCompiler(engine.Engine, proj)
+	if err != nil {
+		return nil, err
+	}
+	compiler.SetOpts(engine.Opts)
+
+	return &TemplateCompilerRegistry{
+		SshCTemplateCompiler: compiler,
+		Project:              proj,
+		Engine:               engine,
+		compilers:            make(map[string]SshCTemplateCompiler, 0),
+	}, nil
+}
+
+// GetCompiler returns the compiler of the engine with the options
+// defined by the template. Without overrides it returns the
+// registry itself.
+func (r *TemplateCompilerRegistry) GetCompiler(t *specs.SshCConfigTemplate) (SshCTemplateCompiler, error) {
+	if t.Engine == "" && len(t.Opts) == 0 && t.Strict == nil {
+		return r, nil
+	}
+
+	engine, opts := r.resolve(t)
+	key := getEngineKey(r, t)
+
+	compiler, ok := r.compilers[key]
+	if !ok {
+		var err error
+		compiler, err = NewTemplateCompiler(engine, r.Project)
+		if err != nil {
+			return nil, err
+		}
+		compiler.SetOpts(opts)
+		compiler.SetStrict(t.Strict)
+		r.compilers[key] = compiler
+	}
+
+	// Share the current variables and the environment directory.
+	compiler.SetEnvBaseDir(r.GetEnvBaseDir())
+	*compiler.GetVars() = *r.GetVars()
+
+	return compiler, nil
+}
+
+// resolve returns the engine and the options used to compile
+// the template.
+func (r *TemplateCompilerRegistry) resolve(t *specs.SshCConfigTemplate) (string, []string) {
+	engine := t.Engine
+	opts := t.Opts
+	if engine == "" || engine == r.Engine.Engine {
+		engine = r.Engine.Engine
+		if len(opts) == 0 {
+			opts = r.Engine.Opts
+		}
+	}
+	return engine, opts
+}
+
+// getEngineKey returns a string that identifies the engine, the
+// options and the strict mode used to compile the template.
+func getEngineKey(compiler SshCTemplateCompiler, t *specs.SshCConfigTemplate) string {
+	engine := fmt.Sprintf("%T", compiler)
+	opts := t.Opts
+	if registry, ok := compiler.(*TemplateCompilerRegistry); ok {
+		engine, opts = registry.resolve(t)
+	}
+
+	strict := "default"
+	if t.Strict != nil {
+		strict = fmt.Sprintf("%v", *t.Strict)
+	}
+
+	return fmt.Sprintf("%s|%s|%s", engine, strings.Join(opts, " "), strict)
+}
+
+// GetTemplateCompiler returns the compiler to use with the template.
+func GetTemplateCompiler(compiler SshCTemplateCompiler, t *specs.SshCConfigTemplate) (SshCTemplateCompiler, error) {
+	if registry, ok := compiler.(*TemplateCompilerRegistry); ok {
+		return registry.GetCompiler(t)
+	}
+
+	if t.Engine != "" || len(t.Opts) > 0 || t.Strict != nil {
+		return nil, fmt.Errorf(
+			"template %s: engine options not supported by the compiler", t.Source)
+	}
+
+	return compiler, nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package template_test
+
+import (
+	"os"
+	"path/filepath"
+
+	log "github.com/MottainaiCI/ssh-compose/pkg/logger"
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+	. "github.com/MottainaiCI/ssh-compose/pkg/template"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Compiler registry", func() {
+
+	Context("Per template engine", func() {
+
+		proj := &specs.SshCProject{
+			Name: "project1",
+			Environments: []specs.SshCEnvVars{
+				{
+					EnvVars: map[string]interface{}{
+						"key1": "value1",
+					},
+				},
+			},
+		}
+
+		It("Mix engines", func() {
+			log.NewSshCLogger(specs.NewSshComposeConfig(nil)).SetAsDefault()
+
+			dir, err := os.MkdirTemp("", "sshc-registry")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			strict := true
+			lenient := false
+			env := &specs.SshCEnvironment{
+				File:           filepath.Join(dir, "env.yml"),
+				TemplateEngine: specs.SshCTemplateEngine{Engine: "mottainai"},
+			}
+			proj.ConfigTemplates = []specs.SshCConfigTemplate{
+				{Source: "a.tmpl", Destination: "a.conf"},
+				{Source: "b.j2", Destination: "b.conf", Engine: "jinja2-native", Strict: &lenient},
+				{Source: "c.tmpl", Destination: "c.conf", Strict: &strict},
+			}
+
+			Expect(os.WriteFile(filepath.Join(dir, "a.tmpl"),
+				[]byte("{{ .key1 }}:{{ .missing }}"), 0644)).Should(BeNil())
+			Expect(os.WriteFile(filepath.Join(dir, "b.j2"),
+				[]byte("{{ key1 }}:{{ project.name }}:{{ missing }}"), 0644)).Should(BeNil())
+			Expect(os.WriteFile(filepath.Join(dir, "c.tmpl"),
+				[]byte("{{ .key1 }}"), 0644)).Should(BeNil())
+
+			compiler, err := NewProjectTemplateCompiler(env, proj)
+			Expect(err).Should(BeNil())
+
+			err = CompileProjectFiles(proj, compiler, CompilerOpts{})
+			Expect(err).Should(BeNil())
+
+			data, err := os.ReadFile(filepath.Join(dir, "a.conf"))
+			Expect(err).Should(BeNil())
+			Expect(string(data)).To(Equal("value1:<no value>"))
+
+			data, err = os.ReadFile(filepath.Join(dir, "b.conf"))
+			Expect(err).Should(BeNil())
+			Expect(string(data)).To(Equal("value1:project1:"))
+
+			data, err = os.ReadFile(filepath.Join(dir, "c.conf"))
+			Expect(err).Should(BeNil())
+			Expect(string(data)).To(Equal("value1"))
+
+			Expect(os.WriteFile(filepath.Join(dir, "c.tmpl"),
+				[]byte("{{ .missing }}"), 0644)).Should(BeNil())
+			err = CompileProjectFiles(proj, compiler, CompilerOpts{})
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Invalid engine", func() {
+			_, err := NewProjectTemplateCompiler(&specs.SshCEnvironment{
+				TemplateEngine: specs.SshCTemplateEngine{Engine: "foo"},
+			}, proj)
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+})
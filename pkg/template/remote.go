@@ -0,0 +1,99 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package template
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+)
+
+// RemoteTemplate contains the content of a remote template
+// compiled for a node.
+type RemoteTemplate struct {
+	specs.SshCRemoteTemplate
+
+	SourceFile string
+	Content    []byte
+}
+
+// CompileNodeRemoteTemplates compiles in memory the remote templates
+// of the group and of the node. The sources of the group templates are
+// relative to the environment directory and the sources of the node
+// templates are relative to the node source directory.
+func CompileNodeRemoteTemplates(node *specs.SshCNode, group *specs.SshCGroup,
+	compiler SshCTemplateCompiler) ([]RemoteTemplate, error) {
+	ans := []RemoteTemplate{}
+
+	envBaseAbs, err := filepath.Abs(compiler.GetEnvBaseDir())
+	if err != nil {
+		return ans, err
+	}
+
+	baseDir := node.SourceDir
+	if !filepath.IsAbs(node.SourceDir) {
+		baseDir = filepath.Join(envBaseAbs, node.SourceDir)
+	}
+
+	// Set the group and the node keys with the current node.
+	(*compiler.GetVars())["group"] = group
+	(*compiler.GetVars())["node"] = *node
+	for k, v := range node.Labels {
+		(*compiler.GetVars())[k] = v
+	}
+
+	if group != nil {
+		for _, rt := range group.RemoteTemplates {
+			t, err := compileRemoteTemplate(envBaseAbs, rt, compiler)
+			if err != nil {
+				return ans, err
+			}
+			ans = append(ans, *t)
+		}
+	}
+
+	for _, rt := range node.RemoteTemplates {
+		t, err := compileRemoteTemplate(baseDir, rt, compiler)
+		if err != nil {
+			return ans, err
+		}
+		ans = append(ans, *t)
+	}
+
+	return ans, nil
+}
+
+func compileRemoteTemplate(baseDir string, rt specs.SshCRemoteTemplate,
+	compiler SshCTemplateCompiler) (*RemoteTemplate, error) {
+
+	if !path.IsAbs(rt.Destination) {
+		return nil, fmt.Errorf("invalid destination %s of the remote template %s: it must be an absolute path",
+			rt.Destination, rt.Source)
+	}
+
+	sourceFile := rt.Source
+	if !filepath.IsAbs(sourceFile) {
+		sourceFile = filepath.Join(baseDir, rt.Source)
+	}
+
+	data, err := os.ReadFile(sourceFile)
+	if err != nil {
+		return nil, fmt.Errorf("error on read template %s: %s", sourceFile, err.Error())
+	}
+
+	content, err := compiler.CompileRaw(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("error on compile template %s: %s", sourceFile, err.Error())
+	}
+
+	return &RemoteTemplate{
+		SshCRemoteTemplate: rt,
+		SourceFile:         sourceFile,
+		Content:            []byte(content),
+	}, nil
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package template_test
+
+import (
+	"os"
+	"path/filepath"
+
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+	. "github.com/MottainaiCI/ssh-compose/pkg/template"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Remote templates", func() {
+
+	Context("Compile in memory", func() {
+
+		proj := &specs.SshCProject{
+			Name: "project1",
+			Environments: []specs.SshCEnvVars{
+				{
+					EnvVars: map[string]interface{}{
+						"key1": "value1",
+					},
+				},
+			},
+		}
+
+		It("Group and node templates", func() {
+			dir, err := os.MkdirTemp("", "sshc-remote-tmpl")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			Expect(os.MkdirAll(filepath.Join(dir, "node1"), 0755)).Should(BeNil())
+			Expect(os.WriteFile(filepath.Join(dir, "group.tmpl"),
+				[]byte("{{ .group.Name }}:{{ .node.Name }}"), 0644)).Should(BeNil())
+			Expect(os.WriteFile(filepath.Join(dir, "node1", "node.tmpl"),
+				[]byte("{{ .key1 }}:{{ .role }}"), 0644)).Should(BeNil())
+
+			node := specs.SshCNode{
+				Name:      "node1",
+				SourceDir: "node1",
+				Labels:    map[string]string{"role": "web"},
+				RemoteTemplates: []specs.SshCRemoteTemplate{
+					{Source: "node.tmpl", Destination: "/etc/node.conf"},
+				},
+			}
+			group := &specs.SshCGroup{
+				Name: "group1",
+				RemoteTemplates: []specs.SshCRemoteTemplate{
+					{Source: "group.tmpl", Destination: "/etc/group.conf"},
+				},
+			}
+
+			c := NewMottainaiCompiler(proj)
+			c.SetEnvBaseDir(dir)
+			c.InitVars()
+
+			templates, err := CompileNodeRemoteTemplates(&node, group, c)
+			Expect(err).Should(BeNil())
+			Expect(len(templates)).To(Equal(2))
+			Expect(string(templates[0].Content)).To(Equal("group1:node1"))
+			Expect(templates[0].Destination).To(Equal("/etc/group.conf"))
+			Expect(string(templates[1].Content)).To(Equal("value1:web"))
+			Expect(templates[1].SourceFile).To(Equal(filepath.Join(dir, "node1", "node.tmpl")))
+		})
+
+		It("Relative destination", func() {
+			node := specs.SshCNode{
+				Name: "node1",
+				RemoteTemplates: []specs.SshCRemoteTemplate{
+					{Source: "node.tmpl", Destination: "etc/node.conf"},
+				},
+			}
+
+			c := NewMottainaiCompiler(proj)
+			c.InitVars()
+
+			_, err := CompileNodeRemoteTemplates(&node, nil, c)
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+})
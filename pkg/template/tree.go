@@ -0,0 +1,123 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package template
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MottainaiCI/ssh-compose/pkg/helpers"
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+)
+
+// CompileConfigTemplate compiles the template with the compiler
+// selected by the template options. If the source is a directory
+// all files of the tree are compiled under the destination
+// directory with the same layout. It returns the paths of the
+// written files. With the cache the files with unchanged inputs
+// are not compiled again.
+func CompileConfigTemplate(compiler SshCTemplateCompiler, t *specs.SshCConfigTemplate,
+	sourceFile, destFile string, cache *CompileCache) ([]string, error) {
+
+	c, err := GetTemplateCompiler(compiler, t)
+	if err != nil {
+		return nil, err
+	}
+
+	engineKey := getEngineKey(compiler, t)
+	tracker, tracked := getDependencyTracker(c)
+	compileFile := func(src, dst string) error {
+		if cache == nil || !tracked {
+			return c.Compile(src, dst)
+		}
+		return cache.compile(engineKey, src, dst, c.GetVars(), tracker, func() error {
+			return c.Compile(src, dst)
+		})
+	}
+
+	info, err := os.Stat(sourceFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{destFile}, compileFile(sourceFile, destFile)
+	}
+
+	rules := helpers.NewIgnoreRules()
+	err = rules.AddPatterns(t.PassThrough)
+	if err != nil {
+		return nil, fmt.Errorf("error on parse pass_through patterns of %s: %s",
+			t.Source, err.Error())
+	}
+
+	files := []string{}
+	err = filepath.WalkDir(sourceFile, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(sourceFile, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if isPassThrough(rules, rel) {
+			target := filepath.Join(destFile, filepath.FromSlash(rel))
+			files = append(files, target)
+			if cache == nil {
+				return copyFile(p, target)
+			}
+			return cache.compile("copy", p, target, nil, nil, func() error {
+				return copyFile(p, target)
+			})
+		}
+
+		if t.StripSuffix != "" {
+			rel = strings.TrimSuffix(rel, t.StripSuffix)
+		}
+
+		target := filepath.Join(destFile, filepath.FromSlash(rel))
+		files = append(files, target)
+		return compileFile(p, target)
+	})
+
+	return files, err
+}
+
+// isPassThrough returns true if the file or one of its parent
+// directories match the pass-through patterns.
+func isPassThrough(rules *helpers.IgnoreRules, rel string) bool {
+	if rules.IsEmpty() {
+		return false
+	}
+	return rules.MatchTree(rel, false)
+}
+
+func copyFile(sourceFile, destFile string) error {
+	info, err := os.Stat(sourceFile)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(sourceFile)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(destFile), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(destFile, data, info.Mode().Perm())
+}
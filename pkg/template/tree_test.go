@@ -0,0 +1,79 @@
+/*
+Copyright © 2024-2025 Daniele Rondina <geaaru@macaronios.org>
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package template_test
+
+import (
+	"os"
+	"path/filepath"
+
+	specs "github.com/MottainaiCI/ssh-compose/pkg/specs"
+	. "github.com/MottainaiCI/ssh-compose/pkg/template"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Directory templates", func() {
+
+	Context("Compile tree", func() {
+
+		proj := &specs.SshCProject{
+			Name: "project1",
+			Environments: []specs.SshCEnvVars{
+				{
+					EnvVars: map[string]interface{}{
+						"key1": "value1",
+					},
+				},
+			},
+		}
+
+		It("Strip suffix and pass-through", func() {
+			dir, err := os.MkdirTemp("", "sshc-tree")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+
+			files := map[string]string{
+				"conf/app.conf.tmpl":        "{{ .key1 }}",
+				"conf/sites/site1.tmpl":     "site {{ .key1 }}",
+				"conf/static/index.html":    "{{ .notcompiled }}",
+				"conf/scripts/run.sh":       "echo {{ .key1 }}",
+				"conf/scripts/raw.sh.plain": "{{ raw }}",
+			}
+			for f, content := range files {
+				Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, f)), 0755)).Should(BeNil())
+				Expect(os.WriteFile(filepath.Join(dir, f), []byte(content), 0644)).Should(BeNil())
+			}
+
+			c := NewMottainaiCompiler(proj)
+			c.SetEnvBaseDir(dir)
+			c.InitVars()
+
+			t := &specs.SshCConfigTemplate{
+				Source:      "conf",
+				Destination: "out",
+				StripSuffix: ".tmpl",
+				PassThrough: []string{"static/", "*.plain"},
+			}
+			compiled, err := CompileConfigTemplate(c, t,
+				filepath.Join(dir, "conf"), filepath.Join(dir, "out"), nil)
+			Expect(err).Should(BeNil())
+			Expect(len(compiled)).To(Equal(5))
+
+			expected := map[string]string{
+				"out/app.conf":             "value1",
+				"out/sites/site1":          "site value1",
+				"out/static/index.html":    "{{ .notcompiled }}",
+				"out/scripts/run.sh":       "echo value1",
+				"out/scripts/raw.sh.plain": "{{ raw }}",
+			}
+			for f, content := range expected {
+				data, err := os.ReadFile(filepath.Join(dir, f))
+				Expect(err).Should(BeNil())
+				Expect(string(data)).To(Equal(content))
+			}
+		})
+	})
+})